@@ -454,6 +454,9 @@ func (fb *filterBackend) SubscribeTxPreEvent(ch chan<- core.TxPreEvent) event.Su
 func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return fb.bc.SubscribeChainEvent(ch)
 }
+func (fb *filterBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return fb.bc.SubscribeChainFinalizedEvent(ch)
+}
 func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return fb.bc.SubscribeRemovedLogsEvent(ch)
 }
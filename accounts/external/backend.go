@@ -0,0 +1,232 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package external implements an account backend delegating all signing to a
+// remote signer process reachable over RPC (usually an IPC socket).
+package external
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	ecereum "github.com/ecchain/go-ecchain"
+	"github.com/ecchain/go-ecchain/accounts"
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/event"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/rlp"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+const (
+	// queryTimeout is the time allowed for the remote signer to list accounts.
+	queryTimeout = 5 * time.Second
+
+	// signTimeout is the time allowed for the remote signer to answer a signing
+	// request, which may need the confirmation of its operator.
+	signTimeout = time.Minute
+)
+
+// Backend is an account backend exposing the single wallet of a remote signer.
+type Backend struct {
+	signers []accounts.Wallet
+}
+
+// NewBackend creates an account backend for the remote signer reachable at the
+// given endpoint. The signer doesn't need to be running yet, the connection is
+// established on first use and reestablished whenever it drops.
+func NewBackend(endpoint string) *Backend {
+	return &Backend{
+		signers: []accounts.Wallet{NewSigner(endpoint)},
+	}
+}
+
+// Wallets implements accounts.Backend, returning the remote signer wallet.
+func (b *Backend) Wallets() []accounts.Wallet {
+	return b.signers
+}
+
+// Subscribe implements accounts.Backend. The remote signer wallet is always
+// present, so no arrival or departure events are ever fired.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// Signer is a wallet forwarding all requests to a remote signer, which holds
+// the keys and decides on its own whecer to honour signing requests.
+type Signer struct {
+	endpoint string
+
+	client *rpc.Client // Connection to the remote signer, nil if not yet dialed
+	cache  []accounts.Account
+	lock   sync.Mutex
+}
+
+// NewSigner creates a wallet for the remote signer at the given endpoint.
+func NewSigner(endpoint string) *Signer {
+	return &Signer{endpoint: endpoint}
+}
+
+// dial returns the connection to the remote signer, establishing it if needed.
+func (s *Signer) dial(ctx context.Context) (*rpc.Client, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+	client, err := rpc.DialContext(ctx, s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+	return client, nil
+}
+
+// call invokes a method of the remote signer, giving up if it doesn't answer
+// within the timeout.
+func (s *Signer) call(timeout time.Duration, result interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	return client.CallContext(ctx, result, method, args...)
+}
+
+// URL implements accounts.Wallet, returning the endpoint of the remote signer.
+func (s *Signer) URL() accounts.URL {
+	return accounts.URL{Scheme: "extapi", Path: s.endpoint}
+}
+
+// Status implements accounts.Wallet, reporting whecer the remote signer can
+// currently be reached.
+func (s *Signer) Status() (string, error) {
+	var addresses []common.Address
+	if err := s.call(queryTimeout, &addresses, "account_list"); err != nil {
+		return "Offline", err
+	}
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, but is a noop as the remote signer is dialed
+// on demand.
+func (s *Signer) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet, dropping the connection to the remote signer.
+func (s *Signer) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	return nil
+}
+
+// Accounts implements accounts.Wallet, retrieving the accounts the remote signer
+// manages. If the signer is unreachable, the last known list is returned.
+func (s *Signer) Accounts() []accounts.Account {
+	var addresses []common.Address
+	if err := s.call(queryTimeout, &addresses, "account_list"); err != nil {
+		log.Debug("Failed to list remote signer accounts", "endpoint", s.endpoint, "err", err)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.cache
+	}
+	list := make([]accounts.Account, 0, len(addresses))
+	for _, address := range addresses {
+		list = append(list, accounts.Account{Address: address, URL: s.URL()})
+	}
+	s.lock.Lock()
+	s.cache = list
+	s.lock.Unlock()
+
+	return list
+}
+
+// Contains implements accounts.Wallet, returning whecer the remote signer is
+// known to manage the given account.
+func (s *Signer) Contains(account accounts.Account) bool {
+	if account.URL != (accounts.URL{}) && account.URL != s.URL() {
+		return false
+	}
+	for _, known := range s.Accounts() {
+		if known.Address == account.Address {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive implements accounts.Wallet, but is not supported by remote signers.
+func (s *Signer) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for remote signers.
+func (s *Signer) SelfDerive(base accounts.DerivationPath, chain ecereum.ChainStateReader) {}
+
+// SignHash implements accounts.Wallet, requesting the remote signer to sign the
+// given hash with the given account.
+func (s *Signer) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	if err := s.call(signTimeout, &signature, "account_signHash", account.Address, hexutil.Bytes(hash)); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// SignTx implements accounts.Wallet, requesting the remote signer to sign the
+// given transaction with the given account.
+func (s *Signer) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	var signed hexutil.Bytes
+	if err := s.call(signTimeout, &signed, "account_signTransaction", account.Address, hexutil.Bytes(raw), (*hexutil.Big)(chainID)); err != nil {
+		return nil, err
+	}
+	result := new(types.Transaction)
+	if err := rlp.DecodeBytes(signed, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SignHashWithPassphrase implements accounts.Wallet, but is not supported as the
+// remote signer handles authentication on its own.
+func (s *Signer) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, but is not supported as the
+// remote signer handles authentication on its own.
+func (s *Signer) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, accounts.ErrNotSupported
+}
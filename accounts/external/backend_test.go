@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecchain/go-ecchain/accounts"
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+// SignerAPI is the API of a remote signer holding a single key.
+type SignerAPI struct {
+	key *ecdsa.PrivateKey
+}
+
+func (api *SignerAPI) List() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(api.key.PublicKey)}
+}
+
+func (api *SignerAPI) SignHash(address common.Address, hash hexutil.Bytes) (hexutil.Bytes, error) {
+	if address != crypto.PubkeyToAddress(api.key.PublicKey) {
+		return nil, errors.New("unknown account")
+	}
+	return crypto.Sign(hash, api.key)
+}
+
+// newTestSigner starts a remote signer serving over HTTP.
+func newTestSigner(t *testing.T) (*httptest.Server, *ecdsa.PrivateKey) {
+	key, _ := crypto.GenerateKey()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &SignerAPI{key: key}); err != nil {
+		t.Fatalf("failed to register signer API: %v", err)
+	}
+	return httptest.NewServer(server), key
+}
+
+// Tests that accounts are listed and hashes signed by the remote signer, and
+// that the last known accounts are kept while the signer is unreachable.
+func TestSignerRoundTrip(t *testing.T) {
+	remote, key := newTestSigner(t)
+	defer remote.Close()
+
+	signer := NewSigner(remote.URL)
+	defer signer.Close()
+
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	if status, err := signer.Status(); status != "Online" || err != nil {
+		t.Fatalf("status mismatch: have %s (err %v), want Online", status, err)
+	}
+	list := signer.Accounts()
+	if len(list) != 1 || list[0].Address != address || list[0].URL != signer.URL() {
+		t.Fatalf("accounts mismatch: have %v, want %x at %v", list, address, signer.URL())
+	}
+	if !signer.Contains(accounts.Account{Address: address}) {
+		t.Errorf("remote account not contained")
+	}
+	if signer.Contains(accounts.Account{Address: address, URL: accounts.URL{Scheme: "keystore", Path: "/key"}}) {
+		t.Errorf("account of other wallet contained")
+	}
+	if signer.Contains(accounts.Account{Address: common.Address{0x01}}) {
+		t.Errorf("unknown account contained")
+	}
+	hash := crypto.Keccak256([]byte("hello"))
+	sig, err := signer.SignHash(accounts.Account{Address: address}, hash)
+	if err != nil {
+		t.Fatalf("failed to sign hash: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != address {
+		t.Fatalf("signature not made by remote key (err %v)", err)
+	}
+	if _, err := signer.SignHash(accounts.Account{Address: common.Address{0x01}}, hash); err == nil {
+		t.Errorf("signed with unknown account")
+	}
+	// Take the signer down and ensure the failure surfaces, but the accounts stay
+	remote.Close()
+
+	if status, err := signer.Status(); status != "Offline" || err == nil {
+		t.Errorf("status mismatch: have %s (err %v), want Offline", status, err)
+	}
+	if !signer.Contains(accounts.Account{Address: address}) {
+		t.Errorf("cached account lost while signer offline")
+	}
+	if _, err := signer.SignHash(accounts.Account{Address: address}, hash); err == nil {
+		t.Errorf("signed with unreachable signer")
+	}
+}
+
+// Tests that an unreachable remote signer fails requests without blocking.
+func TestSignerUnreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external-signer-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signer := NewSigner(filepath.Join(dir, "signer.ipc"))
+	defer signer.Close()
+
+	if status, err := signer.Status(); status != "Offline" || err == nil {
+		t.Errorf("status mismatch: have %s (err %v), want Offline", status, err)
+	}
+	if list := signer.Accounts(); len(list) != 0 {
+		t.Errorf("accounts listed by unreachable signer: %v", list)
+	}
+	if _, err := signer.SignHash(accounts.Account{Address: common.Address{0x01}}, make([]byte, 32)); err == nil {
+		t.Errorf("signed with unreachable signer")
+	}
+	if _, err := signer.Derive(accounts.DefaultBaseDerivationPath, false); err != accounts.ErrNotSupported {
+		t.Errorf("derive error mismatch: have %v, want %v", err, accounts.ErrNotSupported)
+	}
+}
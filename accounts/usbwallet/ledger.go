@@ -32,6 +32,7 @@ import (
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/hexutil"
 	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/rlp"
 )
@@ -51,6 +52,7 @@ const (
 	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and ecchain address for a given BIP 32 path
 	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an ecchain transaction after having the user validate the parameters
 	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignHash         ledgerOpcode = 0x0e // Signs a raw 32 byte hash after having the user validate it
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1ConfirmFetchAddress     ledgerParam1 = 0x01 // Require a user confirmation before returning the address
@@ -73,6 +75,7 @@ var errLedgerInvalidVersionReply = errors.New("ledger: invalid version reply")
 type ledgerDriver struct {
 	device  io.ReadWriter // USB device connection to communicate through
 	version [3]byte       // Current version of the Ledger firmware (zero if app is offline)
+	rawdata bool          // Flag whecer the user enabled arbitrary data signatures in the app
 	browser bool          // Flag whecer the Ledger is in browser mode (reply channel mismatch)
 	failure error         // Any failure that would make the device unusable
 	log     log.Logger    // Contextual logger to tag the ledger with its id
@@ -122,7 +125,7 @@ func (w *ledgerDriver) Open(device io.ReadWriter, passphrase string) error {
 		return nil
 	}
 	// Try to resolve the ecchain app's version, will fail prior to v1.0.2
-	if w.version, err = w.ledgerVersion(); err != nil {
+	if w.version, w.rawdata, err = w.ledgerVersion(); err != nil {
 		w.version = [3]byte{1, 0, 0} // Assume worst case, can't verify if v1.0.0 or v1.0.1
 	}
 	return nil
@@ -131,14 +134,14 @@ func (w *ledgerDriver) Open(device io.ReadWriter, passphrase string) error {
 // Close implements usbwallet.driver, cleaning up and metadata maintained within
 // the Ledger driver.
 func (w *ledgerDriver) Close() error {
-	w.browser, w.version = false, [3]byte{}
+	w.browser, w.version, w.rawdata = false, [3]byte{}, false
 	return nil
 }
 
 // Heartbeat implements usbwallet.driver, performing a sanity check against the
 // Ledger to see if it's still online.
 func (w *ledgerDriver) Heartbeat() error {
-	if _, err := w.ledgerVersion(); err != nil && err != errLedgerInvalidVersionReply {
+	if _, _, err := w.ledgerVersion(); err != nil && err != errLedgerInvalidVersionReply {
 		w.failure = err
 		return err
 	}
@@ -170,8 +173,29 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignHash implements usbwallet.driver, sending the hash to the Ledger and
+// waiting for the user to confirm or deny signing it.
+//
+// Note, the ecchain app only signs raw hashes if the user enabled arbitrary data
+// signatures in the app settings, otherwise an error is returned.
+func (w *ledgerDriver) SignHash(path accounts.DerivationPath, hash []byte) (common.Address, []byte, error) {
+	// If the ecchain app doesn't run, abort
+	if w.offline() {
+		return common.Address{}, nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is allowed to sign the given hash
+	if len(hash) != common.HashLength {
+		return common.Address{}, nil, fmt.Errorf("invalid hash length: have %d, want %d", len(hash), common.HashLength)
+	}
+	if !w.rawdata {
+		return common.Address{}, nil, errors.New("Ledger refuses raw hash signing, please enable arbitrary data signatures in the ecchain app settings")
+	}
+	// All infos gathered and metadata checks out, request signing
+	return w.ledgerSignHash(path, hash)
+}
+
 // ledgerVersion retrieves the current version of the ecchain wallet app running
-// on the Ledger wallet.
+// on the Ledger wallet, and whecer the user enabled arbitrary data signatures.
 //
 // The version retrieval protocol is defined as follows:
 //
@@ -187,19 +211,19 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 //   Application major version                          | 1 byte
 //   Application minor version                          | 1 byte
 //   Application patch version                          | 1 byte
-func (w *ledgerDriver) ledgerVersion() ([3]byte, error) {
+func (w *ledgerDriver) ledgerVersion() ([3]byte, bool, error) {
 	// Send the request and wait for the response
 	reply, err := w.ledgerExchange(ledgerOpGetConfiguration, 0, 0, nil)
 	if err != nil {
-		return [3]byte{}, err
+		return [3]byte{}, false, err
 	}
 	if len(reply) != 4 {
-		return [3]byte{}, errLedgerInvalidVersionReply
+		return [3]byte{}, false, errLedgerInvalidVersionReply
 	}
 	// Cache the version for future reference
 	var version [3]byte
 	copy(version[:], reply[1:])
-	return version, nil
+	return version, reply[0]&0x01 != 0, nil
 }
 
 // ledgerDerive retrieves the currently active ecchain address from a Ledger
@@ -365,6 +389,61 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// ledgerSignHash sends the raw hash to the Ledger wallet, and waits for the user
+// to confirm or deny signing it.
+//
+// The hash signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 0E  | 00 | 00 | var | 41
+//
+// Where the input data is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Hash to sign                                     | 32 bytes
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignHash(derivationPath []uint32, hash []byte) (common.Address, []byte, error) {
+	// Flatten the derivation path and the hash into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath), 1+4*len(derivationPath)+len(hash))
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	payload = append(payload, hash...)
+
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpSignHash, 0, 0, payload)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	// Extract the ecchain signature and do a sanity validation
+	if len(reply) != 65 {
+		return common.Address{}, nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	pubkey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), signature, nil
+}
+
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
 // message and retrieving the response.
 //
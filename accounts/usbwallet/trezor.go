@@ -159,6 +159,13 @@ func (w *trezorDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.trezorSign(path, tx, chainID)
 }
 
+// SignHash implements usbwallet.driver, however the Trezor wire protocol can only
+// sign prefixed messages, never raw hashes, so this method always returns an
+// error.
+func (w *trezorDriver) SignHash(path accounts.DerivationPath, hash []byte) (common.Address, []byte, error) {
+	return common.Address{}, nil, accounts.ErrNotSupported
+}
+
 // trezorDerive sends a derivation request to the Trezor device and returns the
 // ecchain address located on that path.
 func (w *trezorDriver) trezorDerive(derivationPath []uint32) (common.Address, error) {
@@ -66,6 +66,10 @@ type driver interface {
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
 	// or deny the transaction.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
+
+	// SignHash sends the raw hash to the USB device and waits for the user to
+	// confirm or deny signing it.
+	SignHash(path accounts.DerivationPath, hash []byte) (common.Address, []byte, error)
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -495,10 +499,47 @@ func (w *wallet) SelfDerive(base accounts.DerivationPath, chain ecereum.ChainSta
 	w.deriveChain = chain
 }
 
-// SignHash implements accounts.Wallet, however signing arbitrary data is not
-// supported for hardware wallets, so this method will always return an error.
+// SignHash implements accounts.Wallet. It sends the hash over to the USB wallet
+// to request a confirmation from the user. It returns either the signature or a
+// failure if the user denied signing or the device firmware cannot sign raw
+// hashes.
 func (w *wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
-	return nil, accounts.ErrNotSupported
+	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
+	defer w.stateLock.RUnlock()
+
+	// If the wallet is closed, abort
+	if w.device == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Make sure the requested account is contained within
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	// All infos gathered and metadata checks out, request signing
+	<-w.commsLock
+	defer func() { w.commsLock <- struct{}{} }()
+
+	// Ensure the device isn't screwed with while user confirmation is pending
+	// TODO(karalabe): remove if hotplug lands on Windows
+	w.hub.commsLock.Lock()
+	w.hub.commsPend++
+	w.hub.commsLock.Unlock()
+
+	defer func() {
+		w.hub.commsLock.Lock()
+		w.hub.commsPend--
+		w.hub.commsLock.Unlock()
+	}()
+	// Sign the hash and verify the signer to avoid hardware fault surprises
+	signer, signature, err := w.driver.SignHash(path, hash)
+	if err != nil {
+		return nil, err
+	}
+	if signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), signer.Hex())
+	}
+	return signature, nil
 }
 
 // SignTx implements accounts.Wallet. It sends the transaction over to the Ledger
@@ -547,9 +588,9 @@ func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	return signed, nil
 }
 
-// SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
-// data is not supported for Ledger wallets, so this method will always return
-// an error.
+// SignHashWithPassphrase implements accounts.Wallet, attempting to sign the given
+// hash with the given account using passphrase as extra authentication. Since
+// USB wallets don't rely on passphrases, these are silently ignored.
 func (w *wallet) SignHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
 	return w.SignHash(account, hash)
 }
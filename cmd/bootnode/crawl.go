@@ -0,0 +1,354 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ecereum.
+//
+// go-ecereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ecereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ecereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/cmd/utils"
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/ec"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/discv5"
+	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/rlp"
+)
+
+const (
+	crawlDialTimeout = 5 * time.Second  // Timeout of the TCP dial to a node
+	crawlProbes      = 16               // Number of nodes probed concurrently
+	crawlMaxStatus   = 10 * 1024 * 1024 // Maximum size of an ec status message
+)
+
+// crawlNode is the census entry of a node, as stored in the crawl output file.
+type crawlNode struct {
+	Enode     string       `json:"enode"`
+	FirstSeen time.Time    `json:"firstSeen"`           // First time the node was found in discovery
+	LastSeen  time.Time    `json:"lastSeen"`            // Last time the node was found in discovery
+	LastCheck time.Time    `json:"lastCheck"`           // Last time the node was probed
+	Name      string       `json:"name,omitempty"`      // Client name from the devp2p handshake
+	Caps      []string     `json:"caps,omitempty"`      // Capabilities from the devp2p handshake
+	NetworkID uint64       `json:"networkId,omitempty"` // Fields of the ec status message
+	Genesis   *common.Hash `json:"genesis,omitempty"`
+	Head      *common.Hash `json:"head,omitempty"`
+	TD        *big.Int     `json:"td,omitempty"`
+	Error     string       `json:"error,omitempty"` // Failure of the last probe
+}
+
+// ecStatus is the status message of the ec protocol, see ec/protocol.go.
+type ecStatus struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// crawler walks the discovery network, probing every node found for its client
+// name and chain status.
+type crawler struct {
+	key     *ecdsa.PrivateKey
+	lookup  func(target discover.NodeID) []*discover.Node
+	recheck time.Duration
+
+	lock  sync.Mutex
+	nodes map[string]*crawlNode
+}
+
+// runCrawler loads the census file, crawls the discovery network for the given
+// duration and writes back the updated census. Nodes which haven't been seen
+// for longer than expire are dropped, nodes probed less than recheck ago are
+// not probed again.
+func runCrawler(file string, key *ecdsa.PrivateKey, conn *net.UDPConn, realaddr *net.UDPAddr, bootnodes string, v5 bool, duration, recheck, expire time.Duration) {
+	c := &crawler{key: key, recheck: recheck, nodes: loadCensus(file)}
+
+	// Start discovery, seeding it with the bootnodes and all nodes known from
+	// earlier crawls
+	urls := params.MainnetBootnodes
+	if v5 {
+		urls = params.DiscoveryV5Bootnodes
+	}
+	if bootnodes != "" {
+		urls = strings.Split(bootnodes, ",")
+	}
+	for _, n := range c.nodes {
+		urls = append(urls, n.Enode)
+	}
+	if v5 {
+		var seeds []*discv5.Node
+		for _, url := range urls {
+			n, err := discv5.ParseNode(url)
+			if err != nil {
+				utils.Fatalf("-bootnodes: %v", err)
+			}
+			seeds = append(seeds, n)
+		}
+		ntab, err := discv5.ListenUDP(key, conn, realaddr, "", nil)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		defer ntab.Close()
+		if err := ntab.SetFallbackNodes(seeds); err != nil {
+			utils.Fatalf("%v", err)
+		}
+		c.lookup = func(target discover.NodeID) []*discover.Node {
+			var nodes []*discover.Node
+			for _, n := range ntab.Lookup(discv5.NodeID(target)) {
+				nodes = append(nodes, discover.NewNode(discover.NodeID(n.ID), n.IP, n.UDP, n.TCP))
+			}
+			return nodes
+		}
+	} else {
+		var seeds []*discover.Node
+		for _, url := range urls {
+			n, err := discover.ParseNode(url)
+			if err != nil {
+				utils.Fatalf("-bootnodes: %v", err)
+			}
+			seeds = append(seeds, n)
+		}
+		tab, err := discover.ListenUDP(conn, discover.Config{PrivateKey: key, AnnounceAddr: realaddr, Bootnodes: seeds})
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		defer tab.Close()
+		c.lookup = tab.Lookup
+	}
+	c.run(duration)
+	c.expire(expire)
+
+	writeCensus(file, c.nodes)
+	c.report()
+}
+
+// run walks the network with random lookups until the duration expires,
+// handing every newly found node to the probers.
+func (c *crawler) run(duration time.Duration) {
+	var (
+		wg     sync.WaitGroup
+		probes = make(chan *discover.Node)
+		found  = make(map[discover.NodeID]bool)
+	)
+	for i := 0; i < crawlProbes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range probes {
+				c.check(n)
+			}
+		}()
+	}
+	for deadline := time.Now().Add(duration); time.Now().Before(deadline); {
+		var target discover.NodeID
+		rand.Read(target[:])
+
+		fresh := 0
+		for _, n := range c.lookup(target) {
+			if !found[n.ID] {
+				found[n.ID] = true
+				fresh++
+				c.seen(n)
+				probes <- n
+			}
+		}
+		// Don't hammer small networks with lookups that yield nothing new
+		if fresh == 0 {
+			time.Sleep(time.Second)
+		}
+	}
+	close(probes)
+	wg.Wait()
+	log.Info("Crawl finished", "found", len(found), "known", len(c.nodes))
+}
+
+// seen records that the node was found in discovery.
+func (c *crawler) seen(n *discover.Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	entry := c.nodes[n.ID.String()]
+	if entry == nil {
+		entry = &crawlNode{FirstSeen: now}
+		c.nodes[n.ID.String()] = entry
+		log.Debug("Found new node", "id", n.ID, "addr", n.IP)
+	}
+	entry.Enode, entry.LastSeen = n.String(), now
+}
+
+// check probes the node unless it was checked recently, storing the results.
+func (c *crawler) check(n *discover.Node) {
+	c.lock.Lock()
+	entry := c.nodes[n.ID.String()]
+	recent := time.Since(entry.LastCheck) < c.recheck
+	c.lock.Unlock()
+	if recent {
+		return
+	}
+	result, err := c.probe(n)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		log.Debug("Failed to probe node", "id", n.ID, "err", err)
+		// Keep the results of earlier probes, the node might just be full
+		entry.Error = err.Error()
+	} else {
+		log.Debug("Probed node", "id", n.ID, "name", result.Name, "network", result.NetworkID)
+		result.Enode, result.FirstSeen, result.LastSeen = entry.Enode, entry.FirstSeen, entry.LastSeen
+		*entry = *result
+	}
+	entry.LastCheck = time.Now()
+}
+
+// probe connects to the node and runs the devp2p handshake, then waits for the
+// ec status message.
+func (c *crawler) probe(n *discover.Node) (*crawlNode, error) {
+	if n.TCP == 0 {
+		return nil, fmt.Errorf("no TCP endpoint")
+	}
+	fd, err := net.DialTimeout("tcp", fmt.Sprintf("%v:%d", n.IP, n.TCP), crawlDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	caps := make([]p2p.Cap, len(ec.ProtocolVersions))
+	for i, version := range ec.ProtocolVersions {
+		caps[i] = p2p.Cap{Name: ec.ProtocolName, Version: version}
+	}
+	conn, err := p2p.Probe(fd, c.key, n, common.MakeName("Crawler", params.Version), caps)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	result := &crawlNode{Name: conn.Name()}
+	for _, cap := range conn.Caps() {
+		result.Caps = append(result.Caps, cap.String())
+	}
+	// Nodes without a shared ec version close the connection right away, the
+	// handshake results are still worth keeping then
+	msg, err := conn.ReadMsg()
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer msg.Discard()
+
+	if msg.Code != ec.StatusMsg {
+		result.Error = fmt.Sprintf("first message has code %d, want status", msg.Code)
+		return result, nil
+	}
+	if msg.Size > crawlMaxStatus {
+		result.Error = fmt.Sprintf("status message too large: %d bytes", msg.Size)
+		return result, nil
+	}
+	var status ecStatus
+	if err := msg.Decode(&status); err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.NetworkID, result.TD = status.NetworkId, status.TD
+	result.Genesis, result.Head = &status.GenesisBlock, &status.CurrentBlock
+	return result, nil
+}
+
+// expire drops all nodes which haven't been found in discovery for the given
+// duration.
+func (c *crawler) expire(age time.Duration) {
+	for id, n := range c.nodes {
+		if time.Since(n.LastSeen) > age {
+			delete(c.nodes, id)
+		}
+	}
+}
+
+// report prints the number of live nodes per network and client.
+func (c *crawler) report() {
+	var (
+		networks = make(map[string]int)
+		clients  = make(map[string]int)
+	)
+	for _, n := range c.nodes {
+		if n.Genesis != nil {
+			networks[fmt.Sprintf("%d/%x", n.NetworkID, n.Genesis[:4])]++
+		}
+		if n.Name != "" {
+			clients[strings.Split(n.Name, "/")[0]]++
+		}
+	}
+	fmt.Printf("Known nodes: %d\n", len(c.nodes))
+	printCounts("Networks (id/genesis):", networks)
+	printCounts("Clients:", clients)
+}
+
+func printCounts(title string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+
+	fmt.Println(title)
+	for _, key := range keys {
+		fmt.Printf("  %-24s %d\n", key, counts[key])
+	}
+}
+
+// loadCensus reads the census file, returning an empty census if it doesn't
+// exist yet.
+func loadCensus(file string) map[string]*crawlNode {
+	nodes := make(map[string]*crawlNode)
+	blob, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nodes
+	}
+	if err != nil {
+		utils.Fatalf("-crawl: %v", err)
+	}
+	if err := json.Unmarshal(blob, &nodes); err != nil {
+		utils.Fatalf("-crawl: invalid census %s: %v", file, err)
+	}
+	return nodes
+}
+
+// writeCensus stores the census as indented JSON.
+func writeCensus(file string, nodes map[string]*crawlNode) {
+	blob, err := json.MarshalIndent(nodes, "", "  ")
+	if err != nil {
+		utils.Fatalf("-crawl: %v", err)
+	}
+	if err := ioutil.WriteFile(file, append(blob, '\n'), 0644); err != nil {
+		utils.Fatalf("-crawl: %v", err)
+	}
+}
@@ -14,7 +14,8 @@
 // You should have received a copy of the GNU General Public License
 // along with go-ecereum. If not, see <http://www.gnu.org/licenses/>.
 
-// bootnode runs a booecrap node for the ecchain Discovery Protocol.
+// bootnode runs a booecrap node for the ecchain Discovery Protocol, or crawls
+// the network to take a census of its nodes.
 package main
 
 import (
@@ -23,6 +24,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/ecchain/go-ecchain/cmd/utils"
 	"github.com/ecchain/go-ecchain/crypto"
@@ -46,6 +48,12 @@ func main() {
 		verbosity   = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
 		vmodule     = flag.String("vmodule", "", "log verbosity pattern")
 
+		crawlFile    = flag.String("crawl", "", "crawl the network, storing the node census in the given JSON file")
+		crawlTime    = flag.Duration("crawltime", 30*time.Minute, "time to spend crawling the network")
+		crawlRecheck = flag.Duration("crawlrecheck", time.Hour, "minimum time between two probes of a node")
+		crawlExpire  = flag.Duration("crawlexpire", 7*24*time.Hour, "time after which unseen nodes are dropped from the census")
+		bootnodes    = flag.String("bootnodes", "", "comma separated enode URLs to start crawling from")
+
 		nodeKey *ecdsa.PrivateKey
 		err     error
 	)
@@ -70,6 +78,11 @@ func main() {
 			utils.Fatalf("%v", err)
 		}
 		return
+	case *crawlFile != "" && *nodeKeyFile == "" && *nodeKeyHex == "":
+		// Crawling doesn't need a persistent identity
+		if nodeKey, err = crypto.GenerateKey(); err != nil {
+			utils.Fatalf("could not generate key: %v", err)
+		}
 	case *nodeKeyFile == "" && *nodeKeyHex == "":
 		utils.Fatalf("Use -nodekey or -nodekeyhex to specify a private key")
 	case *nodeKeyFile != "" && *nodeKeyHex != "":
@@ -117,6 +130,10 @@ func main() {
 		}
 	}
 
+	if *crawlFile != "" {
+		runCrawler(*crawlFile, nodeKey, conn, realaddr, *bootnodes, *runv5, *crawlTime, *crawlRecheck, *crawlExpire)
+		return
+	}
 	if *runv5 {
 		if _, err := discv5.ListenUDP(nodeKey, conn, realaddr, "", restrictList); err != nil {
 			utils.Fatalf("%v", err)
@@ -0,0 +1,354 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ecereum.
+//
+// go-ecereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ecereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ecereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/cmd/utils"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/dnsdisc"
+	"github.com/ecchain/go-ecchain/p2p/enr"
+	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/rlp"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dnsCrawlTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time to spend walking the discovery network",
+		Value: 30 * time.Minute,
+	}
+	dnsCrawlListenFlag = cli.StringFlag{
+		Name:  "listen",
+		Usage: "UDP address to run discovery on",
+		Value: "0.0.0.0:0",
+	}
+	dnsDomainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "Domain name the tree is published under",
+	}
+	dnsSeqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "Sequence number of the tree, must increase on every update",
+		Value: 1,
+	}
+	dnsMaxAgeFlag = cli.DurationFlag{
+		Name:  "maxage",
+		Usage: "Drop nodes not seen by a crawl for longer than this (0 = keep all)",
+		Value: 24 * time.Hour,
+	}
+	dnsLinkFlag = cli.StringFlag{
+		Name:  "link",
+		Usage: "Comma separated enrtree:// URLs of other trees to link to",
+	}
+	dnsOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the TXT records to",
+		Value: "txt.json",
+	}
+	dnsCommand = cli.Command{
+		Name:     "dns",
+		Usage:    "Generate and verify DNS node lists",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The dns commands crawl the discovery network for node records, publish them as
+signed DNS node lists (EIP-1459), and verify published lists.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "crawl",
+				Usage:     "Collect the node records of the discovery network",
+				ArgsUsage: "<nodes.json>",
+				Action:    utils.MigrateFlags(dnsCrawl),
+				Flags: []cli.Flag{
+					utils.BootnodesFlag,
+					dnsCrawlTimeoutFlag,
+					dnsCrawlListenFlag,
+				},
+				Description: `
+    gec dns crawl nodes.json
+
+walks the discovery network and stores the records of all responsive nodes in
+the given file. Nodes already in the file are updated, so repeated crawls build
+on each other.`,
+			},
+			{
+				Name:      "sign",
+				Usage:     "Create a signed tree of node records",
+				ArgsUsage: "<nodes.json> <keyfile>",
+				Action:    utils.MigrateFlags(dnsSign),
+				Flags: []cli.Flag{
+					dnsDomainFlag,
+					dnsSeqFlag,
+					dnsMaxAgeFlag,
+					dnsLinkFlag,
+					dnsOutputFlag,
+				},
+				Description: `
+    gec dns sign --domain nodes.example.org nodes.json tree.key
+
+creates the tree of the crawled node records, signs it with the private key in
+the given key file and writes the TXT records to publish to the output file.
+Nodes not seen by a crawl within --maxage are left out of the tree.`,
+			},
+			{
+				Name:      "sync",
+				Usage:     "Download and verify a published tree",
+				ArgsUsage: "<enrtree-url> [nodes.json]",
+				Action:    utils.MigrateFlags(dnsSync),
+				Description: `
+    gec dns sync enrtree://<key>@nodes.example.org
+
+resolves the tree at the given URL and verifies all its entries, optionally
+storing the contained node records in the given file.`,
+			},
+		},
+	}
+)
+
+// dnsNode is a crawled node record as stored in the node set file.
+type dnsNode struct {
+	Seq      uint64    `json:"seq"`
+	Record   string    `json:"record"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// dnsCrawl walks the discovery network, collecting the node records of all
+// nodes found.
+func dnsCrawl(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	output := ctx.Args().First()
+	nodes := loadDNSNodes(output)
+
+	urls := params.MainnetBootnodes
+	if ctx.IsSet(utils.BootnodesFlag.Name) {
+		urls = strings.Split(ctx.String(utils.BootnodesFlag.Name), ",")
+	}
+	var bootnodes []*discover.Node
+	for _, url := range urls {
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			utils.Fatalf("Bootstrap URL invalid: %v", err)
+		}
+		bootnodes = append(bootnodes, node)
+	}
+	addr, err := net.ResolveUDPAddr("udp", ctx.String(dnsCrawlListenFlag.Name))
+	if err != nil {
+		utils.Fatalf("Invalid listen address: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		utils.Fatalf("Failed to listen: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		utils.Fatalf("Failed to generate node key: %v", err)
+	}
+	tab, err := discover.ListenUDP(conn, discover.Config{PrivateKey: key, Bootnodes: bootnodes})
+	if err != nil {
+		utils.Fatalf("Failed to start discovery: %v", err)
+	}
+	defer tab.Close()
+
+	// Walk the network with random lookups until the timeout expires
+	found := make(map[discover.NodeID]*discover.Node)
+	for deadline := time.Now().Add(ctx.Duration(dnsCrawlTimeoutFlag.Name)); time.Now().Before(deadline); {
+		var target discover.NodeID
+		rand.Read(target[:])
+		for _, n := range tab.Lookup(target) {
+			if _, ok := found[n.ID]; !ok {
+				found[n.ID] = n
+				log.Info("Found node", "id", n.ID, "found", len(found))
+			}
+		}
+	}
+	// Retrieve the records of all nodes found, a few at a time
+	var (
+		lock  sync.Mutex
+		wg    sync.WaitGroup
+		slots = make(chan struct{}, 16)
+		now   = time.Now()
+	)
+	for _, n := range found {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(n *discover.Node) {
+			defer func() { <-slots; wg.Done() }()
+
+			record, err := tab.NodeRecord(n)
+			if err != nil {
+				log.Debug("Failed to retrieve node record", "id", n.ID, "err", err)
+				return
+			}
+			lock.Lock()
+			nodes[n.ID.String()] = dnsNode{Seq: record.Seq(), Record: encodeRecord(record), LastSeen: now}
+			lock.Unlock()
+		}(n)
+	}
+	wg.Wait()
+
+	writeJSON(output, nodes)
+	fmt.Printf("Crawled %d nodes, %d records stored\n", len(found), len(nodes))
+	return nil
+}
+
+// dnsSign creates and signs a tree of the node records in the node set file.
+func dnsSign(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	domain := ctx.String(dnsDomainFlag.Name)
+	if domain == "" {
+		utils.Fatalf("Tree domain missing (--%s)", dnsDomainFlag.Name)
+	}
+	nodes := loadDNSNodes(ctx.Args().Get(0))
+	key, err := crypto.LoadECDSA(ctx.Args().Get(1))
+	if err != nil {
+		utils.Fatalf("Failed to load signing key: %v", err)
+	}
+	var (
+		maxage  = ctx.Duration(dnsMaxAgeFlag.Name)
+		records = make([]*enr.Record, 0, len(nodes))
+		stale   int
+	)
+	for id, n := range nodes {
+		if maxage > 0 && time.Since(n.LastSeen) > maxage {
+			log.Debug("Dropping stale node", "id", id, "seen", n.LastSeen)
+			stale++
+			continue
+		}
+		record, err := decodeRecord(n.Record)
+		if err != nil {
+			utils.Fatalf("Invalid record of node %s: %v", id, err)
+		}
+		records = append(records, record)
+	}
+	var links []string
+	if ctx.IsSet(dnsLinkFlag.Name) {
+		links = strings.Split(ctx.String(dnsLinkFlag.Name), ",")
+	}
+	tree, err := dnsdisc.MakeTree(ctx.Uint(dnsSeqFlag.Name), records, links)
+	if err != nil {
+		utils.Fatalf("Failed to create tree: %v", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		utils.Fatalf("Failed to sign tree: %v", err)
+	}
+	writeJSON(ctx.String(dnsOutputFlag.Name), tree.ToTXT(domain))
+	fmt.Printf("Signed tree of %d nodes (%d stale dropped): %s\n", len(records), stale, url)
+	return nil
+}
+
+// dnsSync downloads and verifies a published tree.
+func dnsSync(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	client := dnsdisc.NewClient(dnsdisc.Config{})
+	tree, err := client.SyncTree(ctx.Args().Get(0))
+	if err != nil {
+		utils.Fatalf("Failed to sync tree: %v", err)
+	}
+	records := tree.Nodes()
+	fmt.Printf("Tree seq %d: %d nodes, links %v\n", tree.Seq(), len(records), tree.Links())
+
+	if output := ctx.Args().Get(1); output != "" {
+		var (
+			nodes = make(map[string]dnsNode)
+			now   = time.Now()
+		)
+		for _, record := range records {
+			var pubkey enr.Secp256k1
+			if err := record.Load(&pubkey); err != nil {
+				continue
+			}
+			id := discover.PubkeyID((*ecdsa.PublicKey)(&pubkey))
+			nodes[id.String()] = dnsNode{Seq: record.Seq(), Record: encodeRecord(record), LastSeen: now}
+		}
+		writeJSON(output, nodes)
+	}
+	return nil
+}
+
+// loadDNSNodes reads a node set file, returning an empty set if it doesn't
+// exist yet.
+func loadDNSNodes(file string) map[string]dnsNode {
+	nodes := make(map[string]dnsNode)
+	blob, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nodes
+	}
+	if err != nil {
+		utils.Fatalf("Failed to read node set: %v", err)
+	}
+	if err := json.Unmarshal(blob, &nodes); err != nil {
+		utils.Fatalf("Invalid node set %s: %v", file, err)
+	}
+	return nodes
+}
+
+// writeJSON stores the given value as indented JSON.
+func writeJSON(file string, value interface{}) {
+	blob, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode %s: %v", file, err)
+	}
+	if err := ioutil.WriteFile(file, append(blob, '\n'), 0644); err != nil {
+		utils.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
+
+// encodeRecord returns the text representation of a node record.
+func encodeRecord(record *enr.Record) string {
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		utils.Fatalf("Failed to encode node record: %v", err)
+	}
+	return "enr:" + base64.RawURLEncoding.EncodeToString(blob)
+}
+
+// decodeRecord parses the text representation of a node record.
+func decodeRecord(text string) (*enr.Record, error) {
+	if !strings.HasPrefix(text, "enr:") {
+		return nil, fmt.Errorf("missing 'enr:' prefix")
+	}
+	blob, err := base64.RawURLEncoding.DecodeString(text[4:])
+	if err != nil {
+		return nil, err
+	}
+	record := new(enr.Record)
+	if err := rlp.DecodeBytes(blob, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/metrics"
 	"github.com/ecchain/go-ecchain/node"
+	"github.com/ecchain/go-ecchain/tracing"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -61,6 +62,7 @@ var (
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
+		utils.ExternalSignerFlag,
 		utils.DashboardEnabledFlag,
 		utils.DashboardAddrFlag,
 		utils.DashboardPortFlag,
@@ -69,6 +71,7 @@ var (
 		utils.ecashCacheDirFlag,
 		utils.ecashCachesInMemoryFlag,
 		utils.ecashCachesOnDiskFlag,
+		utils.ecashCacheServerFlag,
 		utils.ecashDatasetDirFlag,
 		utils.ecashDatasetsInMemoryFlag,
 		utils.ecashDatasetsOnDiskFlag,
@@ -104,11 +107,14 @@ var (
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DNSDiscoveryFlag,
 		utils.NetrestrictFlag,
+		utils.EgressLimitFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
+		utils.DeveloperManualFlag,
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
@@ -117,11 +123,23 @@ var (
 		utils.RPCVirtualHostsFlag,
 		utils.ecStatsURLFlag,
 		utils.MetricsEnabledFlag,
+		utils.MetricsHTTPFlag,
+		utils.MetricsPortFlag,
+		utils.TracingEnabledFlag,
+		utils.TracingEndpointFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
 		utils.GpoPercentileFlag,
 		utils.ExtraDataFlag,
+		utils.MinerRecommitIntervalFlag,
+		utils.MinerGasTargetFlag,
+		utils.MinerGasLimitFlag,
+		utils.MinerBuilderFlag,
+		utils.MinerReservedGasFlag,
+		utils.MinerReservedSendersFlag,
+		utils.MinerStratumFlag,
+		utils.MinerStratumDifficultyFlag,
 		configFileFlag,
 	}
 
@@ -130,6 +148,9 @@ var (
 		utils.RPCListenAddrFlag,
 		utils.RPCPortFlag,
 		utils.RPCApiFlag,
+		utils.HealthMinPeersFlag,
+		utils.HealthMaxBlocksBehindFlag,
+		utils.HealthMaxBlockAgeFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
@@ -170,7 +191,10 @@ func init() {
 		javascriptCommand,
 		// See misccmd.go:
 		makecacheCommand,
+		cacheserverCommand,
 		makedagCommand,
+		// See dnscmd.go:
+		dnsCommand,
 		versionCommand,
 		bugCommand,
 		licenseCommand,
@@ -192,12 +216,13 @@ func init() {
 		}
 		// Start system runtime metrics collection
 		go metrics.CollectProcessMetrics(3 * time.Second)
-
-		utils.SetupNetwork(ctx)
+		utils.SetupMetrics(ctx)
+		utils.SetupTracing(ctx)
 		return nil
 	}
 
 	app.After = func(ctx *cli.Context) error {
+		tracing.Stop()
 		debug.Exit()
 		console.Stdin.Close() // Resets terminal mode.
 		return nil
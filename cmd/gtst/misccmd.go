@@ -19,9 +19,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/ecchain/go-ecchain/cmd/utils"
 	"github.com/ecchain/go-ecchain/consensus/ethash"
@@ -42,6 +44,23 @@ The makecache command generates an ethash cache in <outputDir>.
 
 This command exists to support the system testing project.
 Regular users do not need to execute it.
+`,
+	}
+	cacheserverCommand = cli.Command{
+		Action:    utils.MigrateFlags(cacheserver),
+		Name:      "cacheserver",
+		Usage:     "Serve ethash verification caches to the local nodes",
+		ArgsUsage: "<socket> <cacheDir>",
+		Flags: []cli.Flag{
+			utils.ecashCachesInMemoryFlag,
+			utils.ecashCachesOnDiskFlag,
+		},
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The cacheserver command generates the ethash verification caches into <cacheDir>
+and shares them with all the nodes pointed to <socket> via --ethash.cacheserver.
+The nodes memory map the shared files instead of generating their own copies,
+falling back to local generation whenever the server is unreachable.
 `,
 	}
 	makedagCommand = cli.Command{
@@ -91,6 +110,33 @@ func makecache(ctx *cli.Context) error {
 	return nil
 }
 
+// cacheserver serves ethash verification caches over a local socket until
+// interrupted.
+func cacheserver(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		utils.Fatalf(`Usage: gec cacheserver <socket> <cachedir>`)
+	}
+	server, err := ethash.NewCacheServer(ethash.Config{
+		CacheDir:     args[1],
+		CachesInMem:  ctx.Int(utils.ecashCachesInMemoryFlag.Name),
+		CachesOnDisk: ctx.Int(utils.ecashCachesOnDiskFlag.Name),
+	})
+	if err != nil {
+		utils.Fatalf("Failed to create cache server: %v", err)
+	}
+	if err := server.Start(args[0]); err != nil {
+		utils.Fatalf("Failed to start cache server: %v", err)
+	}
+	defer server.Stop()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+
+	return nil
+}
+
 // makedag generates an ethash mining DAG into the provided folder.
 func makedag(ctx *cli.Context) error {
 	args := ctx.Args()
@@ -71,6 +71,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.DataDirFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
+			utils.ExternalSignerFlag,
 			utils.NetworkIdFlag,
 			utils.TestnetFlag,
 			utils.RinkebyFlag,
@@ -87,6 +88,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.DeveloperFlag,
 			utils.DeveloperPeriodFlag,
+			utils.DeveloperManualFlag,
 		},
 	},
 	{
@@ -95,6 +97,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.ecashCacheDirFlag,
 			utils.ecashCachesInMemoryFlag,
 			utils.ecashCachesOnDiskFlag,
+			utils.ecashCacheServerFlag,
 			utils.ecashDatasetDirFlag,
 			utils.ecashDatasetsInMemoryFlag,
 			utils.ecashDatasetsOnDiskFlag,
@@ -148,6 +151,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.RPCListenAddrFlag,
 			utils.RPCPortFlag,
 			utils.RPCApiFlag,
+			utils.HealthMinPeersFlag,
+			utils.HealthMaxBlocksBehindFlag,
+			utils.HealthMaxBlockAgeFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
@@ -174,7 +180,9 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DNSDiscoveryFlag,
 			utils.NetrestrictFlag,
+			utils.EgressLimitFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
 		},
@@ -188,6 +196,14 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerRecommitIntervalFlag,
+			utils.MinerGasTargetFlag,
+			utils.MinerGasLimitFlag,
+			utils.MinerBuilderFlag,
+			utils.MinerReservedGasFlag,
+			utils.MinerReservedSendersFlag,
+			utils.MinerStratumFlag,
+			utils.MinerStratumDifficultyFlag,
 		},
 	},
 	{
@@ -207,6 +223,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "LOGGING AND DEBUGGING",
 		Flags: append([]cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsHTTPFlag,
+			utils.MetricsPortFlag,
+			utils.TracingEnabledFlag,
+			utils.TracingEndpointFlag,
 			utils.FakePoWFlag,
 			utils.NoCompactionFlag,
 		}, debug.Flags...),
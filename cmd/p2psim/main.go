@@ -34,6 +34,11 @@
 //     $ p2psim node connect node01 node02
 //     Connected node01 to node02
 //
+// Scripted experiments can be run from a JSON scenario file, printing a
+// pass/fail report (see simulations.Scenario for the format):
+//
+//     $ p2psim run scenario.json
+//
 package main
 
 import (
@@ -103,6 +108,19 @@ func main() {
 			Usage:  "load a network snapshot from stdin",
 			Action: loadSnapshot,
 		},
+		{
+			Name:      "run",
+			ArgsUsage: "<scenario.json>",
+			Usage:     "run a simulation scenario",
+			Action:    runScenario,
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 0,
+					Usage: "abort the scenario after the given duration (0 = no limit)",
+				},
+			},
+		},
 		{
 			Name:   "node",
 			Usage:  "manage simulation nodes",
@@ -180,7 +198,10 @@ func main() {
 			},
 		},
 	}
-	app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
 
 func showNetwork(ctx *cli.Context) error {
@@ -246,6 +267,29 @@ func loadSnapshot(ctx *cli.Context) error {
 	return client.LoadSnapshot(snap)
 }
 
+func runScenario(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	scenario, err := simulations.LoadScenario(args[0])
+	if err != nil {
+		return err
+	}
+	runCtx := context.Background()
+	if timeout := ctx.Duration("timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, timeout)
+		defer cancel()
+	}
+	report := scenario.Run(runCtx, client)
+	report.Write(ctx.App.Writer)
+	if !report.Passed {
+		return fmt.Errorf("scenario %q failed", scenario.Name)
+	}
+	return nil
+}
+
 func listNodes(ctx *cli.Context) error {
 	if len(ctx.Args()) != 0 {
 		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -33,7 +34,9 @@ import (
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/fdlimit"
 	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/consensus/bft"
 	"github.com/ecchain/go-ecchain/consensus/clique"
+	"github.com/ecchain/go-ecchain/consensus/dev"
 	"github.com/ecchain/go-ecchain/consensus/ethash"
 	"github.com/ecchain/go-ecchain/core"
 	"github.com/ecchain/go-ecchain/core/state"
@@ -48,6 +51,7 @@ import (
 	"github.com/ecchain/go-ecchain/les"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/metrics"
+	"github.com/ecchain/go-ecchain/metrics/exp"
 	"github.com/ecchain/go-ecchain/node"
 	"github.com/ecchain/go-ecchain/p2p"
 	"github.com/ecchain/go-ecchain/p2p/discover"
@@ -55,6 +59,7 @@ import (
 	"github.com/ecchain/go-ecchain/p2p/nat"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/tracing"
 	whisper "github.com/ecchain/go-ecchain/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -126,6 +131,10 @@ var (
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
 	}
+	ExternalSignerFlag = cli.StringFlag{
+		Name:  "signer",
+		Usage: "External signer (IPC path or url of the remote signer)",
+	}
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:  "networkid",
 		Usage: "Network identifier (integer, 1=Frontier, 2=Morden (disused), 3=Ropsten, 4=Rinkeby)",
@@ -141,11 +150,15 @@ var (
 	}
 	DeveloperFlag = cli.BoolFlag{
 		Name:  "dev",
-		Usage: "Ephemeral proof-of-authority network with a pre-funded developer account, mining enabled",
+		Usage: "Ephemeral instant sealing network with a pre-funded developer account, mining enabled",
 	}
 	DeveloperPeriodFlag = cli.IntFlag{
 		Name:  "dev.period",
-		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
+		Usage: "Seconds after which empty blocks are sealed in developer mode (0 = mine only if transaction pending)",
+	}
+	DeveloperManualFlag = cli.BoolFlag{
+		Name:  "dev.manual",
+		Usage: "Seal blocks in developer mode only when requested via dev_mine",
 	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
@@ -229,6 +242,10 @@ var (
 		Usage: "Number of recent ethash caches to keep on disk (16MB each)",
 		Value: ec.DefaultConfig.ecash.CachesOnDisk,
 	}
+	ecashCacheServerFlag = cli.StringFlag{
+		Name:  "ethash.cacheserver",
+		Usage: "Socket of a shared ethash cache server to map the verification caches from (see 'cacheserver')",
+	}
 	ecashDatasetDirFlag = DirectoryFlag{
 		Name:  "ethash.dagdir",
 		Usage: "Directory to store the ethash mining DAGs (default = inside home folder)",
@@ -327,8 +344,8 @@ var (
 	}
 	TargetGasLimitFlag = cli.Uint64Flag{
 		Name:  "targetgaslimit",
-		Usage: "Target gas limit sets the artificial target gas floor for the blocks to mine",
-		Value: params.GenesisGasLimit,
+		Usage: "Target gas limit sets the artificial target gas floor for the blocks to mine (deprecated, use --miner.gastarget)",
+		Value: ec.DefaultConfig.MinerGasTarget,
 	}
 	ecerbaseFlag = cli.StringFlag{
 		Name:  "ecerbase",
@@ -344,6 +361,41 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerRecommitIntervalFlag = cli.DurationFlag{
+		Name:  "miner.recommit",
+		Usage: "Time interval to recreate the block being mined (0 = disabled)",
+		Value: ec.DefaultConfig.MinerRecommit,
+	}
+	MinerGasTargetFlag = cli.Uint64Flag{
+		Name:  "miner.gastarget",
+		Usage: "Target gas limit the mined blocks are raised towards",
+		Value: ec.DefaultConfig.MinerGasTarget,
+	}
+	MinerGasLimitFlag = cli.Uint64Flag{
+		Name:  "miner.gaslimit",
+		Usage: "Maximum gas limit the mined blocks are lowered towards (0 = uncapped)",
+	}
+	MinerBuilderFlag = cli.StringFlag{
+		Name:  "miner.builder",
+		Usage: "Block building strategy (price, fifo, profit)",
+		Value: "price",
+	}
+	MinerReservedGasFlag = cli.Uint64Flag{
+		Name:  "miner.reservedgas",
+		Usage: "Block gas reserved for the transactions of the local senders",
+	}
+	MinerReservedSendersFlag = cli.StringFlag{
+		Name:  "miner.reservedsenders",
+		Usage: "Comma separated list of local senders the reserved block gas is available to",
+	}
+	MinerStratumFlag = cli.StringFlag{
+		Name:  "miner.stratum",
+		Usage: "Listening address of the stratum server for external miners (e.g. 127.0.0.1:8008)",
+	}
+	MinerStratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "miner.stratumdiff",
+		Usage: "Share difficulty of stratum miners (default = block difficulty)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -369,6 +421,29 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	// MetricsHTTPFlag defines the endpoint for a stand-alone metrics HTTP endpoint.
+	// Since the pprof service enables sensitive/vulnerable behavior, this allows a user
+	// to enable a public-OK metrics endpoint without having to worry about ALSO exposing
+	// other profiling behavior or information.
+	MetricsHTTPFlag = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Enable stand-alone metrics HTTP server listening interface (expvar and Prometheus)",
+		Value: "",
+	}
+	MetricsPortFlag = cli.IntFlag{
+		Name:  "metrics.port",
+		Usage: "Metrics HTTP server listening port",
+		Value: 6061,
+	}
+	TracingEnabledFlag = cli.BoolFlag{
+		Name:  "tracing",
+		Usage: "Enable span tracing of RPC calls, block imports, state and database reads",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "Destination of the OTLP/JSON spans: file path or collector URL (http://host:4318)",
+		Value: "",
+	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
 		Usage: "Disables proof-of-work verification",
@@ -402,6 +477,21 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.HTTPVirtualHosts, ","),
 	}
+	HealthMinPeersFlag = cli.IntFlag{
+		Name:  "health.minpeers",
+		Usage: "Minimum number of connected peers for the /ready probe to succeed",
+		Value: node.DefaultConfig.Health.MinPeers,
+	}
+	HealthMaxBlocksBehindFlag = cli.Uint64Flag{
+		Name:  "health.maxblocksbehind",
+		Usage: "Maximum number of blocks behind the highest known block for the /ready probe to succeed",
+		Value: node.DefaultConfig.Health.MaxBlocksBehind,
+	}
+	HealthMaxBlockAgeFlag = cli.DurationFlag{
+		Name:  "health.maxblockage",
+		Usage: "Maximum age of the head block for the /ready probe to succeed (0 = disabled)",
+		Value: node.DefaultConfig.Health.MaxBlockAge,
+	}
 	RPCApiFlag = cli.StringFlag{
 		Name:  "rpcapi",
 		Usage: "API's offered over the HTTP-RPC interface",
@@ -500,10 +590,20 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to dial peers from",
+		Value: "",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	EgressLimitFlag = cli.StringFlag{
+		Name:  "egresslimit",
+		Usage: "Comma separated protocol=bytes/s outbound rate limits shared by all peers (e.g. les=1048576)",
+		Value: "",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -698,6 +798,15 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(RPCVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = splitAndTrim(ctx.GlobalString(RPCVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+		cfg.Health.MinPeers = ctx.GlobalInt(HealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxBlocksBehindFlag.Name) {
+		cfg.Health.MaxBlocksBehind = ctx.GlobalUint64(HealthMaxBlocksBehindFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxBlockAgeFlag.Name) {
+		cfg.Health.MaxBlockAge = ctx.GlobalDuration(HealthMaxBlockAgeFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -776,6 +885,10 @@ func MakeAddress(ks *keystore.KeyStore, account string) (accounts.Account, error
 	return accs[index], nil
 }
 
+// developerKey is the deterministic key of the account pre-funded in developer
+// mode, so test suites can rely on its address and sign with it offline.
+var developerKey, _ = crypto.ToECDSA(crypto.Keccak256([]byte("developer")))
+
 // setecerbase retrieves the ecerbase either from the directly specified
 // command line flags or from the keystore if CLI indexed.
 func setecerbase(ctx *cli.Context, ks *keystore.KeyStore, cfg *ec.Config) {
@@ -853,6 +966,10 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.DiscoveryV5 = true
 	}
 
+	if urls := ctx.GlobalString(DNSDiscoveryFlag.Name); urls != "" {
+		cfg.DNSDiscovery = strings.Split(urls, ",")
+	}
+
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
 		if err != nil {
@@ -861,12 +978,30 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.NetRestrict = list
 	}
 
+	if limits := ctx.GlobalString(EgressLimitFlag.Name); limits != "" {
+		if cfg.EgressLimits == nil {
+			cfg.EgressLimits = make(map[string]int)
+		}
+		for _, limit := range strings.Split(limits, ",") {
+			parts := strings.SplitN(limit, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				Fatalf("Option %q: invalid limit %q, want protocol=bytes", EgressLimitFlag.Name, limit)
+			}
+			rate, err := strconv.Atoi(parts[1])
+			if err != nil || rate < 0 {
+				Fatalf("Option %q: invalid rate of %s: %q", EgressLimitFlag.Name, parts[0], parts[1])
+			}
+			cfg.EgressLimits[parts[0]] = rate
+		}
+	}
+
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
 		cfg.MaxPeers = 0
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DNSDiscovery = nil
 	}
 }
 
@@ -898,6 +1033,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(NoUSBFlag.Name) {
 		cfg.NoUSB = ctx.GlobalBool(NoUSBFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
+		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
+	}
 }
 
 func setGPO(ctx *cli.Context, cfg *gasprice.Config) {
@@ -946,6 +1084,9 @@ func setecash(ctx *cli.Context, cfg *ec.Config) {
 	if ctx.GlobalIsSet(ecashCacheDirFlag.Name) {
 		cfg.ecash.CacheDir = ctx.GlobalString(ecashCacheDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(ecashCacheServerFlag.Name) {
+		cfg.ecash.CacheServer = ctx.GlobalString(ecashCacheServerFlag.Name)
+	}
 	if ctx.GlobalIsSet(ecashDatasetDirFlag.Name) {
 		cfg.ecash.DatasetDir = ctx.GlobalString(ecashDatasetDirFlag.Name)
 	}
@@ -1065,6 +1206,39 @@ func SetecConfig(ctx *cli.Context, stack *node.Node, cfg *ec.Config) {
 	if ctx.GlobalIsSet(ExtraDataFlag.Name) {
 		cfg.ExtraData = []byte(ctx.GlobalString(ExtraDataFlag.Name))
 	}
+	if ctx.GlobalIsSet(MinerRecommitIntervalFlag.Name) {
+		cfg.MinerRecommit = ctx.GlobalDuration(MinerRecommitIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(TargetGasLimitFlag.Name) {
+		cfg.MinerGasTarget = ctx.GlobalUint64(TargetGasLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerGasTargetFlag.Name) {
+		cfg.MinerGasTarget = ctx.GlobalUint64(MinerGasTargetFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerGasLimitFlag.Name) {
+		cfg.MinerGasLimit = ctx.GlobalUint64(MinerGasLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerBuilderFlag.Name) {
+		cfg.MinerBuilder = ctx.GlobalString(MinerBuilderFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerReservedGasFlag.Name) {
+		cfg.MinerReservedGas = ctx.GlobalUint64(MinerReservedGasFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerReservedSendersFlag.Name) {
+		for _, sender := range strings.Split(ctx.GlobalString(MinerReservedSendersFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(sender); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid reserved sender: %s", trimmed)
+			} else {
+				cfg.MinerReservedSenders = append(cfg.MinerReservedSenders, common.HexToAddress(trimmed))
+			}
+		}
+	}
+	if ctx.GlobalIsSet(MinerStratumFlag.Name) {
+		cfg.StratumAddr = ctx.GlobalString(MinerStratumFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerStratumDifficultyFlag.Name) {
+		cfg.StratumDifficulty = ctx.GlobalUint64(MinerStratumDifficultyFlag.Name)
+	}
 	if ctx.GlobalIsSet(GasPriceFlag.Name) {
 		cfg.GasPrice = GlobalBig(ctx, GasPriceFlag.Name)
 	}
@@ -1086,25 +1260,23 @@ func SetecConfig(ctx *cli.Context, stack *node.Node, cfg *ec.Config) {
 		}
 		cfg.Genesis = core.DefaultRinkebyGenesisBlock()
 	case ctx.GlobalBool(DeveloperFlag.Name):
-		// Create new developer account or reuse existing one
-		var (
-			developer accounts.Account
-			err       error
-		)
-		if accs := ks.Accounts(); len(accs) > 0 {
-			developer = ks.Accounts()[0]
-		} else {
-			developer, err = ks.NewAccount("")
-			if err != nil {
-				Fatalf("Failed to create developer account: %v", err)
+		// Import the deterministic developer account unless already present
+		developer := accounts.Account{Address: crypto.PubkeyToAddress(developerKey.PublicKey)}
+		if !ks.HasAddress(developer.Address) {
+			if _, err := ks.ImportECDSA(developerKey, ""); err != nil {
+				Fatalf("Failed to import developer account: %v", err)
 			}
 		}
 		if err := ks.Unlock(developer, ""); err != nil {
 			Fatalf("Failed to unlock developer account: %v", err)
 		}
-		log.Info("Using developer account", "address", developer.Address)
+		log.Info("Using developer account", "address", developer.Address, "key", hex.EncodeToString(crypto.FromECDSA(developerKey)))
 
-		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		period := uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name))
+		cfg.Genesis = core.DeveloperGenesisBlock(period, ctx.GlobalBool(DeveloperManualFlag.Name), developer.Address)
+		if !ctx.GlobalIsSet(ecerbaseFlag.Name) {
+			cfg.ecerbase = developer.Address
+		}
 		if !ctx.GlobalIsSet(GasPriceFlag.Name) {
 			cfg.GasPrice = big.NewInt(1)
 		}
@@ -1178,10 +1350,30 @@ func RegisterecStatsService(stack *node.Node, url string) {
 	}
 }
 
-// SetupNetwork configures the system for either the main net or some test network.
-func SetupNetwork(ctx *cli.Context) {
-	// TODO(fjl): move target gas limit into config
-	params.TargetGasLimit = ctx.GlobalUint64(TargetGasLimitFlag.Name)
+// SetupMetrics starts the stand-alone metrics HTTP server if metrics collection
+// is enabled and a listening interface was requested.
+func SetupMetrics(ctx *cli.Context) {
+	if metrics.Enabled && ctx.GlobalIsSet(MetricsHTTPFlag.Name) {
+		address := fmt.Sprintf("%s:%d", ctx.GlobalString(MetricsHTTPFlag.Name), ctx.GlobalInt(MetricsPortFlag.Name))
+		exp.Setup(address)
+	}
+}
+
+// SetupTracing starts exporting trace spans if requested on the command line.
+func SetupTracing(ctx *cli.Context) {
+	if !ctx.GlobalBool(TracingEnabledFlag.Name) {
+		return
+	}
+	endpoint := ctx.GlobalString(TracingEndpointFlag.Name)
+	if endpoint == "" {
+		Fatalf("Tracing requires an export destination (--%s)", TracingEndpointFlag.Name)
+	}
+	exporter, err := tracing.NewExporter(endpoint, ctx.App.Name)
+	if err != nil {
+		Fatalf("Failed to create trace exporter: %v", err)
+	}
+	log.Info("Enabling span tracing", "endpoint", endpoint)
+	tracing.Start(exporter)
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
@@ -1226,6 +1418,10 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var engine consensus.Engine
 	if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
+	} else if config.BFT != nil {
+		engine = bft.New(config.BFT, nil, chainDb)
+	} else if config.Dev != nil {
+		engine = dev.New(config.Dev)
 	} else {
 		engine = ethash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
@@ -1236,6 +1432,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 				DatasetDir:     stack.ResolvePath(ec.DefaultConfig.ecash.DatasetDir),
 				DatasetsInMem:  ec.DefaultConfig.ecash.DatasetsInMem,
 				DatasetsOnDisk: ec.DefaultConfig.ecash.DatasetsOnDisk,
+				CacheServer:    ctx.GlobalString(ecashCacheServerFlag.Name),
 			})
 		}
 	}
@@ -1255,6 +1452,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
+	if clique, ok := engine.(*clique.Clique); ok {
+		clique.SetStateReader(chain.StateAt)
+	}
 	return chain, chainDb
 }
 
@@ -0,0 +1,104 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+// API is a user facing RPC API to allow inspecting the validator set and voting
+// on changes to it.
+type API struct {
+	chain consensus.ChainReader
+	bft   *BFT
+}
+
+// GetSnapshot retrieves the state snapshot at a given block.
+func (api *API) GetSnapshot(number *rpc.BlockNumber) (*Snapshot, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	// Ensure we have an actually valid block and return its snapshot
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.bft.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+}
+
+// GetSnapshotAtHash retrieves the state snapshot at a given block.
+func (api *API) GetSnapshotAtHash(hash common.Hash) (*Snapshot, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.bft.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+}
+
+// GetValidators retrieves the list of authorized validators at the specified block.
+func (api *API) GetValidators(number *rpc.BlockNumber) ([]common.Address, error) {
+	snap, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	return snap.validators(), nil
+}
+
+// GetValidatorsAtHash retrieves the list of authorized validators at the specified block.
+func (api *API) GetValidatorsAtHash(hash common.Hash) ([]common.Address, error) {
+	snap, err := api.GetSnapshotAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return snap.validators(), nil
+}
+
+// Proposals returns the current proposals the node tries to uphold and vote on.
+func (api *API) Proposals() map[common.Address]bool {
+	api.bft.lock.RLock()
+	defer api.bft.lock.RUnlock()
+
+	proposals := make(map[common.Address]bool)
+	for address, auth := range api.bft.proposals {
+		proposals[address] = auth
+	}
+	return proposals
+}
+
+// Propose injects a new authorization proposal that the validator will attempt
+// to push through.
+func (api *API) Propose(address common.Address, auth bool) {
+	api.bft.lock.Lock()
+	defer api.bft.lock.Unlock()
+
+	api.bft.proposals[address] = auth
+}
+
+// Discard drops a currently running proposal, stopping the validator from casting
+// further votes (either for or against).
+func (api *API) Discard(address common.Address) {
+	api.bft.lock.Lock()
+	defer api.bft.lock.Unlock()
+
+	delete(api.bft.proposals, address)
+}
@@ -0,0 +1,893 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a byzantine fault tolerant proof-of-authority consensus
+// engine with instant finality.
+//
+// Blocks are agreed upon by a fixed set of validators in a PBFT style three
+// phase commit (pre-prepare, prepare, commit). A block is only sealed once more
+// than two thirds of the validators signed off on it, so a sealed block can never
+// be reorganised away unless a third of the validators are byzantine. If the
+// proposer of a round fails to get its block committed in time, the validators
+// agree on a round change and the next validator in line takes over.
+//
+// The validator set is kept in the header extra-data: checkpoint blocks carry
+// the full list, every other block may carry a single vote to add or remove a
+// validator, tallied the same way clique does it.
+package bft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/consensus/misc"
+	"github.com/ecchain/go-ecchain/core/state"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/rlp"
+	"github.com/ecchain/go-ecchain/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+	inmemoryMessages   = 1024 // Number of recent consensus messages to remember for deduplication
+)
+
+// BFT protocol constants.
+var (
+	epochLength    = uint64(30000) // Default number of blocks after which to checkpoint and reset the pending votes
+	blockPeriod    = uint64(1)     // Default minimum difference between two consecutive block's timestamps
+	requestTimeout = uint64(10000) // Default milliseconds to wait for a round to commit
+
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for validator vanity
+
+	// mixDigest is the magic mix digest identifying blocks sealed by this engine.
+	mixDigest = common.HexToHash("0x63746963616c2062797a616e74696e65206661756c7420746f6c6572616e6365")
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	defaultDifficulty = big.NewInt(1) // Block difficulty, forks are impossible so there's nothing to weigh
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the validator vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errInvalidExtraData is returned if the extra-data after the vanity cannot be
+	// decoded into the consensus fields.
+	errInvalidExtraData = errors.New("invalid consensus extra-data")
+
+	// errInvalidCheckpointValidators is returned if a checkpoint block contains an
+	// invalid list of validators (i.e. empty or not the correct ones).
+	errInvalidCheckpointValidators = errors.New("invalid validator list on checkpoint block")
+
+	// errExtraValidators is returned if non-checkpoint block contain validator
+	// data in their extra-data fields.
+	errExtraValidators = errors.New("non-checkpoint block contains extra validator list")
+
+	// errInvalidVote is returned if a block carries more than a single vote, or
+	// any vote at all on a checkpoint block.
+	errInvalidVote = errors.New("invalid validator vote")
+
+	// errInvalidNonce is returned if a block's nonce is non-zero.
+	errInvalidNonce = errors.New("non-zero nonce")
+
+	// errInvalidMixDigest is returned if a block's mix digest isn't the BFT one.
+	errInvalidMixDigest = errors.New("invalid mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTxHash is returned if a proposal's transactions don't match the
+	// transaction root in its header.
+	errInvalidTxHash = errors.New("invalid transaction hash")
+
+	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errUnauthorized is returned if a header is proposed by a non-validator.
+	errUnauthorized = errors.New("unauthorized")
+
+	// errInsufficientSeals is returned if a block doesn't carry commit seals from
+	// a quorum of the validators.
+	errInsufficientSeals = errors.New("insufficient committed seals")
+
+	// errInvalidCommittedSeal is returned if a commit seal was not signed by a
+	// validator or a validator signed more than once.
+	errInvalidCommittedSeal = errors.New("invalid committed seal")
+
+	// errNotStarted is returned if a proposal needs to be verified before the
+	// engine was handed a chain to verify it against.
+	errNotStarted = errors.New("engine not started")
+)
+
+// headerVote is a validator set change request cast by the proposer of a block.
+type headerVote struct {
+	Address   common.Address // Account being voted on to change its authorization
+	Authorize bool           // Whecer to authorize or deauthorize the voted account
+}
+
+// extra is the consensus data stored in the header extra-data after the vanity.
+type extra struct {
+	Validators    []common.Address // Full validator list, only present in checkpoint blocks
+	Votes         []headerVote     // Validator set change vote, at most one per block
+	Seal          []byte           // Proposer signature over the sealing hash
+	CommittedSeal [][]byte         // Commit signatures of a validator quorum
+}
+
+// decodeExtra extracts the consensus fields from a header's extra-data.
+func decodeExtra(header *types.Header) (*extra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errMissingVanity
+	}
+	ext := new(extra)
+	if err := rlp.DecodeBytes(header.Extra[extraVanity:], ext); err != nil {
+		return nil, errInvalidExtraData
+	}
+	return ext, nil
+}
+
+// encodeExtra replaces the consensus fields in a header's extra-data, keeping
+// the vanity intact.
+func encodeExtra(header *types.Header, ext *extra) error {
+	blob, err := rlp.EncodeToBytes(ext)
+	if err != nil {
+		return err
+	}
+	vanity := make([]byte, extraVanity)
+	copy(vanity, header.Extra)
+
+	header.Extra = append(vanity, blob...)
+	return nil
+}
+
+// GenesisExtra assembles the extra-data of a genesis block that sets up the
+// initial validators of a BFT network.
+func GenesisExtra(vanity []byte, validators []common.Address) ([]byte, error) {
+	header := &types.Header{Extra: vanity}
+	if err := encodeExtra(header, &extra{Validators: validators}); err != nil {
+		return nil, err
+	}
+	return header.Extra, nil
+}
+
+// sigHash returns the hash which is signed by the proposer of a block. It is the
+// hash of the entire header apart from the proposer and committed seals.
+func sigHash(header *types.Header) (common.Hash, error) {
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	ext.Seal, ext.CommittedSeal = nil, nil
+
+	header = types.CopyHeader(header)
+	if err := encodeExtra(header, ext); err != nil {
+		return common.Hash{}, err
+	}
+	return header.Hash(), nil
+}
+
+// proposalHash returns the hash the validators agree upon during consensus. It
+// is the hash of the entire header apart from the committed seals, which are
+// only known once consensus is reached.
+func proposalHash(header *types.Header) (common.Hash, error) {
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	ext.CommittedSeal = nil
+
+	header = types.CopyHeader(header)
+	if err := encodeExtra(header, ext); err != nil {
+		return common.Hash{}, err
+	}
+	return header.Hash(), nil
+}
+
+// commitHash returns the hash a validator signs when committing to a proposal.
+func commitHash(digest common.Hash) []byte {
+	return crypto.Keccak256(digest[:], []byte{byte(msgCommit)})
+}
+
+// ecrecover extracts the ecchain account address of the proposer of a header.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sighash, err := sigHash(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer, err := recoverAddress(sighash[:], ext.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// recoverAddress returns the address of the key that signed the given hash.
+func recoverAddress(hash []byte, sig []byte) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// BFT is the byzantine fault tolerant proof-of-authority consensus engine.
+type BFT struct {
+	config *params.BFTConfig // Consensus engine configuration parameters
+	db     ecdb.Database     // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+	messages   *lru.ARCCache // Recently seen consensus messages to avoid relaying twice
+
+	key     *ecdsa.PrivateKey // Validator key to sign proposals and votes with
+	address common.Address    // ecchain address of the validator key
+
+	core *core // Consensus state machine agreeing on the next block
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	chain  consensus.ChainReader           // Chain to verify proposals and message senders against
+	insert func(types.Blocks) (int, error) // Importer for blocks committed but not built locally
+	result chan *types.Block               // Channel to deliver the block currently being sealed on
+	digest common.Hash                     // Proposal hash of the block currently being sealed
+	lock   sync.RWMutex                    // Protects the proposals and sealing fields
+
+	peers     map[discover.NodeID]*peer // Remote nodes speaking the consensus protocol
+	peersLock sync.RWMutex              // Protects the peer set
+}
+
+// New creates a BFT consensus engine, signing consensus messages with the given
+// validator key. The key may be nil for nodes only verifying the chain.
+func New(config *params.BFTConfig, key *ecdsa.PrivateKey, db ecdb.Database) *BFT {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = epochLength
+	}
+	if conf.Period == 0 {
+		conf.Period = blockPeriod
+	}
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = requestTimeout
+	}
+	// Allocate the snapshot caches and create the engine
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	messages, _ := lru.NewARC(inmemoryMessages)
+
+	bft := &BFT{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		messages:   messages,
+		key:        key,
+		proposals:  make(map[common.Address]bool),
+		peers:      make(map[discover.NodeID]*peer),
+	}
+	if key != nil {
+		bft.address = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	bft.core = newCore(bft.address, time.Duration(conf.RequestTimeout)*time.Millisecond, bft)
+	return bft
+}
+
+// Address returns the validator address the engine signs with.
+func (b *BFT) Address() common.Address {
+	return b.address
+}
+
+// SetInserter sets the callback used to import blocks that the validator set
+// committed to, but which weren't built by the local miner. This happens if a
+// validator re-proposes a block locked in an earlier round.
+func (b *BFT) SetInserter(insert func(types.Blocks) (int, error)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.insert = insert
+}
+
+// SetChain sets the local chain used to verify proposals and to authenticate
+// consensus messages before the first seal, allowing non-validator nodes to
+// relay messages of the validators.
+func (b *BFT) SetChain(chain consensus.ChainReader) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.chain = chain
+}
+
+// Stop terminates the consensus state machine.
+func (b *BFT) Stop() {
+	b.core.stop()
+}
+
+// Author implements consensus.Engine, returning the ecchain address recovered
+// from the proposer seal in the header's extra-data section.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, b.signatures)
+}
+
+// VerifyHeader checks whecer a header conforms to the consensus rules.
+func (b *BFT) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return b.verifyHeader(chain, header, nil, true)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (b *BFT) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := b.verifyHeader(chain, header, headers[:i], true)
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whecer a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. Committed seals are only checked if
+// requested, as proposals don't have them yet.
+func (b *BFT) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Ensure that the extra-data contains the consensus fields
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	// Checkpoint blocks need to carry the validator list and no votes
+	checkpoint := (number % b.config.Epoch) == 0
+	if checkpoint && len(ext.Validators) == 0 {
+		return errInvalidCheckpointValidators
+	}
+	if !checkpoint && len(ext.Validators) != 0 {
+		return errExtraValidators
+	}
+	if len(ext.Votes) > 1 || (checkpoint && len(ext.Votes) != 0) {
+		return errInvalidVote
+	}
+	// Ensure the fields meaningless in BFT are set to their defaults
+	if header.Nonce != (types.BlockNonce{}) {
+		return errInvalidNonce
+	}
+	if header.MixDigest != mixDigest {
+		return errInvalidMixDigest
+	}
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if number > 0 && (header.Difficulty == nil || header.Difficulty.Cmp(defaultDifficulty) != 0) {
+		return errInvalidDifficulty
+	}
+	// If all checks passed, validate any special fields for hard forks
+	if err := misc.VerifyForkHashes(chain.Config(), header, false); err != nil {
+		return err
+	}
+	// All basic checks passed, verify cascading fields
+	return b.verifyCascadingFields(chain, header, parents, committed)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers.
+func (b *BFT) verifyCascadingFields(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+b.config.Period > header.Time.Uint64() {
+		return ErrInvalidTimestamp
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	// If the block is a checkpoint block, verify the validator list
+	if number%b.config.Epoch == 0 {
+		ext, _ := decodeExtra(header)
+		if !validatorsEqual(ext.Validators, snap.validators()) {
+			return errInvalidCheckpointValidators
+		}
+	}
+	// All basic checks passed, verify the seals and return
+	if err := b.verifySeal(snap, header); err != nil {
+		return err
+	}
+	if committed {
+		return b.verifyCommittedSeals(snap, header)
+	}
+	return nil
+}
+
+// snapshot retrieves the authorization snapshot at a given point in time.
+func (b *BFT) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := b.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(b.config, b.signatures, b.db, hash); err == nil {
+				log.Trace("Loaded validator snapshot from disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at block zero, make a snapshot
+		if number == 0 {
+			genesis := chain.GetHeaderByNumber(0)
+			if err := b.verifyHeader(chain, genesis, nil, false); err != nil {
+				return nil, err
+			}
+			ext, _ := decodeExtra(genesis)
+			snap = newSnapshot(b.config, b.signatures, 0, genesis.Hash(), ext.Validators)
+			if err := snap.store(b.db); err != nil {
+				return nil, err
+			}
+			log.Trace("Stored genesis validator snapshot to disk")
+			break
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.Header
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	b.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(b.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored validator snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, err
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (b *BFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whecer the proposer seal and
+// the committed seals contained in the header satisfy the consensus protocol.
+func (b *BFT) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	if err := b.verifySeal(snap, header); err != nil {
+		return err
+	}
+	return b.verifyCommittedSeals(snap, header)
+}
+
+// verifySeal checks whecer the proposer of a header is an authorized validator.
+func (b *BFT) verifySeal(snap *Snapshot, header *types.Header) error {
+	proposer, err := ecrecover(header, b.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[proposer]; !ok {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// verifyCommittedSeals checks whecer a quorum of distinct validators committed
+// to the header.
+func (b *BFT) verifyCommittedSeals(snap *Snapshot, header *types.Header) error {
+	ext, err := decodeExtra(header)
+	if err != nil {
+		return err
+	}
+	digest, err := proposalHash(header)
+	if err != nil {
+		return err
+	}
+	hash := commitHash(digest)
+
+	signed := make(map[common.Address]struct{})
+	for _, seal := range ext.CommittedSeal {
+		validator, err := recoverAddress(hash, seal)
+		if err != nil {
+			return errInvalidCommittedSeal
+		}
+		if _, ok := snap.Validators[validator]; !ok {
+			return errInvalidCommittedSeal
+		}
+		if _, ok := signed[validator]; ok {
+			return errInvalidCommittedSeal
+		}
+		signed[validator] = struct{}{}
+	}
+	if len(signed) < quorum(len(snap.Validators)) {
+		return errInsufficientSeals
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	header.Coinbase = b.address
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = mixDigest
+	header.Difficulty = new(big.Int).Set(defaultDifficulty)
+
+	number := header.Number.Uint64()
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	ext := new(extra)
+	if number%b.config.Epoch == 0 {
+		ext.Validators = snap.validators()
+	} else {
+		b.lock.RLock()
+
+		// Gather all the proposals that make sense voting on
+		addresses := make([]common.Address, 0, len(b.proposals))
+		for address, authorize := range b.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		// If there's pending proposals, cast a vote on one of them
+		if len(addresses) > 0 {
+			address := addresses[rand.Intn(len(addresses))]
+			ext.Votes = []headerVote{{Address: address, Authorize: b.proposals[address]}}
+		}
+		b.lock.RUnlock()
+	}
+	if err := encodeExtra(header, ext); err != nil {
+		return err
+	}
+	// Ensure the timestamp has the correct delay
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(b.config.Period))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, and returns the final block.
+func (b *BFT) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// No block rewards in BFT, so the state remains as is and uncles are dropped
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Seal implements consensus.Engine, signing the block as its proposer and running
+// it through the consensus rounds until a quorum of validators committed to it.
+//
+// Every validator needs to keep sealing for the consensus to make progress, even
+// if it's not its turn to propose: the state machine only moves to a new height
+// when the local miner asks for a block on top of it.
+func (b *BFT) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	if b.key == nil {
+		return nil, errUnauthorized
+	}
+	// Bail out if we're not a validator on top of the parent
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, authorized := snap.Validators[b.address]; !authorized {
+		return nil, errUnauthorized
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	var lastProposer common.Address
+	if number > 1 {
+		if lastProposer, err = b.Author(parent); err != nil {
+			return nil, err
+		}
+	}
+	// Sign the block as its proposer, the committed seals are added on consensus
+	sighash, err := sigHash(header)
+	if err != nil {
+		return nil, err
+	}
+	seal, err := crypto.Sign(sighash[:], b.key)
+	if err != nil {
+		return nil, err
+	}
+	ext, _ := decodeExtra(header)
+	ext.Seal = seal
+	if err := encodeExtra(header, ext); err != nil {
+		return nil, err
+	}
+	block = block.WithSeal(header)
+
+	digest, _ := proposalHash(header)
+	result := make(chan *types.Block, 1)
+
+	b.lock.Lock()
+	b.chain, b.digest, b.result = chain, digest, result
+	b.lock.Unlock()
+
+	// Start agreeing on the height right away, but only propose in our slot
+	b.core.startSequence(number, snap.validators(), lastProposer)
+
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now()) // nolint: gosimple
+	log.Trace("Waiting for slot to propose", "delay", common.PrettyDuration(delay))
+
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	b.core.setCandidate(block)
+
+	select {
+	case <-stop:
+		return nil, nil
+	case sealed := <-result:
+		return sealed, nil
+	}
+}
+
+// verifyProposal checks whecer a block proposed by a remote validator is valid
+// on top of the local chain. It's invoked by the consensus state machine before
+// accepting a pre-prepare.
+func (b *BFT) verifyProposal(block *types.Block) error {
+	b.lock.RLock()
+	chain := b.chain
+	b.lock.RUnlock()
+
+	if chain == nil {
+		return errNotStarted
+	}
+	if hash := types.DeriveSha(block.Transactions()); hash != block.TxHash() {
+		return errInvalidTxHash
+	}
+	if block.UncleHash() != uncleHash {
+		return errInvalidUncleHash
+	}
+	return b.verifyHeader(chain, block.Header(), nil, false)
+}
+
+// validators implements backend, returning the validator set of a sequence if
+// its parent block is known locally.
+func (b *BFT) validators(seq uint64) []common.Address {
+	b.lock.RLock()
+	chain := b.chain
+	b.lock.RUnlock()
+
+	if chain == nil || seq == 0 {
+		return nil
+	}
+	parent := chain.GetHeaderByNumber(seq - 1)
+	if parent == nil {
+		return nil
+	}
+	snap, err := b.snapshot(chain, seq-1, parent.Hash(), nil)
+	if err != nil {
+		return nil
+	}
+	return snap.validators()
+}
+
+// commit is invoked by the consensus state machine when a quorum of validators
+// committed to a block. The proposer of the round assembles the final block: if
+// it's the one the local miner is sealing, it's handed back to it, otherwise it
+// was a locked block re-proposed by us and is imported directly.
+func (b *BFT) commit(proposal *types.Block, seals [][]byte, proposer bool) {
+	if !proposer {
+		return
+	}
+	header := proposal.Header()
+	ext, err := decodeExtra(header)
+	if err != nil {
+		log.Error("Failed to decode committed proposal", "err", err)
+		return
+	}
+	ext.CommittedSeal = seals
+	if err := encodeExtra(header, ext); err != nil {
+		log.Error("Failed to encode committed seals", "err", err)
+		return
+	}
+	sealed := proposal.WithSeal(header)
+	digest, _ := proposalHash(header)
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if digest == b.digest {
+		select {
+		case b.result <- sealed:
+		default:
+		}
+		return
+	}
+	if b.insert != nil {
+		go func(insert func(types.Blocks) (int, error)) {
+			if _, err := insert(types.Blocks{sealed}); err != nil {
+				log.Warn("Failed to import committed block", "number", sealed.Number(), "hash", sealed.Hash(), "err", err)
+			}
+		}(b.insert)
+	}
+}
+
+// sign signs a consensus message hash with the validator key.
+func (b *BFT) sign(hash []byte) ([]byte, error) {
+	if b.key == nil {
+		return nil, errUnauthorized
+	}
+	return crypto.Sign(hash, b.key)
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. BFT blocks are final,
+// so there's no reason for difficulty and it's always 1.
+func (b *BFT) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(defaultDifficulty)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting.
+func (b *BFT) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "bft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, bft: b},
+		Public:    false,
+	}}
+}
+
+// quorum returns the number of validators that need to agree for a decision to
+// be final, tolerating up to (n-1)/3 faulty ones.
+func quorum(n int) int {
+	return (2*n + 2) / 3
+}
+
+// validatorsEqual reports whecer two sorted validator lists are identical.
+func validatorsEqual(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i][:], b[i][:]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,513 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/rlp"
+)
+
+const (
+	maxBacklog      = 1024 // Maximum number of future messages to hold on to
+	maxTimeoutShift = 8    // Maximum number of times the round timeout is doubled
+)
+
+var (
+	// errOldMessage is returned if a message belongs to an already finished sequence.
+	errOldMessage = errors.New("old consensus message")
+
+	// errFutureMessage is returned if a message of a future sequence was backlogged
+	// without its sender being verifiable against the sequence's validators yet.
+	errFutureMessage = errors.New("future consensus message")
+)
+
+// coreState is the phase the consensus state machine is in within a round.
+type coreState uint8
+
+const (
+	stateAcceptRequest coreState = iota // Waiting for the proposer's pre-prepare
+	statePreprepared                    // Proposal accepted, collecting prepares
+	statePrepared                       // Quorum prepared, collecting commits
+	stateCommitted                      // Quorum committed, waiting for the next sequence
+)
+
+// backend is the set of engine functionality the consensus state machine needs
+// to sign, gossip, validate and finally seal blocks.
+type backend interface {
+	// sign signs a hash with the local validator key.
+	sign(hash []byte) ([]byte, error)
+
+	// broadcast gossips an encoded consensus message to the remote validators.
+	broadcast(payload []byte)
+
+	// verifyProposal checks whecer a remotely proposed block is valid.
+	verifyProposal(block *types.Block) error
+
+	// validators returns the validator set of a sequence, or nil if its parent
+	// block is not known locally.
+	validators(seq uint64) []common.Address
+
+	// commit is called when a quorum of validators committed to a block.
+	commit(proposal *types.Block, seals [][]byte, proposer bool)
+}
+
+// core is the PBFT style consensus state machine. It agrees on one block per
+// sequence (block number), running as many rounds as needed until a proposer
+// manages to get its block committed by a quorum of the validators.
+type core struct {
+	address common.Address // Local validator address
+	timeout time.Duration  // Base timeout of a round before requesting a round change
+	backend backend        // Engine to sign, gossip and seal with
+
+	seq          uint64           // Current sequence (block number) being agreed upon
+	round        uint64           // Current round within the sequence
+	state        coreState        // Phase of the current round
+	validators   []common.Address // Validators of the current sequence, in ascending order
+	lastProposer common.Address   // Proposer of the parent block, for proposer rotation
+
+	candidate    *types.Block // Block the local miner wants to propose in this sequence
+	proposal     *types.Block // Block accepted in the current round
+	digest       common.Hash  // Proposal hash of the accepted block
+	locked       *types.Block // Block prepared in an earlier round, re-proposed in later ones
+	lockedDigest common.Hash  // Proposal hash of the locked block
+
+	prepares        map[common.Address]common.Hash         // Digests prepared in the current round
+	commits         map[common.Address]*message            // Commits received in the current round
+	roundChanges    map[uint64]map[common.Address]struct{} // Round change requests for future rounds
+	sentRoundChange uint64                                 // Highest round we requested a change to
+
+	backlog []*message  // Messages for future sequences or rounds
+	timer   *time.Timer // Round timeout triggering round changes
+	stopped bool        // Whecer the state machine was terminated
+
+	lock sync.Mutex
+}
+
+// newCore creates a consensus state machine for the given validator.
+func newCore(address common.Address, timeout time.Duration, backend backend) *core {
+	return &core{
+		address:      address,
+		timeout:      timeout,
+		backend:      backend,
+		prepares:     make(map[common.Address]common.Hash),
+		commits:      make(map[common.Address]*message),
+		roundChanges: make(map[uint64]map[common.Address]struct{}),
+	}
+}
+
+// startSequence moves the state machine to a new block number. It's a no-op if
+// the sequence is already running.
+func (c *core) startSequence(seq uint64, validators []common.Address, lastProposer common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped || (c.seq == seq && c.validators != nil) {
+		return
+	}
+	log.Debug("Starting new consensus sequence", "number", seq, "validators", len(validators))
+
+	c.seq = seq
+	c.validators = validators
+	c.lastProposer = lastProposer
+	c.candidate = nil
+	c.locked, c.lockedDigest = nil, common.Hash{}
+	c.roundChanges = make(map[uint64]map[common.Address]struct{})
+	c.sentRoundChange = 0
+
+	c.startRound(0)
+}
+
+// setCandidate sets the block the local miner wants to propose in the current
+// sequence, proposing it right away if it's our turn.
+func (c *core) setCandidate(block *types.Block) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped || block.NumberU64() != c.seq {
+		return
+	}
+	c.candidate = block
+	if c.state == stateAcceptRequest && c.proposer(c.round) == c.address {
+		c.propose()
+	}
+}
+
+// stop terminates the state machine, cancelling any pending round timer.
+func (c *core) stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stopped = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// startRound resets the round state and, if we're the proposer of the new round,
+// proposes our block.
+func (c *core) startRound(round uint64) {
+	c.round = round
+	c.state = stateAcceptRequest
+	c.proposal, c.digest = nil, common.Hash{}
+	c.prepares = make(map[common.Address]common.Hash)
+	c.commits = make(map[common.Address]*message)
+	for r := range c.roundChanges {
+		if r <= round {
+			delete(c.roundChanges, r)
+		}
+	}
+	c.armTimer(c.seq, round)
+
+	if c.proposer(round) == c.address {
+		c.propose()
+	}
+	c.replayBacklog()
+}
+
+// armTimer (re)schedules the round timeout, doubling it for every failed round.
+func (c *core) armTimer(seq, round uint64) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	shift := round
+	if shift > maxTimeoutShift {
+		shift = maxTimeoutShift
+	}
+	timeout := c.timeout << shift
+	c.timer = time.AfterFunc(timeout, func() { c.onTimeout(seq, round) })
+}
+
+// onTimeout requests a round change if the round didn't make progress in time.
+// The timer is re-armed so that we keep asking for later rounds until the
+// validators agree on one.
+func (c *core) onTimeout(seq, round uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped || c.seq != seq || c.round != round {
+		return
+	}
+	next := round + 1
+	if c.sentRoundChange >= next {
+		next = c.sentRoundChange + 1
+	}
+	log.Debug("Consensus round timed out", "number", seq, "round", round, "next", next)
+
+	c.armTimer(seq, round)
+	c.sendRoundChange(next)
+}
+
+// proposer returns the validator allowed to propose in the given round. The
+// proposer rotates round-robin, starting after the proposer of the parent.
+func (c *core) proposer(round uint64) common.Address {
+	n := len(c.validators)
+	if n == 0 {
+		return common.Address{}
+	}
+	offset := -1
+	for i, validator := range c.validators {
+		if validator == c.lastProposer {
+			offset = i
+			break
+		}
+	}
+	return c.validators[(offset+1+int(round%uint64(n)))%n]
+}
+
+// containsAddress returns whecer the address is part of the validator set.
+func containsAddress(validators []common.Address, address common.Address) bool {
+	for _, validator := range validators {
+		if validator == address {
+			return true
+		}
+	}
+	return false
+}
+
+// propose broadcasts a pre-prepare with the locked block if we have one, or the
+// local candidate otherwise.
+func (c *core) propose() {
+	block := c.locked
+	if block == nil {
+		block = c.candidate
+	}
+	if block == nil {
+		return
+	}
+	blob, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	log.Debug("Proposing block", "number", c.seq, "round", c.round, "hash", block.Hash())
+	c.send(&message{Code: msgPreprepare, Round: c.round, Proposal: blob})
+}
+
+// sendRoundChange requests moving to the given round.
+func (c *core) sendRoundChange(round uint64) {
+	c.sentRoundChange = round
+	c.send(&message{Code: msgRoundChange, Round: round})
+}
+
+// send signs and gossips a message, also processing it locally.
+func (c *core) send(msg *message) {
+	msg.Sequence = c.seq
+	payload, err := msg.encode(c.backend.sign)
+	if err != nil {
+		log.Error("Failed to sign consensus message", "err", err)
+		return
+	}
+	msg.sender = c.address
+	c.backend.broadcast(payload)
+	c.process(msg)
+}
+
+// handle processes an encoded consensus message received from the network. A nil
+// error means the message was valid and may be relayed further.
+func (c *core) handle(payload []byte) error {
+	msg, err := decodeMessage(payload)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped {
+		return nil
+	}
+	return c.handleMessage(msg)
+}
+
+// handleMessage authenticates a message against the validators of its sequence,
+// then processes it if it belongs to the current view or backlogs it if it's a
+// future one. A nil error means the message was sent by a validator of its
+// sequence and may be relayed.
+//
+// The validators of future sequences are not known until their parent blocks
+// are, so such messages are checked against the current validators instead and
+// are only relayed once they were verified when replayed from the backlog.
+func (c *core) handleMessage(msg *message) error {
+	if c.validators != nil && msg.Sequence < c.seq {
+		return errOldMessage
+	}
+	verified, validators := true, c.validators
+	if validators == nil || msg.Sequence != c.seq {
+		if validators = c.backend.validators(msg.Sequence); validators == nil {
+			verified, validators = false, c.validators
+		}
+	}
+	if !containsAddress(validators, msg.sender) {
+		return errUnauthorized
+	}
+	if c.validators == nil || msg.Sequence > c.seq || (msg.Code != msgRoundChange && msg.Round > c.round) {
+		c.addBacklog(msg)
+		if !verified {
+			return errFutureMessage
+		}
+	} else {
+		c.process(msg)
+	}
+	msg.relayed = true
+	return nil
+}
+
+// process dispatches a message of the current sequence to its phase handler.
+func (c *core) process(msg *message) {
+	log.Trace("Processing consensus message", "msg", msg)
+
+	switch msg.Code {
+	case msgPreprepare:
+		c.handlePreprepare(msg)
+	case msgPrepare:
+		c.handlePrepare(msg)
+	case msgCommit:
+		c.handleCommit(msg)
+	case msgRoundChange:
+		c.handleRoundChange(msg)
+	}
+}
+
+// handlePreprepare accepts the proposal of the round's proposer and prepares it.
+func (c *core) handlePreprepare(msg *message) {
+	if msg.Round != c.round || c.state != stateAcceptRequest {
+		return
+	}
+	if msg.sender != c.proposer(c.round) {
+		log.Debug("Ignoring pre-prepare from non-proposer", "msg", msg)
+		return
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(msg.Proposal, block); err != nil {
+		log.Debug("Failed to decode proposal", "msg", msg, "err", err)
+		return
+	}
+	if block.NumberU64() != c.seq {
+		return
+	}
+	digest, err := proposalHash(block.Header())
+	if err != nil {
+		return
+	}
+	// If we're locked, only the locked block may be accepted, otherwise check
+	// that the block is valid unless we proposed it ourselves
+	switch {
+	case c.locked != nil:
+		if digest != c.lockedDigest {
+			log.Debug("Rejecting proposal conflicting with locked block", "msg", msg, "locked", c.lockedDigest)
+			return
+		}
+	case msg.sender != c.address:
+		if err := c.backend.verifyProposal(block); err != nil {
+			log.Debug("Rejecting invalid proposal", "msg", msg, "err", err)
+			return
+		}
+	}
+	c.proposal, c.digest = block, digest
+	c.state = statePreprepared
+
+	c.send(&message{Code: msgPrepare, Round: c.round, Digest: digest})
+
+	// Prepares and commits may have overtaken the pre-prepare
+	c.checkPrepared()
+	c.checkCommitted()
+}
+
+// handlePrepare records a prepare vote of a validator.
+func (c *core) handlePrepare(msg *message) {
+	if msg.Round != c.round {
+		return
+	}
+	c.prepares[msg.sender] = msg.Digest
+	c.checkPrepared()
+}
+
+// checkPrepared locks on the proposal and commits to it once a quorum of the
+// validators prepared it.
+func (c *core) checkPrepared() {
+	if c.state != statePreprepared {
+		return
+	}
+	count := 0
+	for _, digest := range c.prepares {
+		if digest == c.digest {
+			count++
+		}
+	}
+	if count < quorum(len(c.validators)) {
+		return
+	}
+	c.state = statePrepared
+	c.locked, c.lockedDigest = c.proposal, c.digest
+
+	seal, err := c.backend.sign(commitHash(c.digest))
+	if err != nil {
+		log.Error("Failed to sign committed seal", "err", err)
+		return
+	}
+	c.send(&message{Code: msgCommit, Round: c.round, Digest: c.digest, CommittedSeal: seal})
+}
+
+// handleCommit records a commit vote of a validator along with its seal.
+func (c *core) handleCommit(msg *message) {
+	if msg.Round != c.round {
+		return
+	}
+	signer, err := recoverAddress(commitHash(msg.Digest), msg.CommittedSeal)
+	if err != nil || signer != msg.sender {
+		log.Debug("Ignoring commit with invalid seal", "msg", msg)
+		return
+	}
+	c.commits[msg.sender] = msg
+	c.checkCommitted()
+}
+
+// checkCommitted finalizes the proposal once a quorum of the validators committed
+// to it.
+func (c *core) checkCommitted() {
+	if c.proposal == nil || c.state == stateCommitted {
+		return
+	}
+	// Gather the seals in validator order to keep them deterministic
+	var seals [][]byte
+	for _, validator := range c.validators {
+		if msg, ok := c.commits[validator]; ok && msg.Digest == c.digest {
+			seals = append(seals, msg.CommittedSeal)
+		}
+	}
+	if len(seals) < quorum(len(c.validators)) {
+		return
+	}
+	c.state = stateCommitted
+	c.locked, c.lockedDigest = c.proposal, c.digest
+
+	log.Debug("Consensus reached on block", "number", c.seq, "round", c.round, "hash", c.proposal.Hash(), "seals", len(seals))
+	c.backend.commit(c.proposal, seals, c.proposer(c.round) == c.address)
+}
+
+// handleRoundChange records a round change request. Once more than a third of
+// the validators want a later round we join them, and once a quorum does the
+// round is changed.
+func (c *core) handleRoundChange(msg *message) {
+	if msg.Round <= c.round {
+		return
+	}
+	if c.roundChanges[msg.Round] == nil {
+		c.roundChanges[msg.Round] = make(map[common.Address]struct{})
+	}
+	c.roundChanges[msg.Round][msg.sender] = struct{}{}
+
+	faulty := (len(c.validators) - 1) / 3
+	if len(c.roundChanges[msg.Round]) > faulty && c.sentRoundChange < msg.Round {
+		c.sendRoundChange(msg.Round)
+	}
+	if msg.Round > c.round && len(c.roundChanges[msg.Round]) >= quorum(len(c.validators)) {
+		log.Debug("Changing consensus round", "number", c.seq, "round", msg.Round)
+		c.startRound(msg.Round)
+	}
+}
+
+// addBacklog stores a message of a future view, dropping the oldest one if the
+// backlog is full.
+func (c *core) addBacklog(msg *message) {
+	if len(c.backlog) >= maxBacklog {
+		c.backlog = c.backlog[1:]
+	}
+	c.backlog = append(c.backlog, msg)
+}
+
+// replayBacklog re-handles all the messages in the backlog after a view change,
+// relaying the ones that could only be verified now.
+func (c *core) replayBacklog() {
+	backlog := c.backlog
+	c.backlog = nil
+
+	for _, msg := range backlog {
+		if msg.Sequence < c.seq {
+			continue
+		}
+		relayed := msg.relayed
+		if err := c.handleMessage(msg); err == nil && !relayed && msg.payload != nil {
+			c.backend.broadcast(msg.payload)
+		}
+	}
+}
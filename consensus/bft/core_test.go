@@ -0,0 +1,290 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/params"
+)
+
+// testCommit is a consensus decision reported by a validator.
+type testCommit struct {
+	validator common.Address
+	proposal  *types.Block
+	seals     [][]byte
+	proposer  bool
+}
+
+// testBackend is a validator of an in-memory network, delivering every message
+// to all other online validators.
+type testBackend struct {
+	key     *ecdsa.PrivateKey
+	core    *core
+	network *testNetwork
+	commits chan testCommit
+}
+
+func (b *testBackend) sign(hash []byte) ([]byte, error)  { return crypto.Sign(hash, b.key) }
+func (b *testBackend) verifyProposal(*types.Block) error { return nil }
+
+func (b *testBackend) validators(seq uint64) []common.Address {
+	return b.network.validators
+}
+
+func (b *testBackend) broadcast(payload []byte) {
+	for _, peer := range b.network.nodes {
+		if peer != b && peer.core != nil {
+			go peer.core.handle(payload)
+		}
+	}
+}
+
+func (b *testBackend) commit(proposal *types.Block, seals [][]byte, proposer bool) {
+	b.commits <- testCommit{b.core.address, proposal, seals, proposer}
+}
+
+// testNetwork is a set of validators, some of which may be offline.
+type testNetwork struct {
+	nodes      []*testBackend
+	validators []common.Address
+	commits    chan testCommit
+}
+
+// newTestNetwork creates n validators, leaving the ones listed as offline without
+// a consensus state machine. The validators are sorted by address.
+func newTestNetwork(n int, timeout time.Duration, offline ...int) *testNetwork {
+	network := &testNetwork{commits: make(chan testCommit, 4*n)}
+
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	for i := 0; i < n; i++ {
+		key, _ := crypto.GenerateKey()
+		keys[crypto.PubkeyToAddress(key.PublicKey)] = key
+	}
+	var addresses []common.Address
+	for address := range keys {
+		addresses = append(addresses, address)
+	}
+	network.validators = newSnapshot(nil, nil, 0, common.Hash{}, addresses).validators()
+
+	for i, address := range network.validators {
+		node := &testBackend{key: keys[address], network: network, commits: network.commits}
+		network.nodes = append(network.nodes, node)
+
+		online := true
+		for _, j := range offline {
+			if i == j {
+				online = false
+			}
+		}
+		if online {
+			node.core = newCore(address, timeout, node)
+		}
+	}
+	return network
+}
+
+// start begins the given sequence on all online validators, handing each the
+// block to propose.
+func (n *testNetwork) start(seq uint64, block *types.Block) {
+	for _, node := range n.nodes {
+		if node.core != nil {
+			node.core.startSequence(seq, n.validators, common.Address{})
+		}
+	}
+	for _, node := range n.nodes {
+		if node.core != nil {
+			node.core.setCandidate(block)
+		}
+	}
+}
+
+// stop terminates all online validators.
+func (n *testNetwork) stop() {
+	for _, node := range n.nodes {
+		if node.core != nil {
+			node.core.stop()
+		}
+	}
+}
+
+// newTestProposal creates a block with the consensus fields of the given number.
+func newTestProposal(t *testing.T, number uint64) *types.Block {
+	header := &types.Header{Number: new(big.Int).SetUint64(number), Time: big.NewInt(0), Difficulty: big.NewInt(1)}
+	if err := encodeExtra(header, &extra{}); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	return types.NewBlockWithHeader(header)
+}
+
+// waitCommits waits until the expected number of validators committed, checking
+// that they all agree on the block and exactly the wanted one assembles it.
+func waitCommits(t *testing.T, network *testNetwork, count int, proposer common.Address) []testCommit {
+	var commits []testCommit
+	for len(commits) < count {
+		select {
+		case commit := <-network.commits:
+			commits = append(commits, commit)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for commits: have %d, want %d", len(commits), count)
+		}
+	}
+	for _, commit := range commits {
+		if commit.proposal.Hash() != commits[0].proposal.Hash() {
+			t.Fatalf("validators committed to different blocks: %x != %x", commit.proposal.Hash(), commits[0].proposal.Hash())
+		}
+		if len(commit.seals) < quorum(len(network.validators)) {
+			t.Errorf("validator %x: committed with %d seals, want at least %d", commit.validator, len(commit.seals), quorum(len(network.validators)))
+		}
+		if want := commit.validator == proposer; commit.proposer != want {
+			t.Errorf("validator %x: proposer flag mismatch: have %v, want %v", commit.validator, commit.proposer, want)
+		}
+	}
+	return commits
+}
+
+// Tests that a healthy validator set commits to the first proposal, and that the
+// resulting seals pass the engine's verification.
+func TestCoreCommit(t *testing.T) {
+	network := newTestNetwork(4, time.Minute)
+	defer network.stop()
+
+	network.start(1, newTestProposal(t, 1))
+	commits := waitCommits(t, network, 4, network.validators[0])
+
+	// Assemble the sealed header and verify it against the validator set
+	header := commits[0].proposal.Header()
+	ext, _ := decodeExtra(header)
+	ext.CommittedSeal = commits[0].seals
+	if err := encodeExtra(header, ext); err != nil {
+		t.Fatalf("failed to encode committed seals: %v", err)
+	}
+	db, _ := ecdb.NewMemDatabase()
+	engine := New(&params.BFTConfig{}, nil, db)
+	snap := newSnapshot(engine.config, engine.signatures, 0, common.Hash{}, network.validators)
+
+	if err := engine.verifyCommittedSeals(snap, header); err != nil {
+		t.Fatalf("committed seals rejected: %v", err)
+	}
+	ext.CommittedSeal = ext.CommittedSeal[:quorum(len(network.validators))-1]
+	encodeExtra(header, ext)
+	if err := engine.verifyCommittedSeals(snap, header); err != errInsufficientSeals {
+		t.Fatalf("insufficient seals error mismatch: have %v, want %v", err, errInsufficientSeals)
+	}
+}
+
+// Tests that if the proposer of the first round is offline, the remaining
+// validators change rounds and commit the next proposer's block.
+func TestCoreRoundChange(t *testing.T) {
+	network := newTestNetwork(4, 50*time.Millisecond, 0)
+	defer network.stop()
+
+	network.start(1, newTestProposal(t, 1))
+	waitCommits(t, network, 3, network.validators[1])
+}
+
+// Tests that a single validator network commits on its own.
+func TestCoreSingleValidator(t *testing.T) {
+	network := newTestNetwork(1, time.Minute)
+	defer network.stop()
+
+	network.start(1, newTestProposal(t, 1))
+	waitCommits(t, network, 1, network.validators[0])
+}
+
+// relayBackend is a consensus backend of a node without a validator key, which
+// knows the validators of the sequences up to a given one and records all the
+// messages relayed by the state machine.
+type relayBackend struct {
+	validatorSet []common.Address
+	known        uint64
+	relayed      [][]byte
+}
+
+func (b *relayBackend) sign(hash []byte) ([]byte, error)    { return nil, errors.New("no key") }
+func (b *relayBackend) verifyProposal(*types.Block) error   { return nil }
+func (b *relayBackend) broadcast(payload []byte)            { b.relayed = append(b.relayed, payload) }
+func (b *relayBackend) commit(*types.Block, [][]byte, bool) {}
+func (b *relayBackend) validators(seq uint64) []common.Address {
+	if seq > b.known {
+		return nil
+	}
+	return b.validatorSet
+}
+
+// newTestMessage creates an encoded prepare message of the given sequence.
+func newTestMessage(t *testing.T, key *ecdsa.PrivateKey, seq uint64) []byte {
+	msg := &message{Code: msgPrepare, Sequence: seq}
+	payload, err := msg.encode(func(hash []byte) ([]byte, error) { return crypto.Sign(hash, key) })
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	return payload
+}
+
+// Tests that messages are authenticated before being backlogged, and that the
+// ones of future sequences are only relayed once verified.
+func TestCoreBacklogAuthentication(t *testing.T) {
+	validator, _ := crypto.GenerateKey()
+	outsider, _ := crypto.GenerateKey()
+
+	backend := &relayBackend{validatorSet: []common.Address{crypto.PubkeyToAddress(validator.PublicKey)}, known: 1}
+	core := newCore(common.Address{}, time.Minute, backend)
+	defer core.stop()
+
+	// Before consensus starts, only messages of known validators are accepted
+	if err := core.handle(newTestMessage(t, outsider, 1)); err != errUnauthorized {
+		t.Fatalf("outsider message error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+	if err := core.handle(newTestMessage(t, validator, 1)); err != nil {
+		t.Fatalf("validator message rejected: %v", err)
+	}
+	if err := core.handle(newTestMessage(t, validator, 5)); err != errUnauthorized {
+		t.Fatalf("unverifiable message error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+	if len(core.backlog) != 1 {
+		t.Fatalf("backlog size mismatch: have %d, want 1", len(core.backlog))
+	}
+	core.startSequence(1, backend.validatorSet, common.Address{})
+
+	// Future messages are checked against the current validators, and held back
+	// from relaying until the sequence's validators are known
+	if err := core.handle(newTestMessage(t, outsider, 2)); err != errUnauthorized {
+		t.Fatalf("outsider future message error mismatch: have %v, want %v", err, errUnauthorized)
+	}
+	future := newTestMessage(t, validator, 2)
+	if err := core.handle(future); err != errFutureMessage {
+		t.Fatalf("future message error mismatch: have %v, want %v", err, errFutureMessage)
+	}
+	if len(core.backlog) != 1 || len(backend.relayed) != 0 {
+		t.Fatalf("backlog/relay mismatch: have %d/%d, want 1/0", len(core.backlog), len(backend.relayed))
+	}
+	backend.known = 2
+	core.startSequence(2, backend.validatorSet, common.Address{})
+
+	if len(backend.relayed) != 1 || string(backend.relayed[0]) != string(future) {
+		t.Fatalf("verified future message not relayed: %d relayed", len(backend.relayed))
+	}
+}
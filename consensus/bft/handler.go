@@ -0,0 +1,133 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"fmt"
+
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ProtocolName       = "bft"
+	ProtocolVersion    = 1
+	ProtocolLength     = 1
+	ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+)
+
+// bft protocol message codes
+const (
+	ConsensusMsg = 0x00
+)
+
+// peer is a remote node speaking the consensus protocol.
+type peer struct {
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+}
+
+// Protocols returns the p2p sub-protocol used to gossip consensus messages
+// between the validators. Non-validator nodes run it too, relaying messages
+// between validators that aren't directly connected.
+func (b *BFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run:     b.runPeer,
+	}}
+}
+
+// runPeer registers a remote node and processes its consensus messages until
+// the connection is torn down.
+func (b *BFT) runPeer(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	id := p.ID()
+
+	b.peersLock.Lock()
+	b.peers[id] = &peer{Peer: p, rw: rw}
+	b.peersLock.Unlock()
+
+	defer func() {
+		b.peersLock.Lock()
+		delete(b.peers, id)
+		b.peersLock.Unlock()
+	}()
+	p.Log().Debug("BFT peer connected")
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			p.Log().Debug("BFT peer disconnected", "err", err)
+			return err
+		}
+		if msg.Size > ProtocolMaxMsgSize {
+			msg.Discard()
+			return fmt.Errorf("message too large: %v > %v", msg.Size, ProtocolMaxMsgSize)
+		}
+		switch msg.Code {
+		case ConsensusMsg:
+			var payload []byte
+			if err := msg.Decode(&payload); err != nil {
+				return fmt.Errorf("invalid consensus message: %v", err)
+			}
+			// Skip anything already seen, otherwise process and relay
+			hash := crypto.Keccak256Hash(payload)
+			if _, known := b.messages.Get(hash); known {
+				continue
+			}
+			b.messages.Add(hash, struct{}{})
+
+			if err := b.core.handle(payload); err != nil {
+				p.Log().Trace("Dropped consensus message", "err", err)
+				continue
+			}
+			b.gossip(payload, id)
+
+		default:
+			msg.Discard()
+			return fmt.Errorf("invalid message code: %v", msg.Code)
+		}
+	}
+}
+
+// broadcast implements backend, gossiping a locally created consensus message.
+func (b *BFT) broadcast(payload []byte) {
+	b.messages.Add(crypto.Keccak256Hash(payload), struct{}{})
+	b.gossip(payload, discover.NodeID{})
+}
+
+// gossip sends a consensus message to all connected peers apart from the one it
+// was received from.
+func (b *BFT) gossip(payload []byte, skip discover.NodeID) {
+	b.peersLock.RLock()
+	defer b.peersLock.RUnlock()
+
+	for id, p := range b.peers {
+		if id == skip {
+			continue
+		}
+		go func(p *peer) {
+			if err := p2p.Send(p.rw, ConsensusMsg, payload); err != nil {
+				log.Trace("Failed to send consensus message", "peer", p.ID(), "err", err)
+			}
+		}(p)
+	}
+}
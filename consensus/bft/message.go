@@ -0,0 +1,107 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/rlp"
+)
+
+// Consensus message codes of the three phase commit and the round changes.
+const (
+	msgPreprepare uint64 = iota
+	msgPrepare
+	msgCommit
+	msgRoundChange
+)
+
+var errInvalidMessage = errors.New("invalid consensus message")
+
+// message is a signed consensus message exchanged between validators.
+type message struct {
+	Code          uint64      // Phase of the consensus the message belongs to
+	Sequence      uint64      // Block number being agreed upon
+	Round         uint64      // Round within the sequence, bumped on proposer failure
+	Digest        common.Hash // Proposal hash being prepared or committed
+	Proposal      []byte      // RLP encoded block, only in pre-prepares
+	CommittedSeal []byte      // Validator signature over the commit hash, only in commits
+	Signature     []byte      // Validator signature over the rest of the message
+
+	sender  common.Address // Validator that signed the message, recovered on decode
+	payload []byte         // Encoded message as received from the network
+	relayed bool           // Whecer the message was relayed to the network
+}
+
+// sigHash returns the hash of the message the sender signs.
+func (m *message) sigHash() ([]byte, error) {
+	blob, err := rlp.EncodeToBytes(&message{
+		Code:          m.Code,
+		Sequence:      m.Sequence,
+		Round:         m.Round,
+		Digest:        m.Digest,
+		Proposal:      m.Proposal,
+		CommittedSeal: m.CommittedSeal,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(blob), nil
+}
+
+// encode signs the message with the given signer function and returns the
+// wire encoding of it.
+func (m *message) encode(sign func([]byte) ([]byte, error)) ([]byte, error) {
+	hash, err := m.sigHash()
+	if err != nil {
+		return nil, err
+	}
+	if m.Signature, err = sign(hash); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(m)
+}
+
+// decodeMessage parses a consensus message off the wire and recovers the
+// validator that signed it.
+func decodeMessage(payload []byte) (*message, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(payload, msg); err != nil {
+		return nil, err
+	}
+	if msg.Code > msgRoundChange {
+		return nil, errInvalidMessage
+	}
+	hash, err := msg.sigHash()
+	if err != nil {
+		return nil, err
+	}
+	if msg.sender, err = recoverAddress(hash, msg.Signature); err != nil {
+		return nil, err
+	}
+	msg.payload = payload
+	return msg, nil
+}
+
+// String implements fmt.Stringer.
+func (m *message) String() string {
+	names := []string{"PRE-PREPARE", "PREPARE", "COMMIT", "ROUND-CHANGE"}
+	return fmt.Sprintf("%s{seq: %d, round: %d, from: %x}", names[m.Code], m.Sequence, m.Round, m.sender[:4])
+}
@@ -117,3 +117,78 @@ func (api *API) Discard(address common.Address) {
 
 	delete(api.clique.proposals, address)
 }
+
+// statusBlocks is the number of recent blocks inspected for the signer status.
+const statusBlocks = 64
+
+// SignerStatus is the recent sealing activity of a single signer.
+type SignerStatus struct {
+	Sealed    int    `json:"sealed"`    // Number of recent blocks sealed by the signer
+	InTurn    int    `json:"inTurn"`    // Number of recent blocks sealed in-turn
+	OutOfTurn int    `json:"outOfTurn"` // Number of recent blocks sealed out-of-turn
+	LastBlock uint64 `json:"lastBlock"` // Number of the last block sealed by the signer (0 = none recently)
+}
+
+// Status is the recent sealing activity of the signers and the pending votes.
+type Status struct {
+	Number     uint64                           `json:"number"`               // Head block the status was assembled at
+	NumBlocks  uint64                           `json:"numBlocks"`            // Number of recent blocks inspected
+	Signers    map[common.Address]*SignerStatus `json:"signers"`              // Sealing activity of current (and recently removed) signers
+	Votes      []*Vote                          `json:"votes"`                // Votes cast in the current epoch in chronological order
+	Tally      map[common.Address]Tally         `json:"tally"`                // Current tally of the votes
+	Governance *common.Address                  `json:"governance,omitempty"` // Governance contract managing the signers, if any
+}
+
+// Status reports the sealing activity of the signers over the recent blocks,
+// along with the votes and tally pending in the current epoch.
+func (api *API) Status() (*Status, error) {
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{
+		Number:     header.Number.Uint64(),
+		Signers:    make(map[common.Address]*SignerStatus),
+		Votes:      make([]*Vote, len(snap.Votes)),
+		Tally:      make(map[common.Address]Tally),
+		Governance: api.clique.config.Governance,
+	}
+	copy(status.Votes, snap.Votes)
+	for address, tally := range snap.Tally {
+		status.Tally[address] = tally
+	}
+	for _, signer := range snap.signers() {
+		status.Signers[signer] = new(SignerStatus)
+	}
+	// Walk the recent blocks backwards, accumulating the sealing stats
+	for status.NumBlocks < statusBlocks && header.Number.Uint64() > 0 {
+		signer, err := ecrecover(header, api.clique.signatures)
+		if err != nil {
+			return nil, err
+		}
+		stats, ok := status.Signers[signer]
+		if !ok {
+			stats = new(SignerStatus)
+			status.Signers[signer] = stats
+		}
+		stats.Sealed++
+		if header.Difficulty.Cmp(diffInTurn) == 0 {
+			stats.InTurn++
+		} else {
+			stats.OutOfTurn++
+		}
+		if stats.LastBlock == 0 {
+			stats.LastBlock = header.Number.Uint64()
+		}
+		status.NumBlocks++
+
+		if header = api.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+			break
+		}
+	}
+	return status, nil
+}
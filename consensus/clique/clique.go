@@ -48,6 +48,9 @@ const (
 	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
 
 	wiggleTime = 500 * time.Millisecond // Random delay (per signer) to allow concurrent signers
+
+	signRetryDelay    = 250 * time.Millisecond // Initial delay before retrying a failed signing request
+	signRetryMaxDelay = 8 * time.Second        // Maximum delay between signing request retries
 )
 
 // Clique proof-of-authority protocol constants.
@@ -203,9 +206,10 @@ type Clique struct {
 
 	proposals map[common.Address]bool // Current list of proposals we are pushing
 
-	signer common.Address // ecchain address of the signing key
-	signFn SignerFn       // Signer function to authorize hashes with
-	lock   sync.RWMutex   // Protects the signer fields
+	signer  common.Address // ecchain address of the signing key
+	signFn  SignerFn       // Signer function to authorize hashes with
+	stateFn StateFn        // State accessor to read the governance contract with
+	lock    sync.RWMutex   // Protects the signer fields
 }
 
 // New creates a Clique proof-of-authority consensus engine with the initial
@@ -287,6 +291,10 @@ func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
 		return errInvalidCheckpointVote
 	}
+	// Signers managed by a governance contract can't be voted on
+	if c.config.Governance != nil && (header.Coinbase != (common.Address{}) || !bytes.Equal(header.Nonce[:], nonceDropVote)) {
+		return errGovernanceVote
+	}
 	// Check that the extra-data contains both the vanity and signature
 	if len(header.Extra) < extraVanity {
 		return errMissingVanity
@@ -353,7 +361,7 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *type
 		return err
 	}
 	// If the block is a checkpoint block, verify the signer list
-	if number%c.config.Epoch == 0 {
+	if number%c.config.Epoch == 0 && c.config.Governance == nil {
 		signers := make([]byte, len(snap.Signers)*common.AddressLength)
 		for i, signer := range snap.signers() {
 			copy(signers[i*common.AddressLength:], signer[:])
@@ -363,6 +371,25 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *type
 			return errInvalidCheckpointSigners
 		}
 	}
+	if number%c.config.Epoch == 0 && c.config.Governance != nil {
+		if len(header.Extra) == extraVanity+extraSeal {
+			return errInvalidCheckpointSigners
+		}
+		// Governance managed lists can only be checked against the parent state.
+		// Engines without state access (light clients) can't ever verify them,
+		// so refuse the checkpoint instead of trusting the header. If the parent
+		// state isn't available yet (it's imported in the same batch), the list
+		// is checked by Finalize before the block is written.
+		statedb, err := c.governanceState(parent)
+		if err == errNoGovernanceState {
+			return err
+		}
+		if err == nil {
+			if err := c.verifyGovernanceCheckpoint(header, parent, statedb, snap); err != nil {
+				return err
+			}
+		}
+	}
 	// All basic checks passed, verify the seal and return
 	return c.verifySeal(chain, header, parents)
 }
@@ -515,7 +542,7 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if number%c.config.Epoch != 0 && c.config.Governance == nil {
 		c.lock.RLock()
 
 		// Gather all the proposals that make sense voting on
@@ -545,8 +572,21 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	}
 	header.Extra = header.Extra[:extraVanity]
 
+	// Checkpoints carry the signer list, governance contract read from the parent
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
 	if number%c.config.Epoch == 0 {
-		for _, signer := range snap.signers() {
+		signers := snap.signers()
+		if c.config.Governance != nil {
+			statedb, err := c.governanceState(parent)
+			if err != nil {
+				return err
+			}
+			signers = c.governanceSigners(statedb, parent, snap)
+		}
+		for _, signer := range signers {
 			header.Extra = append(header.Extra, signer[:]...)
 		}
 	}
@@ -556,10 +596,6 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	header.MixDigest = common.Hash{}
 
 	// Ensure the timestamp has the correct delay
-	parent := chain.GetHeader(header.ParentHash, number-1)
-	if parent == nil {
-		return consensus.ErrUnknownAncestor
-	}
 	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(c.config.Period))
 	if header.Time.Int64() < time.Now().Unix() {
 		header.Time = big.NewInt(time.Now().Unix())
@@ -570,6 +606,24 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 // Finalize implements consensus.Engine, ensuring no uncles are set, nor block
 // rewards given, and returns the final block.
 func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// Checkpoints of governance managed chains must match the contract
+	if number := header.Number.Uint64(); number%c.config.Epoch == 0 && c.config.Governance != nil {
+		parent := chain.GetHeader(header.ParentHash, number-1)
+		if parent == nil {
+			return nil, consensus.ErrUnknownAncestor
+		}
+		snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return nil, err
+		}
+		statedb, err := c.governanceState(parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.verifyGovernanceCheckpoint(header, parent, statedb, snap); err != nil {
+			return nil, err
+		}
+	}
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
@@ -643,8 +697,8 @@ func (c *Clique) Seal(chain consensus.ChainReader, block *types.Block, stop <-ch
 	case <-time.After(delay):
 	}
 	// Sign all the things!
-	sighash, err := signFn(accounts.Account{Address: signer}, sigHash(header).Bytes())
-	if err != nil {
+	sighash, err := c.sign(signFn, signer, header, stop)
+	if sighash == nil || err != nil {
 		return nil, err
 	}
 	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
@@ -652,6 +706,33 @@ func (c *Clique) Seal(chain consensus.ChainReader, block *types.Block, stop <-ch
 	return block.WithSeal(header), nil
 }
 
+// sign requests the signature of the header from the signer callback. As the
+// key may be held by a hardware wallet or a remote signer, failed requests are
+// retried with an exponential backoff until they succeed, the signer reports
+// the operation as unsupported, or sealing is aborted (returning a nil seal).
+func (c *Clique) sign(signFn SignerFn, signer common.Address, header *types.Header, stop <-chan struct{}) ([]byte, error) {
+	delay := signRetryDelay
+	for {
+		sighash, err := signFn(accounts.Account{Address: signer}, sigHash(header).Bytes())
+		if err == nil {
+			return sighash, nil
+		}
+		if err == accounts.ErrNotSupported {
+			return nil, err
+		}
+		log.Warn("Signer unavailable, retrying", "signer", signer, "number", header.Number, "retry", common.PrettyDuration(delay), "err", err)
+
+		select {
+		case <-stop:
+			return nil, nil
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > signRetryMaxDelay {
+			delay = signRetryMaxDelay
+		}
+	}
+}
+
 // CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
 // that a new block should have based on the previous blocks in the chain and the
 // current signer.
@@ -0,0 +1,73 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/accounts"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Tests that signing requests are retried while the signer is unavailable, and
+// that unsupported signers and aborted sealing don't block.
+func TestSignRetry(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), Difficulty: big.NewInt(1), Extra: make([]byte, extraVanity+extraSeal)}
+
+	engine := &Clique{}
+
+	// A signer that's offline for the first few requests must eventually sign
+	failures := 2
+	signFn := func(account accounts.Account, hash []byte) ([]byte, error) {
+		if failures > 0 {
+			failures--
+			return nil, errors.New("signer offline")
+		}
+		return crypto.Sign(hash, key)
+	}
+	sig, err := engine.sign(signFn, signer, header, make(chan struct{}))
+	if err != nil {
+		t.Fatalf("failed to sign after retries: %v", err)
+	}
+	copy(header.Extra[len(header.Extra)-extraSeal:], sig)
+	sigcache, _ := lru.NewARC(1)
+	if recovered, err := ecrecover(header, sigcache); err != nil || recovered != signer {
+		t.Fatalf("signer mismatch: have %x, want %x (err %v)", recovered, signer, err)
+	}
+	// Signers not supporting the operation must fail fast
+	unsupported := func(accounts.Account, []byte) ([]byte, error) { return nil, accounts.ErrNotSupported }
+	if _, err := engine.sign(unsupported, signer, header, make(chan struct{})); err != accounts.ErrNotSupported {
+		t.Fatalf("unsupported signer error mismatch: have %v, want %v", err, accounts.ErrNotSupported)
+	}
+	// Aborting the sealing must stop the retries
+	stop := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(stop)
+	}()
+	offline := func(accounts.Account, []byte) ([]byte, error) { return nil, errors.New("signer offline") }
+	if sig, err := engine.sign(offline, signer, header, stop); sig != nil || err != nil {
+		t.Fatalf("aborted signing mismatch: have %x/%v, want nil/nil", sig, err)
+	}
+}
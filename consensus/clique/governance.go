@@ -0,0 +1,129 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/state"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/log"
+)
+
+// maxGovernanceSigners is the maximum number of signers read from a governance
+// contract, capping the work a broken contract can cause at each checkpoint.
+const maxGovernanceSigners = 256
+
+var (
+	// governanceSignersSlot is the storage slot of the signer array in the
+	// governance contract. The contract's first state variable must be the
+	// dynamic `address[] signers` array.
+	governanceSignersSlot = common.Hash{}
+
+	// errNoGovernanceState is returned if the signers need to be read from the
+	// governance contract, but no state database was made available.
+	errNoGovernanceState = errors.New("governance contract state unavailable")
+
+	// errGovernanceVote is returned if a block casts a signer vote on a chain
+	// where the signers are managed by the governance contract.
+	errGovernanceVote = errors.New("signer vote on governance managed chain")
+)
+
+// StateFn is a callback function to retrieve the state database at a given
+// state root.
+type StateFn func(root common.Hash) (*state.StateDB, error)
+
+// SetStateReader injects the state accessor the engine uses to read the signer
+// set from the governance contract.
+func (c *Clique) SetStateReader(stateFn StateFn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stateFn = stateFn
+}
+
+// governanceState retrieves the state of the given block to read the governance
+// contract from. If the engine has no state access at all, errNoGovernanceState
+// is returned, otherwise the error of the state accessor, if any.
+func (c *Clique) governanceState(header *types.Header) (*state.StateDB, error) {
+	c.lock.RLock()
+	stateFn := c.stateFn
+	c.lock.RUnlock()
+
+	if stateFn == nil {
+		return nil, errNoGovernanceState
+	}
+	return stateFn(header.Root)
+}
+
+// governanceSigners reads the signer set from the governance contract in the
+// given state of the block, in ascending order. If the contract doesn't list any
+// signers, the current ones are kept to avoid halting the chain.
+func (c *Clique) governanceSigners(statedb *state.StateDB, header *types.Header, snap *Snapshot) []common.Address {
+	signers := readGovernanceSigners(statedb, *c.config.Governance)
+	if len(signers) == 0 {
+		log.Warn("Governance contract lists no signers, keeping current ones", "contract", *c.config.Governance, "number", header.Number)
+		return snap.signers()
+	}
+	return signers
+}
+
+// verifyGovernanceCheckpoint checks that the signer list of a checkpoint block
+// is the one listed by the governance contract in the parent state.
+func (c *Clique) verifyGovernanceCheckpoint(header *types.Header, parent *types.Header, statedb *state.StateDB, snap *Snapshot) error {
+	signers := c.governanceSigners(statedb, parent, snap)
+
+	expect := make([]byte, 0, len(signers)*common.AddressLength)
+	for _, signer := range signers {
+		expect = append(expect, signer[:]...)
+	}
+	if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], expect) {
+		return errInvalidCheckpointSigners
+	}
+	return nil
+}
+
+// readGovernanceSigners reads the `address[] signers` array from the storage of
+// the governance contract, returning the unique entries in ascending order.
+func readGovernanceSigners(statedb *state.StateDB, contract common.Address) []common.Address {
+	length := statedb.Geecate(contract, governanceSignersSlot).Big().Uint64()
+	if length > maxGovernanceSigners {
+		length = maxGovernanceSigners
+	}
+	// Dynamic array items are laid out sequentially from the hash of the slot
+	base := crypto.Keccak256Hash(governanceSignersSlot[:]).Big()
+
+	unique := make(map[common.Address]struct{})
+	signers := make([]common.Address, 0, length)
+	for i := uint64(0); i < length; i++ {
+		slot := common.BigToHash(new(big.Int).Add(base, new(big.Int).SetUint64(i)))
+		signer := common.BytesToAddress(statedb.Geecate(contract, slot).Bytes())
+
+		if _, ok := unique[signer]; ok || signer == (common.Address{}) {
+			continue
+		}
+		unique[signer] = struct{}{}
+		signers = append(signers, signer)
+	}
+	sort.Slice(signers, func(i, j int) bool { return bytes.Compare(signers[i][:], signers[j][:]) < 0 })
+	return signers
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/core/state"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// governanceContract is the address of the governance contract in the tests.
+var governanceContract = common.HexToAddress("0x000000000000000000000000000000000000c0de")
+
+// newGovernanceState creates a state database with the given entries in the
+// signer array of the governance contract.
+func newGovernanceState(contract common.Address, entries []common.Address) *state.StateDB {
+	db, _ := ecdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+
+	statedb.Seecate(contract, governanceSignersSlot, common.BigToHash(big.NewInt(int64(len(entries)))))
+
+	base := crypto.Keccak256Hash(governanceSignersSlot[:]).Big()
+	for i, entry := range entries {
+		slot := common.BigToHash(new(big.Int).Add(base, big.NewInt(int64(i))))
+		statedb.Seecate(contract, slot, common.BytesToHash(entry[:]))
+	}
+	return statedb
+}
+
+// sortedAddresses returns the addresses in ascending order.
+func sortedAddresses(addresses ...common.Address) []common.Address {
+	sorted := append([]common.Address{}, addresses...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i][:], sorted[j][:]) < 0 })
+	return sorted
+}
+
+// checkpointExtra assembles the extra-data of a checkpoint listing the signers.
+func checkpointExtra(signers []common.Address) []byte {
+	extra := make([]byte, extraVanity, extraVanity+len(signers)*common.AddressLength+extraSeal)
+	for _, signer := range signers {
+		extra = append(extra, signer[:]...)
+	}
+	return append(extra, make([]byte, extraSeal)...)
+}
+
+// governanceChainReader implements consensus.ChainReader to access a single
+// parent header. All other methods and requests will panic.
+type governanceChainReader struct {
+	parent *types.Header
+}
+
+func (r *governanceChainReader) Config() *params.ChainConfig               { return params.AllCliqueProtocolChanges }
+func (r *governanceChainReader) CurrentHeader() *types.Header              { panic("not supported") }
+func (r *governanceChainReader) GetHeaderByNumber(uint64) *types.Header    { panic("not supported") }
+func (r *governanceChainReader) GetHeaderByHash(common.Hash) *types.Header { panic("not supported") }
+func (r *governanceChainReader) GetBlock(common.Hash, uint64) *types.Block { panic("not supported") }
+func (r *governanceChainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if r.parent != nil && r.parent.Hash() == hash && r.parent.Number.Uint64() == number {
+		return r.parent
+	}
+	return nil
+}
+
+// Tests that the signer array is read from the governance contract storage the
+// way solidity lays out an `address[]` in the first slot.
+func TestGovernanceSigners(t *testing.T) {
+	contract := governanceContract
+	entries := []common.Address{
+		common.HexToAddress("0x3333333333333333333333333333333333333333"),
+		common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		{}, // unset entries are skipped
+		common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		common.HexToAddress("0x1111111111111111111111111111111111111111"), // duplicates too
+	}
+	statedb := newGovernanceState(contract, entries)
+
+	want := []common.Address{
+		common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		common.HexToAddress("0x3333333333333333333333333333333333333333"),
+	}
+	if have := readGovernanceSigners(statedb, contract); !reflect.DeepEqual(have, want) {
+		t.Errorf("signer mismatch: have %x, want %x", have, want)
+	}
+	if have := readGovernanceSigners(statedb, common.Address{}); len(have) != 0 {
+		t.Errorf("signers read from empty contract: %x", have)
+	}
+}
+
+// Tests that checkpoints of governance managed chains are only finalized if
+// they list the signers of the contract in the parent state.
+func TestGovernanceCheckpointFinalize(t *testing.T) {
+	accounts := newTesterAccountPool()
+	signers := sortedAddresses(accounts.address("A"), accounts.address("B"))
+
+	config := &params.CliqueConfig{Period: 1, Epoch: 4, Governance: &governanceContract}
+	db, _ := ecdb.NewMemDatabase()
+	engine := New(config, db)
+
+	parent := &types.Header{Number: big.NewInt(3), Time: big.NewInt(0), Extra: make([]byte, extraVanity+extraSeal)}
+	engine.recents.Add(parent.Hash(), newSnapshot(config, engine.signatures, 3, parent.Hash(), signers))
+	chain := &governanceChainReader{parent: parent}
+
+	newCheckpoint := func(listed []common.Address) *types.Header {
+		return &types.Header{Number: big.NewInt(4), ParentHash: parent.Hash(), Time: big.NewInt(1), Extra: checkpointExtra(listed)}
+	}
+	finalize := func(header *types.Header) error {
+		statedb := newGovernanceState(common.Address{}, nil)
+		_, err := engine.Finalize(chain, header, statedb, nil, nil, nil)
+		return err
+	}
+	// Without access to the state, checkpoints can't be verified
+	if err := finalize(newCheckpoint(signers)); err != errNoGovernanceState {
+		t.Fatalf("stateless finalize error mismatch: have %v, want %v", err, errNoGovernanceState)
+	}
+	// The contract replaces the signers with a new set, which must be listed
+	replaced := sortedAddresses(accounts.address("B"), accounts.address("C"), accounts.address("D"))
+	engine.SetStateReader(func(root common.Hash) (*state.StateDB, error) {
+		if root != parent.Root {
+			t.Errorf("state root mismatch: have %x, want %x", root, parent.Root)
+		}
+		return newGovernanceState(governanceContract, []common.Address{replaced[2], replaced[0], replaced[1]}), nil
+	})
+	if err := finalize(newCheckpoint(replaced)); err != nil {
+		t.Fatalf("failed to finalize valid checkpoint: %v", err)
+	}
+	for i, listed := range [][]common.Address{signers, replaced[:2], {replaced[1], replaced[0], replaced[2]}} {
+		if err := finalize(newCheckpoint(listed)); err != errInvalidCheckpointSigners {
+			t.Errorf("test %d: invalid checkpoint error mismatch: have %v, want %v", i, err, errInvalidCheckpointSigners)
+		}
+	}
+	// Checkpoints of unknown parents can't be verified, other blocks aren't checked
+	orphan := newCheckpoint(replaced)
+	orphan.ParentHash = common.Hash{0x01}
+	if err := finalize(orphan); err != consensus.ErrUnknownAncestor {
+		t.Errorf("orphan checkpoint error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+	if err := finalize(&types.Header{Number: big.NewInt(5), ParentHash: common.Hash{0x01}, Extra: make([]byte, extraVanity+extraSeal)}); err != nil {
+		t.Errorf("failed to finalize non-checkpoint block: %v", err)
+	}
+}
+
+// Tests that header verification checks the signer list of governance checkpoints
+// against the contract if the parent state is available, refuses them if the
+// engine can't access state at all, and leaves them to Finalize otherwise.
+func TestGovernanceCheckpointVerifyHeader(t *testing.T) {
+	accounts := newTesterAccountPool()
+	signers := sortedAddresses(accounts.address("A"), accounts.address("B"))
+
+	config := &params.CliqueConfig{Period: 1, Epoch: 4, Governance: &governanceContract}
+	db, _ := ecdb.NewMemDatabase()
+	engine := New(config, db)
+
+	parent := &types.Header{Number: big.NewInt(3), Time: big.NewInt(0), Extra: make([]byte, extraVanity+extraSeal)}
+	engine.recents.Add(parent.Hash(), newSnapshot(config, engine.signatures, 3, parent.Hash(), signers))
+	chain := &governanceChainReader{parent: parent}
+
+	// The in-turn signer of the checkpoint is the first in the sorted list
+	inturn := "A"
+	if signers[0] != accounts.address("A") {
+		inturn = "B"
+	}
+	verify := func(listed []common.Address) error {
+		header := &types.Header{Number: big.NewInt(4), ParentHash: parent.Hash(), Time: big.NewInt(1), Difficulty: diffInTurn, Extra: checkpointExtra(listed)}
+		accounts.sign(header, inturn)
+		return engine.verifyCascadingFields(chain, header, nil)
+	}
+	replaced := sortedAddresses(accounts.address("B"), accounts.address("C"))
+
+	// Without access to the state, checkpoints are refused
+	if err := verify(replaced); err != errNoGovernanceState {
+		t.Fatalf("stateless verification error mismatch: have %v, want %v", err, errNoGovernanceState)
+	}
+	// Without the parent state, the list can't be checked yet
+	engine.SetStateReader(func(root common.Hash) (*state.StateDB, error) {
+		return nil, errors.New("missing state")
+	})
+	if err := verify(signers); err != nil {
+		t.Fatalf("failed to verify checkpoint without parent state: %v", err)
+	}
+	// With the parent state, only the contract's signers may be listed
+	engine.SetStateReader(func(root common.Hash) (*state.StateDB, error) {
+		return newGovernanceState(governanceContract, replaced), nil
+	})
+	if err := verify(replaced); err != nil {
+		t.Fatalf("failed to verify valid checkpoint: %v", err)
+	}
+	if err := verify(signers); err != errInvalidCheckpointSigners {
+		t.Errorf("invalid checkpoint error mismatch: have %v, want %v", err, errInvalidCheckpointSigners)
+	}
+}
+
+// Tests that governance checkpoints replace the signer set of the snapshot with
+// the one they list, ignoring the votes.
+func TestGovernanceSnapshotApply(t *testing.T) {
+	accounts := newTesterAccountPool()
+	config := &params.CliqueConfig{Period: 1, Epoch: 4, Governance: &governanceContract}
+	sigcache, _ := lru.NewARC(inmemorySignatures)
+
+	snap := newSnapshot(config, sigcache, 2, common.Hash{}, sortedAddresses(
+		accounts.address("A"), accounts.address("B"), accounts.address("C"), accounts.address("D"), accounts.address("E")))
+	snap.Recents[1] = accounts.address("C")
+	snap.Recents[2] = accounts.address("E")
+
+	headers := []*types.Header{
+		{Number: big.NewInt(3), Time: big.NewInt(0), Difficulty: big.NewInt(1), Extra: make([]byte, extraVanity+extraSeal)},
+		{Number: big.NewInt(4), Time: big.NewInt(0), Difficulty: big.NewInt(1), Extra: checkpointExtra(sortedAddresses(accounts.address("B"), accounts.address("D")))},
+	}
+	copy(headers[1].Nonce[:], nonceDropVote)
+	accounts.sign(headers[0], "A")
+	accounts.sign(headers[1], "B")
+
+	result, err := snap.apply(headers)
+	if err != nil {
+		t.Fatalf("failed to apply checkpoint: %v", err)
+	}
+	if want := sortedAddresses(accounts.address("B"), accounts.address("D")); !reflect.DeepEqual(result.signers(), want) {
+		t.Errorf("signer mismatch: have %x, want %x", result.signers(), want)
+	}
+	// With two signers left, only the last two blocks' signers are still recent
+	if want := map[uint64]common.Address{3: accounts.address("A"), 4: accounts.address("B")}; !reflect.DeepEqual(result.Recents, want) {
+		t.Errorf("recents mismatch: have %v, want %v", result.Recents, want)
+	}
+	if len(snap.Signers) != 5 {
+		t.Errorf("original snapshot modified: %d signers", len(snap.Signers))
+	}
+}
+
+// Tests that signer votes are rejected on chains where the signers are managed
+// by the governance contract.
+func TestGovernanceVoteRejected(t *testing.T) {
+	config := &params.CliqueConfig{Period: 1, Epoch: 4, Governance: &governanceContract}
+	db, _ := ecdb.NewMemDatabase()
+	engine := New(config, db)
+
+	tests := []struct {
+		coinbase common.Address
+		nonce    []byte
+	}{
+		{common.Address{0x01}, nonceAuthVote},
+		{common.Address{0x01}, nonceDropVote},
+		{common.Address{}, nonceAuthVote},
+	}
+	for i, tt := range tests {
+		header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), Coinbase: tt.coinbase, Extra: make([]byte, extraVanity+extraSeal)}
+		copy(header.Nonce[:], tt.nonce)
+
+		if err := engine.verifyHeader(nil, header, nil); err != errGovernanceVote {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, errGovernanceVote)
+		}
+	}
+	// Blocks without a vote must pass the check
+	header := &types.Header{Number: big.NewInt(1), Time: big.NewInt(0), Extra: make([]byte, extraVanity+extraSeal)}
+	copy(header.Nonce[:], nonceDropVote)
+	if err := engine.verifyHeader(nil, header, nil); err == errGovernanceVote {
+		t.Errorf("non-voting block rejected as vote")
+	}
+}
@@ -172,6 +172,9 @@ func (s *Snapshot) uncast(address common.Address, authorize bool) bool {
 
 // apply creates a new authorization snapshot by applying the given headers to
 // the original one.
+//
+// Note, the signer lists of governance checkpoints are taken as is. They must be
+// checked against the contract by the header verification or Finalize first.
 func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 	// Allow passing in no headers for cleaner code
 	if len(headers) == 0 {
@@ -215,6 +218,21 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		}
 		snap.Recents[number] = signer
 
+		// Governance checkpoints replace the signer set with the listed one
+		if number%s.config.Epoch == 0 && s.config.Governance != nil {
+			snap.Signers = make(map[common.Address]struct{})
+			for i := extraVanity; i < len(header.Extra)-extraSeal; i += common.AddressLength {
+				snap.Signers[common.BytesToAddress(header.Extra[i:i+common.AddressLength])] = struct{}{}
+			}
+			// Signer list may have shrunk, delete any leftover recent caches
+			limit := uint64(len(snap.Signers)/2 + 1)
+			for seen := range snap.Recents {
+				if seen+limit <= number {
+					delete(snap.Recents, seen)
+				}
+			}
+			continue
+		}
 		// Header authorized, discard any previous votes from the signer
 		for i, vote := range snap.Votes {
 			if vote.Signer == signer && vote.Address == header.Coinbase {
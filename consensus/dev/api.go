@@ -0,0 +1,154 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dev
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/core"
+	"github.com/ecchain/go-ecchain/event"
+)
+
+// mineTimeout is the time allowed for the miner to pick up a seal request and
+// for the sealed block to become the chain head.
+const mineTimeout = 5 * time.Second
+
+var (
+	// errNotMining is returned if blocks are requested while the miner is not
+	// running, so nobody picks up the seal requests.
+	errNotMining = errors.New("miner not running")
+
+	// errNoChainControl is returned if the chain the API was created for can't be
+	// rewound or followed.
+	errNoChainControl = errors.New("chain doesn't support developer controls")
+)
+
+// devChain is the chain functionality needed by the developer API, implemented
+// by core.BlockChain.
+type devChain interface {
+	consensus.ChainReader
+
+	SetHead(head uint64) error
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// API is a user facing RPC API to control block production and time on the
+// developer chain.
+type API struct {
+	chain consensus.ChainReader
+	dev   *Dev
+}
+
+// Mine seals the given number of blocks (1 if omitted), regardless of whecer
+// they contain transactions, and returns the new head number once they have
+// been imported.
+func (api *API) Mine(blocks *hexutil.Uint64) (hexutil.Uint64, error) {
+	chain, ok := api.chain.(devChain)
+	if !ok {
+		return 0, errNoChainControl
+	}
+	count := uint64(1)
+	if blocks != nil {
+		count = uint64(*blocks)
+	}
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	head := chain.CurrentHeader().Number.Uint64()
+	for target := head + count; head < target; {
+		select {
+		case api.dev.mine <- struct{}{}:
+		case <-time.After(mineTimeout):
+			return hexutil.Uint64(head), errNotMining
+		}
+		// Wait for the sealed block (or any other) to become the new head
+		for number := head; head <= number; {
+			select {
+			case ev := <-heads:
+				head = ev.Block.NumberU64()
+			case err := <-sub.Err():
+				return hexutil.Uint64(head), err
+			case <-time.After(mineTimeout):
+				return hexutil.Uint64(head), errNotMining
+			}
+		}
+	}
+	return hexutil.Uint64(head), nil
+}
+
+// SetAutomine switches between sealing blocks as soon as they contain any
+// transactions, and sealing them only when requested via Mine.
+func (api *API) SetAutomine(enabled bool) {
+	api.dev.lock.Lock()
+	defer api.dev.lock.Unlock()
+
+	api.dev.manual = !enabled
+}
+
+// SetTimestamp sets the block clock, so the next block is stamped with the given
+// unix time (or one second after its parent, whichever is later).
+func (api *API) SetTimestamp(timestamp uint64) {
+	api.dev.setClock(int64(timestamp))
+}
+
+// IncreaseTime moves the block clock forward by the given number of seconds,
+// returning the total adjustment compared to the local clock.
+func (api *API) IncreaseTime(seconds uint64) int64 {
+	return api.dev.adjust(int64(seconds))
+}
+
+// Snapshot records the current chain head and block clock, returning an id to
+// revert to them later.
+func (api *API) Snapshot() hexutil.Uint64 {
+	api.dev.lock.Lock()
+	defer api.dev.lock.Unlock()
+
+	api.dev.snapshots = append(api.dev.snapshots, snapshot{
+		number: api.chain.CurrentHeader().Number.Uint64(),
+		offset: api.dev.offset,
+	})
+	return hexutil.Uint64(len(api.dev.snapshots))
+}
+
+// Revert rewinds the chain and the block clock to the given snapshot. The
+// snapshot and all the ones taken after it are discarded. False is returned if
+// the snapshot is unknown.
+func (api *API) Revert(id hexutil.Uint64) (bool, error) {
+	chain, ok := api.chain.(devChain)
+	if !ok {
+		return false, errNoChainControl
+	}
+	api.dev.lock.Lock()
+	if id == 0 || int(id) > len(api.dev.snapshots) {
+		api.dev.lock.Unlock()
+		return false, nil
+	}
+	snap := api.dev.snapshots[id-1]
+	api.dev.snapshots = api.dev.snapshots[:id-1]
+	api.dev.offset = snap.offset
+	api.dev.lock.Unlock()
+
+	// Rewinding the chain rebuilds the pending block with the restored clock
+	if err := chain.SetHead(snap.number); err != nil {
+		return false, err
+	}
+	return true, nil
+}
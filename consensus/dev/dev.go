@@ -0,0 +1,266 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dev implements an instant sealing consensus engine for developer
+// chains, giving test suites full control over block production and time.
+package dev
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/core/state"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+var (
+	// errUnknownBlock is returned when verifying a header without a block number.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is not above
+	// the one of its parent.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+)
+
+// Dev is a consensus engine sealing blocks as soon as they contain transactions
+// or whenever explicitly requested, without any proof of work or authority. The
+// timestamps of the blocks follow an adjustable clock.
+type Dev struct {
+	config *params.DevConfig // Consensus engine configuration parameters
+
+	manual bool          // Whecer blocks are only sealed when requested
+	offset int64         // Seconds the block clock is ahead of the local one
+	mine   chan struct{} // Requests to seal the pending block, regardless of contents
+
+	snapshots []snapshot // Chain heads to revert to, indexed by snapshot id - 1
+	refresh   func()     // Callback to rebuild the pending block after clock changes
+
+	lock sync.RWMutex // Protects the clock, mode and snapshot fields
+}
+
+// snapshot is a chain head a developer chain can be reverted to.
+type snapshot struct {
+	number uint64 // Number of the head block
+	offset int64  // Clock adjustment at the time of the snapshot
+}
+
+// New creates a developer consensus engine.
+func New(config *params.DevConfig) *Dev {
+	return &Dev{
+		config: config,
+		manual: config.Manual,
+		mine:   make(chan struct{}),
+	}
+}
+
+// SetRefresh sets a callback invoked whenever the block clock is adjusted, so
+// the miner can rebuild its pending block with the new timestamp.
+func (d *Dev) SetRefresh(refresh func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.refresh = refresh
+}
+
+// adjust moves the block clock by the given number of seconds, notifying the
+// miner to restamp its pending block.
+func (d *Dev) adjust(seconds int64) int64 {
+	d.lock.Lock()
+	d.offset += seconds
+	offset, refresh := d.offset, d.refresh
+	d.lock.Unlock()
+
+	if refresh != nil {
+		refresh()
+	}
+	return offset
+}
+
+// setClock sets the block clock to the given unix time, notifying the miner to
+// restamp its pending block.
+func (d *Dev) setClock(timestamp int64) {
+	d.lock.Lock()
+	d.offset = timestamp - time.Now().Unix()
+	refresh := d.refresh
+	d.lock.Unlock()
+
+	if refresh != nil {
+		refresh()
+	}
+}
+
+// now returns the current time of the adjustable block clock.
+func (d *Dev) now() uint64 {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return uint64(time.Now().Unix() + d.offset)
+}
+
+// Author implements consensus.Engine, returning the coinbase of the block.
+func (d *Dev) Author(header *types.Header) (common.Address, error) {
+	return header.Coinbase, nil
+}
+
+// VerifyHeader implements consensus.Engine, checking whecer a header conforms
+// to the consensus rules.
+func (d *Dev) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return d.verifyHeader(chain, header, nil)
+}
+
+// VerifyHeaders implements consensus.Engine, verifying a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel
+// to retrieve the async verifications (the order is that of the input slice).
+func (d *Dev) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := d.verifyHeader(chain, header, headers[:i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whecer a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database.
+func (d *Dev) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if header.Time.Cmp(parent.Time) <= 0 {
+		return errInvalidTimestamp
+	}
+	if header.Difficulty == nil || header.Difficulty.Cmp(common.Big1) != 0 {
+		return errInvalidDifficulty
+	}
+	if header.UncleHash != types.CalcUncleHash(nil) {
+		return errInvalidUncleHash
+	}
+	return nil
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (d *Dev) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine. Developer blocks carry no seal, so
+// all of them are accepted.
+func (d *Dev) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	return nil
+}
+
+// Prepare implements consensus.Engine, stamping the header with the time of the
+// block clock and the fixed difficulty.
+func (d *Dev) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = new(big.Int).SetUint64(d.now())
+	if header.Time.Cmp(parent.Time) <= 0 {
+		header.Time = new(big.Int).Add(parent.Time, common.Big1)
+	}
+	header.Difficulty = d.CalcDifficulty(chain, header.Time.Uint64(), parent)
+	return nil
+}
+
+// Finalize implements consensus.Engine. There are no block rewards on developer
+// chains, so the state remains as is and uncles are dropped.
+func (d *Dev) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Seal implements consensus.Engine. Unless in manual mode, blocks containing
+// transactions are sealed right away, empty ones after the configured period.
+// Any block is sealed when requested through Mine.
+func (d *Dev) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	d.lock.RLock()
+	manual := d.manual
+	d.lock.RUnlock()
+
+	if !manual && len(block.Transactions()) > 0 {
+		return block, nil
+	}
+	var timeout <-chan time.Time
+	if !manual && d.config.Period > 0 {
+		timeout = time.After(time.Duration(d.config.Period) * time.Second)
+	}
+	select {
+	case <-d.mine:
+		return block, nil
+	case <-timeout:
+		return block, nil
+	case <-stop:
+		return nil, nil
+	}
+}
+
+// CalcDifficulty implements consensus.Engine, returning the fixed difficulty of
+// developer blocks.
+func (d *Dev) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return big.NewInt(1)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to control
+// block production and time on the developer chain.
+func (d *Dev) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "dev",
+		Version:   "1.0",
+		Service:   &API{chain: chain, dev: d},
+		Public:    false,
+	}}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dev
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/core"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/core/vm"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/params"
+)
+
+var (
+	testKey, _  = crypto.GenerateKey()
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// newTestChain creates a developer chain with a funded test account.
+func newTestChain(t *testing.T, config *params.DevConfig) (*Dev, *core.BlockChain, ecdb.Database) {
+	db, _ := ecdb.NewMemDatabase()
+	genesis := core.DeveloperGenesisBlock(config.Period, config.Manual, testAddress)
+	genesis.MustCommit(db)
+
+	engine := New(config)
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return engine, chain, db
+}
+
+// newTestBlock creates a block on top of the chain head, optionally holding a
+// single transaction.
+func newTestBlock(engine *Dev, chain *core.BlockChain, db ecdb.Database, withTx bool) *types.Block {
+	blocks, _ := core.GenerateChain(chain.Config(), chain.CurrentBlock(), engine, db, 1, func(i int, block *core.BlockGen) {
+		if withTx {
+			tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{}, big.NewInt(1), params.TxGas, nil, nil), types.HomesteadSigner{}, testKey)
+			block.AddTx(tx)
+		}
+	})
+	return blocks[0]
+}
+
+// Tests that blocks are sealed right away if they contain transactions, and
+// otherwise only on request.
+func TestSeal(t *testing.T) {
+	for _, manual := range []bool{false, true} {
+		engine, chain, db := newTestChain(t, &params.DevConfig{Manual: manual})
+
+		for _, withTx := range []bool{false, true} {
+			block := newTestBlock(engine, chain, db, withTx)
+
+			sealed := make(chan *types.Block, 1)
+			go func() {
+				result, _ := engine.Seal(chain, block, make(chan struct{}))
+				sealed <- result
+			}()
+			select {
+			case <-sealed:
+				if manual || !withTx {
+					t.Errorf("manual %v, txs %v: block sealed without request", manual, withTx)
+				}
+				continue
+			case <-time.After(100 * time.Millisecond):
+				if !manual && withTx {
+					t.Errorf("manual %v, txs %v: block not sealed", manual, withTx)
+				}
+			}
+			engine.mine <- struct{}{}
+			if result := <-sealed; result == nil || result.Hash() != block.Hash() {
+				t.Errorf("manual %v, txs %v: sealed block mismatch", manual, withTx)
+			}
+		}
+		chain.Stop()
+	}
+}
+
+// Tests that the developer API seals blocks on request, adjusts the block clock
+// and reverts the chain to earlier snapshots.
+func TestAPI(t *testing.T) {
+	engine, chain, _ := newTestChain(t, &params.DevConfig{Manual: true})
+	defer chain.Stop()
+
+	// Run a minimal miner sealing empty blocks on top of the chain head, which
+	// restarts sealing whenever the block clock is adjusted
+	var (
+		lock  sync.Mutex
+		abort = make(chan struct{})
+		stop  = make(chan struct{})
+	)
+	refresh := func() {
+		lock.Lock()
+		close(abort)
+		abort = make(chan struct{})
+		lock.Unlock()
+	}
+	engine.SetRefresh(refresh)
+	defer func() {
+		close(stop)
+		refresh()
+	}()
+	go func() {
+		for {
+			lock.Lock()
+			current := abort
+			lock.Unlock()
+
+			parent := chain.CurrentBlock()
+			header := &types.Header{
+				ParentHash: parent.Hash(),
+				Number:     new(big.Int).Add(parent.Number(), common.Big1),
+				GasLimit:   parent.GasLimit(),
+				Root:       parent.Root(),
+			}
+			if err := engine.Prepare(chain, header); err != nil {
+				t.Errorf("failed to prepare header: %v", err)
+				return
+			}
+			block := types.NewBlock(header, nil, nil, nil)
+			sealed, _ := engine.Seal(chain, block, current)
+			if sealed == nil {
+				select {
+				case <-stop:
+					return
+				default:
+					continue
+				}
+			}
+			if _, err := chain.InsertChain(types.Blocks{sealed}); err != nil {
+				t.Errorf("failed to insert block: %v", err)
+				return
+			}
+		}
+	}()
+	api := &API{chain: chain, dev: engine}
+
+	snap := api.Snapshot()
+	blocks := hexutil.Uint64(3)
+	if head, err := api.Mine(&blocks); err != nil || head != 3 {
+		t.Fatalf("mined head mismatch: have %d, want 3 (err %v)", head, err)
+	}
+	// Jump ahead in time and ensure the next block follows
+	future := uint64(time.Now().Add(24 * time.Hour).Unix())
+	api.SetTimestamp(future)
+	if head, err := api.Mine(nil); err != nil || head != 4 {
+		t.Fatalf("mined head mismatch: have %d, want 4 (err %v)", head, err)
+	}
+	if have := chain.CurrentBlock().Time().Uint64(); have < future || have > future+2 {
+		t.Errorf("block time mismatch: have %d, want %d", have, future)
+	}
+	// Revert to the genesis and ensure the snapshot is consumed
+	if ok, err := api.Revert(snap); !ok || err != nil {
+		t.Fatalf("failed to revert: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 0 {
+		t.Errorf("reverted head mismatch: have %d, want 0", head)
+	}
+	if ok, _ := api.Revert(snap); ok {
+		t.Errorf("reverted to consumed snapshot")
+	}
+	if offset := api.IncreaseTime(0); offset != 0 {
+		t.Errorf("clock adjustment not reverted: have %d", offset)
+	}
+}
+
+// Tests that concurrently setting the block clock to the same time doesn't
+// accumulate the adjustments.
+func TestSetTimestampConcurrent(t *testing.T) {
+	api := &API{dev: New(&params.DevConfig{})}
+	future := uint64(time.Now().Add(24 * time.Hour).Unix())
+
+	var pend sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+			for j := 0; j < 100; j++ {
+				api.SetTimestamp(future)
+			}
+		}()
+	}
+	pend.Wait()
+
+	if have := api.dev.now(); have < future || have > future+1 {
+		t.Errorf("block clock mismatch: have %d, want %d", have, future)
+	}
+}
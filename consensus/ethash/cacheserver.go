@@ -0,0 +1,212 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+const (
+	// cacheDialTimeout is the maximum time allowed to connect to a cache server.
+	cacheDialTimeout = 3 * time.Second
+
+	// cacheRequestTimeout is the maximum time allowed for a cache server to
+	// answer a request, including generating the requested cache.
+	cacheRequestTimeout = 2 * time.Minute
+)
+
+var (
+	// errNoCacheDir is returned if a cache server is created without a disk
+	// location to share its caches through.
+	errNoCacheDir = errors.New("cache server needs a cache directory")
+
+	// errCacheNotShared is returned by a cache server if it failed to store the
+	// requested cache on disk, so it can't be memory mapped by its clients.
+	errCacheNotShared = errors.New("cache not stored on disk")
+
+	// errCacheSizeMismatch is returned if a shared cache doesn't have the size
+	// the client expects, e.g. when a test mode client talks to a full server.
+	errCacheSizeMismatch = errors.New("shared cache size mismatch")
+)
+
+// CacheServer generates ethash verification caches on behalf of the processes
+// running on the same host. Caches are stored in a directory all clients can
+// read, and clients memory map them, so the operating system keeps a single
+// copy in memory regardless of the number of processes verifying blocks.
+type CacheServer struct {
+	ethash   *ecash       // Engine generating and tracking the shared caches
+	server   *rpc.Server  // RPC server answering the cache requests
+	listener net.Listener // Unix socket the cache requests arrive on
+	lock     sync.Mutex
+}
+
+// NewCacheServer creates a cache server storing its caches in the configured
+// cache directory. The dataset and remote cache settings are ignored.
+func NewCacheServer(config Config) (*CacheServer, error) {
+	if config.CacheDir == "" {
+		return nil, errNoCacheDir
+	}
+	// Generating the future cache drops the old ones from disk, keep all the in
+	// memory caches and the future one around for the clients to map
+	if config.CachesInMem <= 0 {
+		config.CachesInMem = 1
+	}
+	if config.CachesOnDisk <= config.CachesInMem {
+		config.CachesOnDisk = config.CachesInMem + 1
+	}
+	config.CacheServer = ""
+
+	server := rpc.NewServer()
+	cs := &CacheServer{ethash: New(config), server: server}
+	if err := server.RegisterName("ethash", &CacheServerAPI{cs.ethash}); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Start begins accepting cache requests on the given unix socket (or named pipe
+// on Windows).
+func (cs *CacheServer) Start(endpoint string) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if cs.listener != nil {
+		return fmt.Errorf("cache server already listening on %s", cs.listener.Addr())
+	}
+	listener, err := rpc.CreateIPCListener(endpoint)
+	if err != nil {
+		return err
+	}
+	cs.listener = listener
+	go cs.server.ServeListener(listener)
+
+	log.Info("Ethash cache server started", "endpoint", endpoint, "dir", cs.ethash.config.CacheDir)
+	return nil
+}
+
+// Stop closes the listening socket and terminates all client connections. The
+// caches already mapped by the clients stay valid.
+func (cs *CacheServer) Stop() {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	if cs.listener != nil {
+		cs.listener.Close()
+		cs.listener = nil
+	}
+	cs.server.Stop()
+	log.Info("Ethash cache server stopped")
+}
+
+// CacheServerAPI is the RPC API of a cache server, exposed under the ethash
+// namespace.
+type CacheServerAPI struct {
+	ethash *ecash
+}
+
+// Cache generates the verification cache of the given epoch if needed (also
+// preparing the next one) and returns the path of the file it is stored in.
+func (s *CacheServerAPI) Cache(epoch uint64) (string, error) {
+	cache := s.ethash.cache(epoch * epochLength)
+	defer runtime.KeepAlive(cache)
+
+	if cache.dump == nil {
+		return "", errCacheNotShared
+	}
+	// The file may have been dropped by a newer cache, even if still mapped
+	if _, err := os.Stat(cache.dump.Name()); err != nil {
+		return "", errCacheNotShared
+	}
+	return cache.dump.Name(), nil
+}
+
+// cacheClient requests verification caches from a local cache server.
+type cacheClient struct {
+	endpoint string      // Unix socket (or named pipe) of the cache server
+	client   *rpc.Client // Connection to the cache server, nil if not yet dialed
+	lock     sync.Mutex
+}
+
+// newCacheClient creates a client for the cache server at the given endpoint.
+// The server doesn't need to be running yet, it is dialed on first use.
+func newCacheClient(endpoint string) *cacheClient {
+	return &cacheClient{endpoint: endpoint}
+}
+
+// path retrieves the location of the shared cache of the given epoch.
+func (c *cacheClient) path(epoch uint64) (string, error) {
+	c.lock.Lock()
+	if c.client == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cacheDialTimeout)
+		client, err := rpc.DialIPC(ctx, c.endpoint)
+		cancel()
+		if err != nil {
+			c.lock.Unlock()
+			return "", err
+		}
+		c.client = client
+	}
+	client := c.client
+	c.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheRequestTimeout)
+	defer cancel()
+
+	var path string
+	if err := client.CallContext(ctx, &path, "ethash_cache", epoch); err != nil {
+		// The server may have been restarted or hung, redial on the next request
+		c.lock.Lock()
+		if c.client == client {
+			c.client = nil
+		}
+		c.lock.Unlock()
+		client.Close()
+
+		return "", err
+	}
+	return path, nil
+}
+
+// load memory maps the verification cache shared by a cache server.
+func (c *cache) load(remote *cacheClient, size uint64) error {
+	path, err := remote.path(c.epoch)
+	if err != nil {
+		return err
+	}
+	dump, mem, buffer, err := memoryMap(path)
+	if err != nil {
+		return err
+	}
+	if uint64(len(buffer)) != size/4 {
+		mem.Unmap()
+		dump.Close()
+		return errCacheSizeMismatch
+	}
+	c.dump, c.mmap, c.cache = dump, mem, buffer
+	runtime.SetFinalizer(c, (*cache).finalizer)
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that verification caches are memory mapped from a cache server, and are
+// generated locally if the server is unreachable.
+func TestCacheServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-cacheserver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, err := NewCacheServer(Config{CacheDir: filepath.Join(dir, "caches"), CachesInMem: 1, PowMode: ModeTest})
+	if err != nil {
+		t.Fatalf("failed to create cache server: %v", err)
+	}
+	endpoint := filepath.Join(dir, "ethash.ipc")
+	if err := server.Start(endpoint); err != nil {
+		t.Fatalf("failed to start cache server: %v", err)
+	}
+	defer server.Stop()
+
+	// Generate a reference cache locally
+	want := make([]uint32, 1024/4)
+	generateCache(want, 0, seedHash(1))
+
+	// Retrieve the same cache through the server and through an unreachable one
+	for _, endpoint := range []string{endpoint, filepath.Join(dir, "missing.ipc")} {
+		ethash := New(Config{CachesInMem: 1, PowMode: ModeTest, CacheServer: endpoint})
+
+		cache := ethash.cache(0)
+		if !reflect.DeepEqual(cache.cache, want) {
+			t.Errorf("%s: cache content mismatch", endpoint)
+		}
+		if shared := cache.dump != nil; shared != (endpoint == server.listener.Addr().String()) {
+			t.Errorf("%s: cache sharing mismatch: have %v", endpoint, shared)
+		}
+	}
+}
+
+// Tests that the connection to a cache server is dropped after a failed request
+// and redialed on the next one, surviving a restart of the server.
+func TestCacheClientRedial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ethash-cacheserver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, err := NewCacheServer(Config{CacheDir: filepath.Join(dir, "caches"), CachesInMem: 1, PowMode: ModeTest})
+	if err != nil {
+		t.Fatalf("failed to create cache server: %v", err)
+	}
+	endpoint := filepath.Join(dir, "ethash.ipc")
+	if err := server.Start(endpoint); err != nil {
+		t.Fatalf("failed to start cache server: %v", err)
+	}
+	client := newCacheClient(endpoint)
+	if _, err := client.path(0); err != nil {
+		t.Fatalf("failed to retrieve cache path: %v", err)
+	}
+	// Take the server down, the failed request must drop the connection
+	server.Stop()
+	if _, err := client.path(0); err == nil {
+		t.Fatalf("cache path retrieved from stopped server")
+	}
+	if client.client != nil {
+		t.Fatalf("connection kept after failed request")
+	}
+	// Bring a new server up and ensure the client reconnects
+	if server, err = NewCacheServer(Config{CacheDir: filepath.Join(dir, "caches"), CachesInMem: 1, PowMode: ModeTest}); err != nil {
+		t.Fatalf("failed to recreate cache server: %v", err)
+	}
+	if err := server.Start(endpoint); err != nil {
+		t.Fatalf("failed to restart cache server: %v", err)
+	}
+	defer server.Stop()
+
+	if _, err := client.path(0); err != nil {
+		t.Fatalf("failed to retrieve cache path after restart: %v", err)
+	}
+}
@@ -500,6 +500,27 @@ func (ethash *ecash) VerifySeal(chain consensus.ChainReader, header *types.Heade
 	return nil
 }
 
+// Hashimoto computes the mix digest and the PoW value of the given header hash
+// and nonce at the specified block number, using the verification cache. Fake
+// PoW modes return an all zero digest and PoW value, meeting any target.
+func (ethash *ecash) Hashimoto(number uint64, hash []byte, nonce uint64) ([]byte, []byte) {
+	if ethash.config.PowMode == ModeFake || ethash.config.PowMode == ModeFullFake {
+		return make([]byte, common.HashLength), make([]byte, common.HashLength)
+	}
+	if ethash.shared != nil {
+		return ethash.shared.Hashimoto(number, hash, nonce)
+	}
+	cache := ethash.cache(number)
+	size := datasetSize(number)
+	if ethash.config.PowMode == ModeTest {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache.cache, hash, nonce)
+	runtime.KeepAlive(cache)
+
+	return digest, result
+}
+
 // Prepare implements consensus.Engine, initializing the difficulty field of a
 // header to conform to the ethash protocol. The changes are done inline.
 func (ethash *ecash) Prepare(chain consensus.ChainReader, header *types.Header) error {
@@ -47,7 +47,7 @@ var (
 	maxUint256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 	// sharedecash is a full instance that can be shared between multiple users.
-	sharedecash = New(Config{"", 3, 0, "", 1, 0, ModeNormal})
+	sharedecash = New(Config{"", 3, 0, "", 1, 0, ModeNormal, ""})
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
@@ -211,14 +211,25 @@ func newCache(epoch uint64) interface{} {
 	return &cache{epoch: epoch}
 }
 
-// generate ensures that the cache content is generated before use.
-func (c *cache) generate(dir string, limit int, test bool) {
+// generate ensures that the cache content is generated before use. If a cache
+// server is given, the cache is memory mapped from the server's copy instead,
+// falling back to local generation if that fails.
+func (c *cache) generate(dir string, limit int, test bool, remote *cacheClient) {
 	c.once.Do(func() {
 		size := cacheSize(c.epoch*epochLength + 1)
 		seed := seedHash(c.epoch*epochLength + 1)
 		if test {
 			size = 1024
 		}
+		// If a cache server is available, reuse its copy
+		if remote != nil {
+			err := c.load(remote, size)
+			if err == nil {
+				log.Debug("Mapped shared ethash cache", "epoch", c.epoch)
+				return
+			}
+			log.Warn("Failed to retrieve shared ethash cache, generating locally", "epoch", c.epoch, "err", err)
+		}
 		// If we don't store anything on disk, generate and return.
 		if dir == "" {
 			c.cache = make([]uint32, size/4)
@@ -358,7 +369,7 @@ func (d *dataset) finalizer() {
 // MakeCache generates a new ethash cache and optionally stores it to disk.
 func MakeCache(block uint64, dir string) {
 	c := cache{epoch: block / epochLength}
-	c.generate(dir, math.MaxInt32, false)
+	c.generate(dir, math.MaxInt32, false, nil)
 }
 
 // MakeDataset generates a new ethash dataset and optionally stores it to disk.
@@ -387,6 +398,7 @@ type Config struct {
 	DatasetsInMem  int
 	DatasetsOnDisk int
 	PowMode        Mode
+	CacheServer    string // Endpoint of a shared cache server to map the verification caches from
 }
 
 // ecash is a consensus engine based on proot-of-work implementing the ethash
@@ -394,8 +406,9 @@ type Config struct {
 type ecash struct {
 	config Config
 
-	caches   *lru // In memory caches to avoid regenerating too often
-	datasets *lru // In memory datasets to avoid regenerating too often
+	caches   *lru         // In memory caches to avoid regenerating too often
+	datasets *lru         // In memory datasets to avoid regenerating too often
+	remote   *cacheClient // Shared cache server to retrieve the caches from (nil = local only)
 
 	// Mining related fields
 	rand     *rand.Rand    // Properly seeded random source for nonces
@@ -423,13 +436,18 @@ func New(config Config) *ecash {
 	if config.DatasetDir != "" && config.DatasetsOnDisk > 0 {
 		log.Info("Disk storage enabled for ethash DAGs", "dir", config.DatasetDir, "count", config.DatasetsOnDisk)
 	}
-	return &ecash{
+	ethash := &ecash{
 		config:   config,
 		caches:   newlru("cache", config.CachesInMem, newCache),
 		datasets: newlru("dataset", config.DatasetsInMem, newDataset),
 		update:   make(chan struct{}),
 		hashrate: metrics.NewMeter(),
 	}
+	if config.CacheServer != "" {
+		log.Info("Shared ethash caches enabled", "server", config.CacheServer)
+		ethash.remote = newCacheClient(config.CacheServer)
+	}
+	return ethash
 }
 
 // NewTester creates a small sized ethash PoW scheme useful only for testing
@@ -498,12 +516,13 @@ func (ethash *ecash) cache(block uint64) *cache {
 	current := currentI.(*cache)
 
 	// Wait for generation finish.
-	current.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest)
+	current.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest, ethash.remote)
 
-	// If we need a new future cache, now's a good time to regenerate it.
-	if futureI != nil {
+	// If we need a new future cache, now's a good time to regenerate it. A cache
+	// server prepares the future caches on its own, so leave them be until needed.
+	if futureI != nil && ethash.remote == nil {
 		future := futureI.(*cache)
-		go future.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest)
+		go future.generate(ethash.config.CacheDir, ethash.config.CachesOnDisk, ethash.config.PowMode == ModeTest, nil)
 	}
 	return current
 }
@@ -96,7 +96,7 @@ func newTester(t *testing.T, confOverride func(*ec.Config)) *tester {
 		t.Fatalf("failed to create node: %v", err)
 	}
 	ecConf := &ec.Config{
-		Genesis:   core.DeveloperGenesisBlock(15, common.Address{}),
+		Genesis:   core.DeveloperGenesisBlock(15, false, common.Address{}),
 		ecerbase: common.HexToAddress(testAddress),
 		ecash: ethash.Config{
 			PowMode: ethash.ModeTest,
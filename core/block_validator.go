@@ -101,9 +101,11 @@ func (v *BlockValidator) ValidateState(block, parent *types.Block, statedb *stat
 	return nil
 }
 
-// CalcGasLimit computes the gas limit of the next block after parent.
-// This is miner strategy, not consensus protocol.
-func CalcGasLimit(parent *types.Block) uint64 {
+// CalcGasLimit computes the gas limit of the next block after parent. The
+// limit follows the parent's gas usage, but is pulled up towards gasTarget and
+// down towards gasCeil as fast as the protocol allows. A zero ceiling leaves the
+// limit uncapped. This is miner strategy, not consensus protocol.
+func CalcGasLimit(parent *types.Block, gasTarget, gasCeil uint64) uint64 {
 	// contrib = (parentGasUsed * 3 / 2) / 1024
 	contrib := (parent.GasUsed() + parent.GasUsed()/2) / params.GasLimitBoundDivisor
 
@@ -121,12 +123,25 @@ func CalcGasLimit(parent *types.Block) uint64 {
 	if limit < params.MinGasLimit {
 		limit = params.MinGasLimit
 	}
-	// however, if we're now below the target (TargetGasLimit) we increase the
-	// limit as much as we can (parentGasLimit / 1024 -1)
-	if limit < params.TargetGasLimit {
+	// If we're now below the target, we increase the limit as much as we can
+	// (parentGasLimit / 1024 -1), unless that overshoots the ceiling
+	if gasCeil != 0 && gasTarget > gasCeil {
+		gasTarget = gasCeil
+	}
+	if limit < gasTarget {
 		limit = parent.GasLimit() + decay
-		if limit > params.TargetGasLimit {
-			limit = params.TargetGasLimit
+		if limit > gasTarget {
+			limit = gasTarget
+		}
+	}
+	// If we're above the ceiling, we decrease the limit as much as we can
+	if gasCeil != 0 && limit > gasCeil {
+		limit = parent.GasLimit() - decay
+		if limit < gasCeil {
+			limit = gasCeil
+		}
+		if limit < params.MinGasLimit {
+			limit = params.MinGasLimit
 		}
 	}
 	return limit
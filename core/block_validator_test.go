@@ -197,3 +197,31 @@ func testHeaderConcurrentAbortion(t *testing.T, threads int) {
 		t.Errorf("verification count too large: have %d, want below %d", verified, 2*threads)
 	}
 }
+
+// Tests that the gas limit is steered towards the miner's target and ceiling
+// without ever moving further than the protocol allows.
+func TestCalcGasLimit(t *testing.T) {
+	tests := []struct {
+		parent, used, target, ceil uint64
+		want                       uint64
+	}{
+		// Below the target, raise as fast as possible
+		{4000000, 0, 5000000, 0, 4000000 + 4000000/1024 - 1},
+		{4999000, 0, 5000000, 0, 5000000},
+		// Above the ceiling, lower as fast as possible, even if blocks are full
+		{9000000, 9000000, 5000000, 8000000, 9000000 - 9000000/1024 + 1},
+		{8001000, 8001000, 5000000, 8000000, 8000000},
+		// The ceiling takes precedence over the target
+		{7000000, 0, 9000000, 8000000, 7000000 + 7000000/1024 - 1},
+		{7999000, 0, 9000000, 8000000, 8000000},
+		// Between target and ceiling, follow the usage
+		{6000000, 0, 5000000, 8000000, 6000000 - 6000000/1024 + 1},
+		{6000000, 6000000, 5000000, 8000000, 6000000 - 6000000/1024 + 1 + 6000000*3/2/1024},
+	}
+	for i, tt := range tests {
+		parent := types.NewBlockWithHeader(&types.Header{GasLimit: tt.parent, GasUsed: tt.used})
+		if have := CalcGasLimit(parent, tt.target, tt.ceil); have != tt.want {
+			t.Errorf("test %d: gas limit mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+}
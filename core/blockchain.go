@@ -18,6 +18,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/ecchain/go-ecchain/metrics"
 	"github.com/ecchain/go-ecchain/params"
 	"github.com/ecchain/go-ecchain/rlp"
+	"github.com/ecchain/go-ecchain/tracing"
 	"github.com/ecchain/go-ecchain/trie"
 	"github.com/hashicorp/golang-lru"
 	"gopkg.in/karalabe/cookiejar.v2/collections/prque"
@@ -93,14 +95,15 @@ type BlockChain struct {
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
-	hc            *HeaderChain
-	rmLogsFeed    event.Feed
-	chainFeed     event.Feed
-	chainSideFeed event.Feed
-	chainHeadFeed event.Feed
-	logsFeed      event.Feed
-	scope         event.SubscriptionScope
-	genesisBlock  *types.Block
+	hc                 *HeaderChain
+	rmLogsFeed         event.Feed
+	chainFeed          event.Feed
+	chainSideFeed      event.Feed
+	chainHeadFeed      event.Feed
+	chainFinalizedFeed event.Feed
+	logsFeed           event.Feed
+	scope              event.SubscriptionScope
+	genesisBlock       *types.Block
 
 	mu      sync.RWMutex // global mutex for locking chain operations
 	chainmu sync.RWMutex // blockchain insertion lock
@@ -109,6 +112,10 @@ type BlockChain struct {
 	checkpoint       int          // checkpoint counts towards the new checkpoint
 	currentBlock     atomic.Value // Current head of the block chain
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentFinalized atomic.Value // Highest block that can no longer be reorged (nil if no finality)
+
+	finalizedLock sync.Mutex    // Lock protecting the last announced finalized block
+	finalizedSent *types.Header // Finalized block last announced to the subscribers
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
@@ -225,6 +232,7 @@ func (bc *BlockChain) loadLasecate() error {
 	}
 	// Everything seems to be fine, set as the head block
 	bc.currentBlock.Store(currentBlock)
+	bc.updateFinalized(currentBlock.Header())
 
 	// Restore the last known head header
 	currentHeader := currentBlock.Header()
@@ -260,8 +268,18 @@ func (bc *BlockChain) loadLasecate() error {
 // SetHead rewinds the local chain to a new head. In the case of headers, everything
 // above the new head will be deleted and the new one set. In the case of blocks
 // though, the head may be further rewound if block bodies are missing (non-archive
-// nodes after a fast sync).
+// nodes after a fast sync). The new head is announced to the chain head event
+// subscribers, so they can drop any state built on top of the removed blocks.
 func (bc *BlockChain) SetHead(head uint64) error {
+	if err := bc.setHead(head); err != nil {
+		return err
+	}
+	bc.PostChainEvents([]interface{}{ChainHeadEvent{Block: bc.CurrentBlock()}}, nil)
+	return nil
+}
+
+// setHead rewinds the local chain to a new head without notifying anyone.
+func (bc *BlockChain) setHead(head uint64) error {
 	log.Warn("Rewinding blockchain", "target", head)
 
 	bc.mu.Lock()
@@ -309,7 +327,12 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	if err := WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash()); err != nil {
 		log.Crit("Failed to reset head fast block", "err", err)
 	}
-	return bc.loadLasecate()
+	if err := bc.loadLasecate(); err != nil {
+		return err
+	}
+	// The rewind may have dropped the finalized checkpoint, move it below the new head
+	bc.updateFinalized(bc.CurrentBlock().Header())
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -349,6 +372,76 @@ func (bc *BlockChain) CurrentFastBlock() *types.Block {
 	return bc.currentFastBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedHeader retrieves the finalized checkpoint of the canonical
+// chain, the highest block that can no longer be reorged. Nil is returned if
+// the chain has no finality.
+func (bc *BlockChain) CurrentFinalizedHeader() *types.Header {
+	if header, ok := bc.currentFinalized.Load().(*types.Header); ok {
+		return header
+	}
+	return nil
+}
+
+// CurrentSafeHeader retrieves the block halfway between the finalized checkpoint
+// and the head of the canonical chain, which is unlikely, but not guaranteed to
+// stay canonical. Nil is returned if the chain has no finality.
+func (bc *BlockChain) CurrentSafeHeader() *types.Header {
+	finalized := bc.CurrentFinalizedHeader()
+	if finalized == nil {
+		return nil
+	}
+	head, number := bc.CurrentBlock().NumberU64(), finalized.Number.Uint64()
+	if head <= number {
+		return finalized
+	}
+	if header := bc.GetHeaderByNumber(number + (head-number)/2); header != nil {
+		return header
+	}
+	return finalized
+}
+
+// updateFinalized moves the finalized checkpoint to the block the finality
+// depth below the given head. The checkpoint never moves backwards, unless it
+// was removed from the canonical chain by an explicit rewind.
+func (bc *BlockChain) updateFinalized(head *types.Header) {
+	depth, ok := bc.chainConfig.Finality()
+	if !ok {
+		return
+	}
+	number := uint64(0)
+	if head.Number.Uint64() > depth {
+		number = head.Number.Uint64() - depth
+	}
+	if current := bc.CurrentFinalizedHeader(); current != nil {
+		if current.Number.Uint64() >= number && current.Number.Cmp(head.Number) <= 0 && GetCanonicalHash(bc.db, current.Number.Uint64()) == current.Hash() {
+			return
+		}
+	}
+	if header := bc.GetHeaderByNumber(number); header != nil {
+		bc.currentFinalized.Store(header)
+	}
+}
+
+// reorgsFinalized reports whecer making the given block the new head would
+// remove the finalized checkpoint from the canonical chain.
+func (bc *BlockChain) reorgsFinalized(block *types.Block) bool {
+	finalized := bc.CurrentFinalizedHeader()
+	if finalized == nil {
+		return false
+	}
+	// Walk back until the block's chain joins the canonical one
+	for header := block.Header(); header != nil; header = bc.GetHeader(header.ParentHash, header.Number.Uint64()-1) {
+		number := header.Number.Uint64()
+		if GetCanonicalHash(bc.db, number) == header.Hash() {
+			return false
+		}
+		if number <= finalized.Number.Uint64() {
+			return true
+		}
+	}
+	return false
+}
+
 // SetProcessor sets the processor required for making state modifications.
 func (bc *BlockChain) SetProcessor(processor Processor) {
 	bc.procmu.Lock()
@@ -484,6 +577,7 @@ func (bc *BlockChain) insert(block *types.Block) {
 		log.Crit("Failed to insert head block hash", "err", err)
 	}
 	bc.currentBlock.Store(block)
+	bc.updateFinalized(block.Header())
 
 	// If the block is better than our head or is on a different chain, force update heads
 	if updateHeads {
@@ -959,6 +1053,11 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 		// Split same-difficulty blocks by number, then at random
 		reorg = block.NumberU64() < currentBlock.NumberU64() || (block.NumberU64() == currentBlock.NumberU64() && mrand.Float64() < 0.5)
 	}
+	if reorg && block.ParentHash() != currentBlock.Hash() && bc.reorgsFinalized(block) {
+		// Never reorg finalized blocks, regardless of the competing chain's difficulty
+		log.Warn("Rejected reorg beyond finalized block", "number", block.Number(), "hash", block.Hash(), "finalized", bc.CurrentFinalizedHeader().Number)
+		reorg = false
+	}
 	if reorg {
 		// Reorganise the chain if the parent is not the head block
 		if block.ParentHash() != currentBlock.Hash() {
@@ -1021,6 +1120,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	bc.wg.Add(1)
 	defer bc.wg.Done()
 
+	ctx, span := tracing.StartSpan(context.Background(), "core.BlockChain.insertChain")
+	if span != nil {
+		span.SetAttribute("blocks", len(chain))
+		if len(chain) > 0 {
+			span.SetAttribute("first", chain[0].NumberU64())
+		}
+		defer span.End()
+	}
+
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
 
@@ -1138,13 +1246,27 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 			return i, events, coalescedLogs, err
 		}
 		// Process block using the parent state as reference point.
+		pctx, pspan := tracing.StartSpan(ctx, "core.StateProcessor.Process")
+		if pspan != nil {
+			pspan.SetAttribute("number", block.NumberU64())
+			pspan.SetAttribute("txs", len(block.Transactions()))
+			state.SetTraceContext(pctx)
+		}
 		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
+		pspan.SetError(err)
+		pspan.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
 		}
 		// Validate the state using the default validator
+		vctx, vspan := tracing.StartSpan(ctx, "core.BlockValidator.ValidateState")
+		if vspan != nil {
+			state.SetTraceContext(vctx)
+		}
 		err = bc.Validator().ValidateState(block, parent, state, receipts, usedGas)
+		vspan.SetError(err)
+		vspan.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -1152,7 +1274,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		proctime := time.Since(bstart)
 
 		// Write the block to the chain and get the status.
+		_, wspan := tracing.StartSpan(ctx, "core.BlockChain.WriteBlockWithState")
 		status, err := bc.WriteBlockWithState(block, receipts, state)
+		wspan.SetError(err)
+		wspan.End()
+		state.SetTraceContext(nil)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
@@ -1362,6 +1488,7 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainHeadEvent:
 			bc.chainHeadFeed.Send(ev)
+			bc.postFinalizedEvent()
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
@@ -1369,6 +1496,20 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 	}
 }
 
+// postFinalizedEvent announces the finalized checkpoint to the subscribers if it
+// changed since the last announcement.
+func (bc *BlockChain) postFinalizedEvent() {
+	bc.finalizedLock.Lock()
+	defer bc.finalizedLock.Unlock()
+
+	finalized := bc.CurrentFinalizedHeader()
+	if finalized == nil || (bc.finalizedSent != nil && bc.finalizedSent.Hash() == finalized.Hash()) {
+		return
+	}
+	bc.finalizedSent = finalized
+	bc.chainFinalizedFeed.Send(ChainFinalizedEvent{Header: finalized})
+}
+
 func (bc *BlockChain) update() {
 	futureTimer := time.NewTicker(5 * time.Second)
 	defer futureTimer.Stop()
@@ -1547,6 +1688,11 @@ func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Su
 	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
 }
 
+// SubscribeChainFinalizedEvent registers a subscription of ChainFinalizedEvent.
+func (bc *BlockChain) SubscribeChainFinalizedEvent(ch chan<- ChainFinalizedEvent) event.Subscription {
+	return bc.scope.Track(bc.chainFinalizedFeed.Subscribe(ch))
+}
+
 // SubscribeChainSideEvent registers a subscription of ChainSideEvent.
 func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Subscription {
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
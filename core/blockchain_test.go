@@ -32,6 +32,7 @@ import (
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/ecdb"
 	"github.com/ecchain/go-ecchain/params"
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 // Test fork of length N starting from block i
@@ -1338,3 +1339,120 @@ func TestLargeReorgTrieGC(t *testing.T) {
 		}
 	}
 }
+
+// Tests that blocks below the finalized checkpoint are never reorged, even by a
+// heavier chain, but blocks above it still are.
+func TestFinalizedReorg(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Finality: 3}
+
+	engine := ethash.NewFaker()
+
+	db, _ := ecdb.NewMemDatabase()
+	gspec := &Genesis{Config: &config}
+	genesis := gspec.MustCommit(db)
+
+	original, _ := GenerateChain(&config, genesis, engine, db, 10, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+	deep, _ := GenerateChain(&config, original[4], engine, db, 20, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{2}) })
+	shallow, _ := GenerateChain(&config, original[7], engine, db, 5, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{3}) })
+
+	diskdb, _ := ecdb.NewMemDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, &config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	finalized := make(chan ChainFinalizedEvent, 16)
+	sub := chain.SubscribeChainFinalizedEvent(finalized)
+	defer sub.Unsubscribe()
+
+	if _, err := chain.InsertChain(original); err != nil {
+		t.Fatalf("failed to insert original chain: %v", err)
+	}
+	if number := chain.CurrentFinalizedHeader().Number.Uint64(); number != 7 {
+		t.Fatalf("finalized number mismatch: have %d, want 7", number)
+	}
+	if number := chain.CurrentSafeHeader().Number.Uint64(); number != 8 {
+		t.Errorf("safe number mismatch: have %d, want 8", number)
+	}
+	select {
+	case ev := <-finalized:
+		if ev.Header.Hash() != original[6].Hash() {
+			t.Errorf("finalized event mismatch: have #%d, want #7", ev.Header.Number)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("finalized event not posted")
+	}
+	// Import a heavier chain forking below the checkpoint and ensure it's rejected
+	if _, err := chain.InsertChain(deep); err != nil {
+		t.Fatalf("failed to insert deep fork: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != original[9].Hash() {
+		t.Errorf("finalized block reorged: head #%d", chain.CurrentBlock().Number())
+	}
+	// Import a heavier chain forking above the checkpoint and ensure it's accepted
+	if _, err := chain.InsertChain(shallow); err != nil {
+		t.Fatalf("failed to insert shallow fork: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != shallow[4].Hash() {
+		t.Errorf("non-finalized block not reorged: head #%d", chain.CurrentBlock().Number())
+	}
+	if number := chain.CurrentFinalizedHeader().Number.Uint64(); number != 10 {
+		t.Errorf("finalized number mismatch: have %d, want 10", number)
+	}
+	// Rewind below the checkpoint and ensure it follows the new head
+	if err := chain.SetHead(5); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if header := chain.CurrentFinalizedHeader(); header.Hash() != original[1].Hash() {
+		t.Fatalf("rewound finalized mismatch: have #%d, want #2", header.Number)
+	}
+	// The fork previously below the checkpoint is now reorgable
+	if _, err := chain.InsertChain(deep); err != nil {
+		t.Fatalf("failed to insert deep fork: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != deep[19].Hash() {
+		t.Errorf("rewound chain not reorged: head #%d", chain.CurrentBlock().Number())
+	}
+}
+
+// Tests that chains without finality don't report checkpoints.
+func TestNoFinality(t *testing.T) {
+	_, blockchain, err := newCanonical(ethash.NewFaker(), 5, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	if header := blockchain.CurrentFinalizedHeader(); header != nil {
+		t.Errorf("finalized header reported: #%d", header.Number)
+	}
+	if header := blockchain.CurrentSafeHeader(); header != nil {
+		t.Errorf("safe header reported: #%d", header.Number)
+	}
+}
+
+// discardExporter drops all exported spans.
+type discardExporter struct{}
+
+func (discardExporter) Export(spans []*tracing.Span) error { return nil }
+func (discardExporter) Close() error                       { return nil }
+
+// Tests that inserting an empty chain is a no-op even with tracing enabled.
+func TestInsertEmptyChainTraced(t *testing.T) {
+	_, blockchain, err := newCanonical(ethash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	tracing.Start(discardExporter{})
+	defer tracing.Stop()
+
+	if n, err := blockchain.InsertChain(nil); n != 0 || err != nil {
+		t.Fatalf("empty chain insertion failed: have (%d, %v), want (0, nil)", n, err)
+	}
+}
@@ -32,4 +32,8 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrNoFinality is returned when requesting the finalized or safe block of a
+	// chain whose consensus engine never finalizes blocks.
+	ErrNoFinality = errors.New("chain has no finality")
 )
@@ -52,3 +52,7 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainFinalizedEvent is posted when the finalized checkpoint of the canonical
+// chain changes.
+type ChainFinalizedEvent struct{ Header *types.Header }
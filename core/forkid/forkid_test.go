@@ -0,0 +1,104 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package forkid
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/params"
+)
+
+// The fork schedule and genesis of the original ethereum mainnet, for which the
+// EIP-2124 reference identifiers are known.
+var (
+	testGenesis = common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
+	testConfig  = &params.ChainConfig{
+		ChainId:             big.NewInt(1),
+		HomesteadBlock:      big.NewInt(1150000),
+		DAOForkBlock:        big.NewInt(1920000),
+		DAOForkSupport:      true,
+		EIP150Block:         big.NewInt(2463000),
+		EIP155Block:         big.NewInt(2675000),
+		EIP158Block:         big.NewInt(2675000),
+		ByzantiumBlock:      big.NewInt(4370000),
+		ConstantinopleBlock: big.NewInt(7280000),
+	}
+)
+
+// Tests that fork identifiers are calculated correctly at every fork transition.
+func TestCreation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+		{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},       // Unsynced
+		{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}}, // Last Frontier block
+		{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // First Homestead block
+		{1919999, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, // Last Homestead block
+		{1920000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, // First DAO block
+		{2463000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, // First Tangerine block
+		{2675000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, // First Spurious block
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}}, // First Byzantium block
+		{7280000, ID{Hash: checksumToBytes(0x668db0af), Next: 0}},       // First Constantinople block
+		{8000000, ID{Hash: checksumToBytes(0x668db0af), Next: 0}},       // Future block
+	}
+	for i, tt := range tests {
+		if have := NewID(testConfig, testGenesis, tt.head); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+// Tests that remote fork identifiers are validated according to the EIP-2124
+// rules.
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+		// Local is at the same fork as the remote, with the same next fork
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: 0}, nil},
+
+		// Local is at the same fork, remote announces a future fork not passed yet
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: math.MaxUint64}, nil},
+
+		// Local is at the same fork, remote announces a fork already passed locally
+		{7987396, ID{Hash: checksumToBytes(0x668db0af), Next: 7280000}, ErrLocalIncompatibleOrStale},
+
+		// Local is mid Byzantium, remote is still at Spurious announcing Byzantium
+		{7279999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+		// Local is at Constantinople, remote is at Spurious without knowing Byzantium
+		{7987396, ID{Hash: checksumToBytes(0x3edd5b10), Next: 0}, ErrRemoteStale},
+
+		// Local is at Byzantium, remote already passed Constantinople (local out of sync)
+		{7279999, ID{Hash: checksumToBytes(0x668db0af), Next: 0}, nil},
+
+		// Remote is on an unknown chain
+		{7987396, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := NewFilter(testConfig, testGenesis, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
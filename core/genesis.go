@@ -344,17 +344,17 @@ func DefaultRinkebyGenesisBlock() *Genesis {
 	}
 }
 
-// DeveloperGenesisBlock returns the 'gec --dev' genesis block. Note, this must
-// be seeded with the
-func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
-	// Override the default period to the user requested one
-	config := *params.AllCliqueProtocolChanges
-	config.Clique.Period = period
+// DeveloperGenesisBlock returns the 'gec --dev' genesis block, sealed by the
+// instant developer engine with the given empty block period and sealing mode,
+// and with the faucet account pre-funded.
+func DeveloperGenesisBlock(period uint64, manual bool, faucet common.Address) *Genesis {
+	// Override the default sealing rules to the user requested ones
+	config := *params.AllDevProtocolChanges
+	config.Dev = &params.DevConfig{Period: period, Manual: manual}
 
 	// Assemble and return the genesis with the precompiles and faucet pre-funded
 	return &Genesis{
 		Config:     &config,
-		ExtraData:  append(append(make([]byte, 32), faucet[:]...), make([]byte, 65)...),
 		GasLimit:   6283185,
 		Difficulty: big.NewInt(1),
 		Alloc: map[common.Address]GenesisAccount{
@@ -25,6 +25,7 @@ import (
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/rlp"
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 var emptyCodeHash = crypto.Keccak256(nil)
@@ -175,7 +176,11 @@ func (self *stateObject) Geecate(db Database, key common.Hash) common.Hash {
 		return value
 	}
 	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
+	tr := self.getTrie(db)
+	if tracing.Enabled() {
+		defer self.db.traceLoad("state.stateObject.loadStorage", tr, self.address)()
+	}
+	enc, err := tr.TryGet(key[:])
 	if err != nil {
 		self.setError(err)
 		return common.Hash{}
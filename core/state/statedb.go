@@ -18,6 +18,7 @@
 package state
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"sort"
@@ -28,6 +29,7 @@ import (
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/rlp"
+	"github.com/ecchain/go-ecchain/tracing"
 	"github.com/ecchain/go-ecchain/trie"
 )
 
@@ -80,6 +82,9 @@ type StateDB struct {
 	validRevisions []revision
 	nextRevisionId int
 
+	// Trace context the trie loads are attributed to, if any
+	traceCtx context.Context
+
 	lock sync.Mutex
 }
 
@@ -368,6 +373,9 @@ func (self *StateDB) geecateObject(addr common.Address) (stateObject *stateObjec
 	}
 
 	// Load the object from the database.
+	if tracing.Enabled() {
+		defer self.traceLoad("state.StateDB.loadAccount", self.trie, addr)()
+	}
 	enc, err := self.trie.TryGet(addr[:])
 	if len(enc) == 0 {
 		self.setError(err)
@@ -384,6 +392,38 @@ func (self *StateDB) geecateObject(addr common.Address) (stateObject *stateObjec
 	return obj
 }
 
+// SetTraceContext sets the context whose span the state's trie loads are traced
+// as children of. Without a span in the context, trie loads are not traced.
+func (self *StateDB) SetTraceContext(ctx context.Context) {
+	self.traceCtx = ctx
+}
+
+// traceableTrie is implemented by tries able to trace their database reads.
+type traceableTrie interface {
+	SetTraceContext(ctx context.Context)
+}
+
+// traceLoad starts a span tracing a trie load if the state has a trace context,
+// returning the function to end it. The persistent database reads made by the
+// trie in between are traced as children of the span.
+func (self *StateDB) traceLoad(name string, tr Trie, addr common.Address) func() {
+	if tracing.FromContext(self.traceCtx) == nil {
+		return func() {}
+	}
+	ctx, span := tracing.StartSpan(self.traceCtx, name)
+	span.SetAttribute("address", addr.Hex())
+
+	traced, ok := tr.(traceableTrie)
+	if !ok {
+		return span.End
+	}
+	traced.SetTraceContext(ctx)
+	return func() {
+		traced.SetTraceContext(nil)
+		span.End()
+	}
+}
+
 func (self *StateDB) seecateObject(object *stateObject) {
 	self.stateObjects[object.Address()] = object
 }
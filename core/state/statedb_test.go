@@ -18,6 +18,7 @@ package state
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -33,6 +34,7 @@ import (
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/core/types"
 	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -421,3 +423,87 @@ func (s *StateSuite) TestTouchDelete(c *check.C) {
 		c.Fatal("expected no dirty state object")
 	}
 }
+
+// spanCollector gathers all exported tracing spans in memory.
+type spanCollector struct {
+	spans []*tracing.Span
+}
+
+func (c *spanCollector) Export(spans []*tracing.Span) error {
+	c.spans = append(c.spans, spans...)
+	return nil
+}
+
+func (c *spanCollector) Close() error { return nil }
+
+// Tests that trie loads are traced as children of the state's trace context,
+// with the database reads made traced under them, and are not traced without
+// one.
+func TestTracedStateLoads(t *testing.T) {
+	// Create a state with accounts and storage persisted to disk
+	db, _ := ecdb.NewMemDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb)
+
+	// Create enough accounts and slots for the trie roots to not be leaves
+	for i := byte(0); i < 64; i++ {
+		state.SetBalance(common.BytesToAddress([]byte{i}), big.NewInt(1))
+	}
+	addr := common.BytesToAddress([]byte{0x01})
+	for i := byte(1); i < 64; i++ {
+		state.Seecate(addr, common.Hash{i}, common.Hash{i})
+	}
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+
+	collector := new(spanCollector)
+	tracing.Start(collector)
+
+	// Load the account both without and with a trace context
+	untraced, _ := New(root, NewDatabase(db))
+	untraced.GetBalance(addr)
+
+	ctx, parent := tracing.StartSpan(context.Background(), "test")
+	traced, _ := New(root, NewDatabase(db))
+	traced.SetTraceContext(ctx)
+	traced.GetBalance(addr)
+	traced.Geecate(addr, common.Hash{0x02})
+	parent.End()
+
+	if err := tracing.Stop(); err != nil {
+		t.Fatalf("failed to stop tracer: %v", err)
+	}
+	loads := make(map[string]*tracing.Span)
+	for _, span := range collector.spans {
+		if span.ParentID == parent.SpanID {
+			loads[span.Name] = span
+		}
+	}
+	if len(loads) != 2 {
+		t.Fatalf("load span count mismatch: have %d, want 2", len(loads))
+	}
+	for _, name := range []string{"state.StateDB.loadAccount", "state.stateObject.loadStorage"} {
+		load := loads[name]
+		if load == nil {
+			t.Errorf("span %s missing", name)
+			continue
+		}
+		reads := 0
+		for _, span := range collector.spans {
+			if span.ParentID == load.SpanID {
+				if span.Name != "ecdb.Get" {
+					t.Errorf("span %s: unexpected child %s", name, span.Name)
+				}
+				reads++
+			}
+		}
+		if reads == 0 {
+			t.Errorf("span %s: no database reads traced", name)
+		}
+	}
+	for _, span := range collector.spans {
+		if span.TraceID != parent.TraceID {
+			t.Errorf("span %s not part of the trace context", span.Name)
+		}
+	}
+}
@@ -1,20 +1,20 @@
 // Copyright 2017 The go-ethereum Authors
-// This file is part of go-ecereum.
+// This file is part of the go-ecereum library.
 //
-// go-ecereum is free software: you can redistribute it and/or modify
-// it under the terms of the GNU General Public License as published by
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
 // the Free Software Foundation, either version 3 of the License, or
 // (at your option) any later version.
 //
-// go-ecereum is distributed in the hope that it will be useful,
+// The go-ecereum library is distributed in the hope that it will be useful,
 // but WITHOUT ANY WARRANTY; without even the implied warranty of
 // MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
-// GNU General Public License for more details.
+// GNU Lesser General Public License for more details.
 //
-// You should have received a copy of the GNU General Public License
-// along with go-ecereum. If not, see <http://www.gnu.org/licenses/>.
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
 
-package main
+package vm
 
 import (
 	"encoding/json"
@@ -24,25 +24,33 @@ import (
 
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/math"
-	"github.com/ecchain/go-ecchain/core/vm"
 )
 
+// JSONLogger is an EVM state logger that streams each execution step as a
+// single line of JSON to the given writer, producing EIP-3155 style traces
+// without retaining them in memory.
 type JSONLogger struct {
 	encoder *json.Encoder
-	cfg     *vm.LogConfig
+	cfg     *LogConfig
 }
 
-func NewJSONLogger(cfg *vm.LogConfig, writer io.Writer) *JSONLogger {
+// NewJSONLogger creates a new EVM tracer that prints execution steps as JSON
+// objects into the provided stream.
+func NewJSONLogger(cfg *LogConfig, writer io.Writer) *JSONLogger {
+	if cfg == nil {
+		cfg = new(LogConfig)
+	}
 	return &JSONLogger{json.NewEncoder(writer), cfg}
 }
 
+// CaptureStart is triggered at the beginning of execution.
 func (l *JSONLogger) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
 	return nil
 }
 
 // CaptureState outputs state information on the logger.
-func (l *JSONLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
-	log := vm.StructLog{
+func (l *JSONLogger) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	log := StructLog{
 		Pc:         pc,
 		Op:         op,
 		Gas:        gas,
@@ -62,7 +70,7 @@ func (l *JSONLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cos
 }
 
 // CaptureFault outputs state information on the logger.
-func (l *JSONLogger) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+func (l *JSONLogger) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
 	return nil
 }
 
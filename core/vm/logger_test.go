@@ -17,7 +17,10 @@
 package vm
 
 import (
+	"bytes"
+	"encoding/json"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/ecchain/go-ecchain/common"
@@ -68,3 +71,34 @@ func TesecoreCapture(t *testing.T) {
 		t.Errorf("expected %x, got %x", exp, logger.changedValues[contract.Address()][index])
 	}
 }
+
+func TestJSONLoggerStreaming(t *testing.T) {
+	var (
+		out      = new(bytes.Buffer)
+		env      = NewEVM(Context{}, nil, params.TestChainConfig, Config{})
+		logger   = NewJSONLogger(&LogConfig{DisableMemory: true}, out)
+		mem      = NewMemory()
+		stack    = newstack()
+		contract = NewContract(&dummyContractRef{}, &dummyContractRef{}, new(big.Int), 0)
+	)
+	stack.push(big.NewInt(1))
+
+	logger.CaptureState(env, 0, PUSH1, 100, 3, mem, stack, contract, 1, nil)
+	logger.CaptureState(env, 2, STOP, 97, 0, mem, stack, contract, 1, nil)
+	logger.CaptureEnd(nil, 3, 0, nil)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 trace lines, got %d: %q", len(lines), out.String())
+	}
+	var step map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &step); err != nil {
+		t.Fatalf("failed to decode first step: %v", err)
+	}
+	if step["opName"] != "PUSH1" {
+		t.Errorf("opcode mismatch: have %v, want PUSH1", step["opName"])
+	}
+	if step["memory"] != "0x" {
+		t.Errorf("memory captured despite being disabled: %v", step["memory"])
+	}
+}
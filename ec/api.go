@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/hexutil"
@@ -73,10 +74,7 @@ type PublicMinerAPI struct {
 
 // NewPublicMinerAPI create a new PublicMinerAPI instance.
 func NewPublicMinerAPI(e *ecchain) *PublicMinerAPI {
-	agent := miner.NewRemoteAgent(e.BlockChain(), e.Engine())
-	e.Miner().Register(agent)
-
-	return &PublicMinerAPI{e, agent}
+	return &PublicMinerAPI{e, e.agent}
 }
 
 // Mining returns an indication if this node is currently mining.
@@ -187,6 +185,22 @@ func (api *PrivateMinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	return true
 }
 
+// SetRecommitInterval updates the interval (in milliseconds) at which the
+// pending block is re-filled with new transactions while mining.
+func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
+	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
+}
+
+// SetGasLimit updates the gas limit the mined blocks are raised towards and the
+// one they are lowered towards (zero leaves them uncapped).
+func (api *PrivateMinerAPI) SetGasLimit(target hexutil.Uint64, limit hexutil.Uint64) bool {
+	if limit != 0 && target > limit {
+		return false
+	}
+	api.e.Miner().SetGasLimit(uint64(target), uint64(limit))
+	return true
+}
+
 // Setecerbase sets the ecerbase of the miner
 func (api *PrivateMinerAPI) Setecerbase(ecerbase common.Address) bool {
 	api.e.Setecerbase(ecerbase)
@@ -312,11 +326,10 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 		_, stateDb := api.ec.miner.Pending()
 		return stateDb.RawDump(), nil
 	}
-	var block *types.Block
-	if blockNr == rpc.LatestBlockNumber {
-		block = api.ec.blockchain.CurrentBlock()
-	} else {
-		block = api.ec.blockchain.GetBlockByNumber(uint64(blockNr))
+	// Otherwise resolve the block number, including the finalized and safe tags
+	block, err := api.ec.ApiBackend.BlockByNumber(context.Background(), blockNr)
+	if err != nil {
+		return state.Dump{}, err
 	}
 	if block == nil {
 		return state.Dump{}, fmt.Errorf("block #%d not found", blockNr)
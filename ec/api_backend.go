@@ -65,9 +65,24 @@ func (b *ecApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumb
 	if blockNr == rpc.LatestBlockNumber {
 		return b.ec.blockchain.CurrentBlock().Header(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		return b.checkpoint(blockNr)
+	}
 	return b.ec.blockchain.GetHeaderByNumber(uint64(blockNr)), nil
 }
 
+// checkpoint resolves the finalized and safe block tags to their headers.
+func (b *ecApiBackend) checkpoint(blockNr rpc.BlockNumber) (*types.Header, error) {
+	header := b.ec.blockchain.CurrentFinalizedHeader()
+	if blockNr == rpc.SafeBlockNumber {
+		header = b.ec.blockchain.CurrentSafeHeader()
+	}
+	if header == nil {
+		return nil, core.ErrNoFinality
+	}
+	return header, nil
+}
+
 func (b *ecApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
 	// Pending block is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
@@ -78,6 +93,13 @@ func (b *ecApiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 	if blockNr == rpc.LatestBlockNumber {
 		return b.ec.blockchain.CurrentBlock(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		header, err := b.checkpoint(blockNr)
+		if err != nil {
+			return nil, err
+		}
+		return b.ec.blockchain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+	}
 	return b.ec.blockchain.GetBlockByNumber(uint64(blockNr)), nil
 }
 
@@ -85,6 +107,7 @@ func (b *ecApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.B
 	// Pending state is only known by the miner
 	if blockNr == rpc.PendingBlockNumber {
 		block, state := b.ec.miner.Pending()
+		state.SetTraceContext(ctx)
 		return state, block.Header(), nil
 	}
 	// Otherwise resolve the block number and return its state
@@ -93,7 +116,12 @@ func (b *ecApiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.B
 		return nil, nil, err
 	}
 	stateDb, err := b.ec.BlockChain().StateAt(header.Root)
-	return stateDb, header, err
+	if err != nil {
+		return nil, nil, err
+	}
+	// Attribute the state loads of the request to its trace, if any
+	stateDb.SetTraceContext(ctx)
+	return stateDb, header, nil
 }
 
 func (b *ecApiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
@@ -140,6 +168,10 @@ func (b *ecApiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) ev
 	return b.ec.BlockChain().SubscribeChainHeadEvent(ch)
 }
 
+func (b *ecApiBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return b.ec.BlockChain().SubscribeChainFinalizedEvent(ch)
+}
+
 func (b *ecApiBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
 	return b.ec.BlockChain().SubscribeChainSideEvent(ch)
 }
@@ -24,12 +24,16 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	ecereum "github.com/ecchain/go-ecchain"
 	"github.com/ecchain/go-ecchain/accounts"
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/hexutil"
 	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/consensus/bft"
 	"github.com/ecchain/go-ecchain/consensus/clique"
+	"github.com/ecchain/go-ecchain/consensus/dev"
 	"github.com/ecchain/go-ecchain/consensus/ethash"
 	"github.com/ecchain/go-ecchain/core"
 	"github.com/ecchain/go-ecchain/core/bloombits"
@@ -85,12 +89,16 @@ type ecchain struct {
 	ApiBackend *ecApiBackend
 
 	miner     *miner.Miner
+	agent     *miner.RemoteAgent   // Remote agent serving external miners
+	stratum   *miner.StratumServer // Stratum server pushing work to external miners
 	gasPrice  *big.Int
 	ecerbase common.Address
 
 	networkId     uint64
 	netRPCService *ethapi.PublicNetAPI
 
+	traceDir string // Directory to dump standard JSON transaction traces into
+
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and ecerbase)
 }
 
@@ -133,6 +141,7 @@ func New(ctx *node.ServiceContext, config *Config) (*ecchain, error) {
 		ecerbase:      config.ecerbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks),
+		traceDir:       ctx.ResolvePath("traces"),
 	}
 
 	log.Info("Initialising ecchain protocol", "versions", ProtocolVersions, "network", config.NetworkId)
@@ -160,6 +169,14 @@ func New(ctx *node.ServiceContext, config *Config) (*ecchain, error) {
 	}
 	ec.bloomIndexer.Start(ec.blockchain)
 
+	switch engine := ec.engine.(type) {
+	case *clique.Clique:
+		engine.SetStateReader(ec.blockchain.StateAt)
+	case *bft.BFT:
+		engine.SetChain(ec.blockchain)
+		engine.SetInserter(ec.blockchain.InsertChain)
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
@@ -171,6 +188,21 @@ func New(ctx *node.ServiceContext, config *Config) (*ecchain, error) {
 	ec.miner = miner.New(ec, ec.chainConfig, ec.EventMux(), ec.engine)
 	ec.miner.SetExtra(makeExtraData(config.ExtraData))
 
+	builder, err := miner.NewBlockBuilder(config.MinerBuilder)
+	if err != nil {
+		return nil, err
+	}
+	ec.miner.SetBuilder(builder)
+	ec.miner.SetRecommitInterval(config.MinerRecommit)
+	ec.miner.SetGasLimit(config.MinerGasTarget, config.MinerGasLimit)
+	if engine, ok := ec.engine.(*dev.Dev); ok {
+		engine.SetRefresh(ec.miner.Refresh)
+	}
+	ec.miner.SetGasReservation(config.MinerReservedGas, config.MinerReservedSenders)
+
+	ec.agent = miner.NewRemoteAgent(ec.BlockChain(), ec.Engine())
+	ec.miner.Register(ec.agent)
+
 	ec.ApiBackend = &ecApiBackend{ec, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
@@ -216,6 +248,14 @@ func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chai
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
 	}
+	// If byzantine fault tolerance is requested, validate with the node key
+	if chainConfig.BFT != nil {
+		return bft.New(chainConfig.BFT, ctx.NodeKey(), db)
+	}
+	// If a developer chain is requested, seal blocks instantly
+	if chainConfig.Dev != nil {
+		return dev.New(chainConfig.Dev)
+	}
 	// Otherwise assume proof-of-work
 	switch {
 	case config.PowMode == ethash.ModeFake:
@@ -235,6 +275,7 @@ func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chai
 			DatasetDir:     config.DatasetDir,
 			DatasetsInMem:  config.DatasetsInMem,
 			DatasetsOnDisk: config.DatasetsOnDisk,
+			CacheServer:    config.CacheServer,
 		})
 		engine.SetThreads(-1) // Disable CPU mining
 		return engine
@@ -310,6 +351,10 @@ func (s *ecchain) ecerbase() (eb common.Address, err error) {
 	if ecerbase != (common.Address{}) {
 		return ecerbase, nil
 	}
+	// BFT validators are identified by their node key, no account needed
+	if bft, ok := s.engine.(*bft.BFT); ok {
+		return bft.Address(), nil
+	}
 	if wallets := s.AccountManager().Wallets(); len(wallets) > 0 {
 		if accounts := wallets[0].Accounts(); len(accounts) > 0 {
 			ecerbase := accounts[0].Address
@@ -341,12 +386,12 @@ func (s *ecchain) StartMining(local bool) error {
 		return fmt.Errorf("ecerbase missing: %v", err)
 	}
 	if clique, ok := s.engine.(*clique.Clique); ok {
-		wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
-		if wallet == nil || err != nil {
-			log.Error("ecerbase account unavailable locally", "err", err)
-			return fmt.Errorf("signer missing: %v", err)
+		// Hardware and remote wallets may come and go, so don't insist on the
+		// signer being present now, but look it up anew on every signature
+		if _, err := s.accountManager.Find(accounts.Account{Address: eb}); err != nil {
+			log.Warn("ecerbase account currently unavailable, sealing will retry", "err", err)
 		}
-		clique.Authorize(eb, wallet.SignHash)
+		clique.Authorize(eb, s.signHash)
 	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
@@ -359,6 +404,15 @@ func (s *ecchain) StartMining(local bool) error {
 	return nil
 }
 
+// signHash signs the hash with whichever wallet currently holds the account.
+func (s *ecchain) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	wallet, err := s.accountManager.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.SignHash(account, hash)
+}
+
 func (s *ecchain) StopMining()         { s.miner.Stop() }
 func (s *ecchain) IsMining() bool      { return s.miner.Mining() }
 func (s *ecchain) Miner() *miner.Miner { return s.miner }
@@ -374,13 +428,29 @@ func (s *ecchain) ecVersion() int                    { return int(s.protocolMana
 func (s *ecchain) NetVersion() uint64                 { return s.networkId }
 func (s *ecchain) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 
+// SyncProgress implements node.ChainHealthReporter, returning the current
+// synchronisation status of the downloader.
+func (s *ecchain) SyncProgress() ecereum.SyncProgress {
+	return s.protocolManager.downloader.Progress()
+}
+
+// HeadTime implements node.ChainHealthReporter, returning the timestamp of the
+// current head block.
+func (s *ecchain) HeadTime() time.Time {
+	return time.Unix(s.blockchain.CurrentBlock().Time().Int64(), 0)
+}
+
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *ecchain) Protocols() []p2p.Protocol {
+	protos := s.protocolManager.SubProtocols
+	if bft, ok := s.engine.(*bft.BFT); ok {
+		protos = append(protos, bft.Protocols()...)
+	}
 	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+		return protos
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	return append(protos, s.lesServer.Protocols()...)
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -402,9 +472,21 @@ func (s *ecchain) Start(srvr *p2p.Server) error {
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	s.protocolManager.startENRUpdater(srvr)
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Start the stratum server for external miners if requested
+	if s.config.StratumAddr != "" {
+		stratum, err := miner.NewStratumServer(s.agent, s.engine, new(big.Int).SetUint64(s.config.StratumDifficulty))
+		if err != nil {
+			return err
+		}
+		if err := stratum.Start(s.config.StratumAddr); err != nil {
+			return err
+		}
+		s.stratum = stratum
+	}
 	return nil
 }
 
@@ -421,7 +503,13 @@ func (s *ecchain) Stop() error {
 		s.lesServer.Stop()
 	}
 	s.txPool.Stop()
+	if s.stratum != nil {
+		s.stratum.Stop()
+	}
 	s.miner.Stop()
+	if bft, ok := s.engine.(*bft.BFT); ok {
+		bft.Stop()
+	}
 	s.eventMux.Stop()
 
 	s.chainDb.Close()
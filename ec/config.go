@@ -43,12 +43,14 @@ var DefaultConfig = Config{
 		DatasetsInMem:  1,
 		DatasetsOnDisk: 2,
 	},
-	NetworkId:     1,
-	LightPeers:    100,
-	DatabaseCache: 768,
-	TrieCache:     256,
-	TrieTimeout:   5 * time.Minute,
-	GasPrice:      big.NewInt(18 * params.Shannon),
+	NetworkId:      1,
+	LightPeers:     100,
+	DatabaseCache:  768,
+	TrieCache:      256,
+	TrieTimeout:    5 * time.Minute,
+	GasPrice:       big.NewInt(18 * params.Shannon),
+	MinerRecommit:  3 * time.Second,
+	MinerGasTarget: params.GenesisGasLimit,
 
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
@@ -100,6 +102,18 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Block building options
+	MinerRecommit        time.Duration    // Interval of re-filling the pending block while mining (0 = disabled)
+	MinerBuilder         string           `toml:",omitempty"` // Transaction ordering strategy (price, fifo or profit)
+	MinerReservedGas     uint64           `toml:",omitempty"` // Block gas reserved for the local senders
+	MinerReservedSenders []common.Address `toml:",omitempty"` // Local senders the reserved gas is available to
+	MinerGasTarget       uint64           // Gas limit the mined blocks are raised towards
+	MinerGasLimit        uint64           `toml:",omitempty"` // Gas limit the mined blocks are lowered towards (0 = uncapped)
+
+	// Stratum server options
+	StratumAddr       string `toml:",omitempty"` // Listening address of the stratum server (empty = disabled)
+	StratumDifficulty uint64 `toml:",omitempty"` // Share difficulty of stratum miners (0 = block difficulty)
+
 	// ecash options
 	ecash ethash.Config
 
@@ -0,0 +1,98 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ec
+
+import (
+	"fmt"
+
+	"github.com/ecchain/go-ecchain/core"
+	"github.com/ecchain/go-ecchain/core/forkid"
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p"
+	"github.com/ecchain/go-ecchain/p2p/enr"
+	"github.com/ecchain/go-ecchain/rlp"
+)
+
+// ecEntry is the "ec" node record entry, which advertises the fork ID of the
+// chain the node is on.
+type ecEntry struct {
+	ForkID forkid.ID // Fork identifier per EIP-2124
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ecEntry) ENRKey() string {
+	return "ec"
+}
+
+// currentENREntry constructs the node record entry for the current chain head.
+func currentENREntry(chain *core.BlockChain) *ecEntry {
+	return &ecEntry{
+		ForkID: forkid.NewID(chain.Config(), chain.Genesis().Hash(), chain.CurrentHeader().Number.Uint64()),
+	}
+}
+
+// newDialFilter creates a dial filter rejecting nodes whose record advertises a
+// fork ID incompatible with the local chain. Nodes not advertising a fork ID
+// at all are dialed, as they may simply predate node records.
+func newDialFilter(chain *core.BlockChain) func(*enr.Record) error {
+	filter := forkid.NewFilter(chain.Config(), chain.Genesis().Hash(), func() uint64 {
+		return chain.CurrentHeader().Number.Uint64()
+	})
+	return func(record *enr.Record) error {
+		var entry ecEntry
+		if err := record.Load(&entry); err != nil {
+			if enr.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		return filter(entry.ForkID)
+	}
+}
+
+// startENRUpdater keeps the fork ID in the local node record up to date as the
+// chain progresses through the forks. It terminates when the chain is stopped.
+func (pm *ProtocolManager) startENRUpdater(srvr *p2p.Server) {
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := pm.blockchain.SubscribeChainHeadEvent(heads)
+
+	go func() {
+		defer sub.Unsubscribe()
+
+		current := currentENREntry(pm.blockchain)
+		for {
+			select {
+			case <-heads:
+				next := currentENREntry(pm.blockchain)
+				if next.ForkID == current.ForkID {
+					continue
+				}
+				if err := srvr.SetNodeRecordEntry(next); err != nil {
+					log.Debug("Failed to update node record", "err", err)
+					continue
+				}
+				log.Info("Updated fork ID in node record", "hash", fmt.Sprintf("%x", next.ForkID.Hash), "next", next.ForkID.Next)
+				current = next
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+}
@@ -33,9 +33,17 @@ var (
 	propBroadcastDropMeter = metrics.NewRegisteredMeter("ec/fetcher/prop/broadcasts/drop", nil)
 	propBroadcastDOSMeter  = metrics.NewRegisteredMeter("ec/fetcher/prop/broadcasts/dos", nil)
 
+	txAnnounceInMeter   = metrics.NewRegisteredMeter("ec/fetcher/prop/txannounces/in", nil)
+	txAnnounceDropMeter = metrics.NewRegisteredMeter("ec/fetcher/prop/txannounces/drop", nil)
+	txAnnounceDOSMeter  = metrics.NewRegisteredMeter("ec/fetcher/prop/txannounces/dos", nil)
+	txBroadcastInMeter  = metrics.NewRegisteredMeter("ec/fetcher/prop/txbroadcasts/in", nil)
+
 	headerFetchMeter = metrics.NewRegisteredMeter("ec/fetcher/fetch/headers", nil)
 	bodyFetchMeter   = metrics.NewRegisteredMeter("ec/fetcher/fetch/bodies", nil)
 
+	txFetchMeter        = metrics.NewRegisteredMeter("ec/fetcher/fetch/txs", nil)
+	txFetchTimeoutMeter = metrics.NewRegisteredMeter("ec/fetcher/fetch/txs/timeout", nil)
+
 	headerFilterInMeter  = metrics.NewRegisteredMeter("ec/fetcher/filter/headers/in", nil)
 	headerFilterOutMeter = metrics.NewRegisteredMeter("ec/fetcher/filter/headers/out", nil)
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("ec/fetcher/filter/bodies/in", nil)
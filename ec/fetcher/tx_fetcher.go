@@ -0,0 +1,349 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txHashLimit     = 4096                   // Maximum number of unique transactions a peer may have announced
+
+	// MaxTxFetch is the maximum number of transactions requested from or served
+	// to a peer in a single message.
+	MaxTxFetch = 256
+)
+
+// txPoolHasFn is a callback type for checking whecer a transaction is already
+// in the local pool.
+type txPoolHasFn func(common.Hash) bool
+
+// txPoolAddFn is a callback type for adding a batch of transactions to the
+// local pool.
+type txPoolAddFn func([]*types.Transaction) []error
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func([]common.Hash) error
+
+// txAnnounce is the hash notification of the availability of a batch of
+// transactions at a peer.
+type txAnnounce struct {
+	origin   string        // Identifier of the peer originating the notification
+	hashes   []common.Hash // Hashes of the transactions being announced
+	time     time.Time     // Timestamp of the announcement
+	fetchTxs txRequesterFn // Fetcher function to retrieve the announced transactions
+}
+
+// txAnnounced is the retrieval state of a single announced transaction.
+type txAnnounced struct {
+	time      time.Time // Timestamp of the first announcement
+	origins   []string  // Peers announcing the transaction, not yet requested from
+	fetching  string    // Peer the transaction is currently requested from, if any
+	requested time.Time // Timestamp of the current request
+}
+
+// TxFetcher is responsible for accumulating transaction announcements from
+// various peers and retrieving the transactions not yet known locally, each
+// from a single announcing peer at a time.
+type TxFetcher struct {
+	notify chan *txAnnounce
+	done   chan []common.Hash
+	drop   chan string
+	quit   chan struct{}
+
+	// Announce states
+	announces  map[string]int               // Per peer announce counts to prevent memory exhaustion
+	announced  map[common.Hash]*txAnnounced // Announced transactions, scheduled for or being fetched
+	requesters map[string]txRequesterFn     // Retrieval functions of the announcing peers
+
+	// Callbacks
+	hasTx  txPoolHasFn // Checks whecer a transaction is already in the local pool
+	addTxs txPoolAddFn // Adds a batch of transactions to the local pool
+
+	// Testing hooks
+	fetchingHook func(string, []common.Hash) // Method to call upon starting a transaction fetch
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve transactions based on
+// hash announcements.
+func NewTxFetcher(hasTx txPoolHasFn, addTxs txPoolAddFn) *TxFetcher {
+	return &TxFetcher{
+		notify:     make(chan *txAnnounce),
+		done:       make(chan []common.Hash),
+		drop:       make(chan string),
+		quit:       make(chan struct{}),
+		announces:  make(map[string]int),
+		announced:  make(map[common.Hash]*txAnnounced),
+		requesters: make(map[string]txRequesterFn),
+		hasTx:      hasTx,
+		addTxs:     addTxs,
+	}
+}
+
+// Start boots up the announcement based transaction retrieval, accepting and
+// processing hash notifications and deliveries until termination requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retrieval, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the availability of a batch of transactions
+// at a peer.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash, time time.Time, fetchTxs txRequesterFn) error {
+	announce := &txAnnounce{
+		origin:   peer,
+		hashes:   hashes,
+		time:     time,
+		fetchTxs: fetchTxs,
+	}
+	select {
+	case f.notify <- announce:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue adds a batch of transactions, requested or broadcast, to the local
+// pool and stops retrieving them from any other announcing peer.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction) error {
+	txBroadcastInMeter.Mark(int64(len(txs)))
+	f.addTxs(txs)
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.done <- hashes:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop removes all announcements of a disconnected peer, retrieving the
+// transactions requested from it from other announcing peers.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main transaction fetcher loop, checking and processing various
+// notification events.
+func (f *TxFetcher) loop() {
+	fetchTimer := time.NewTimer(0)
+
+	for {
+		select {
+		case <-f.quit:
+			// Fetcher terminating, abort all operations
+			return
+
+		case notification := <-f.notify:
+			// A batch of transactions was announced, make sure the peer isn't DOSing us
+			txAnnounceInMeter.Mark(int64(len(notification.hashes)))
+
+			f.requesters[notification.origin] = notification.fetchTxs
+			for _, hash := range notification.hashes {
+				if f.announces[notification.origin] >= txHashLimit {
+					log.Debug("Peer exceeded ouecanding transaction announces", "peer", notification.origin, "limit", txHashLimit)
+					txAnnounceDOSMeter.Mark(1)
+					break
+				}
+				// Skip transactions already known, or already announced by the peer
+				if f.hasTx(hash) {
+					txAnnounceDropMeter.Mark(1)
+					continue
+				}
+				entry := f.announced[hash]
+				if entry == nil {
+					entry = &txAnnounced{time: notification.time}
+					f.announced[hash] = entry
+				} else if entry.has(notification.origin) {
+					continue
+				}
+				entry.origins = append(entry.origins, notification.origin)
+				f.announces[notification.origin]++
+			}
+			if len(f.announced) > 0 {
+				f.rescheduleFetch(fetchTimer)
+			}
+
+		case hashes := <-f.done:
+			// A batch of transactions arrived, stop retrieving them
+			for _, hash := range hashes {
+				f.forgetHash(hash)
+			}
+
+		case peer := <-f.drop:
+			// A peer disconnected, reschedule its pending retrievals
+			for hash, entry := range f.announced {
+				entry.origins = removeOrigin(entry.origins, peer)
+				if entry.fetching == peer {
+					entry.fetching = ""
+				}
+				if entry.fetching == "" && len(entry.origins) == 0 {
+					delete(f.announced, hash)
+				}
+			}
+			delete(f.announces, peer)
+			delete(f.requesters, peer)
+			f.rescheduleFetch(fetchTimer)
+
+		case <-fetchTimer.C:
+			// At least one transaction's timer ran out, check for needing retrieval
+			request := make(map[string][]common.Hash)
+
+			for hash, entry := range f.announced {
+				// Give up on requests not answered in time, retrying from others
+				if entry.fetching != "" {
+					if time.Since(entry.requested) < txFetchTimeout {
+						continue
+					}
+					log.Trace("Transaction retrieval timed out", "peer", entry.fetching, "hash", hash)
+					txFetchTimeoutMeter.Mark(1)
+					f.release(entry.fetching)
+					entry.fetching = ""
+				}
+				if time.Since(entry.time) < txArriveTimeout-gatherSlack {
+					continue
+				}
+				// If the transaction still didn't arrive, pick a random announcer
+				// to retrieve it from, keeping the others as fallbacks
+				if f.hasTx(hash) || len(entry.origins) == 0 {
+					f.forgetHash(hash)
+					continue
+				}
+				idx := rand.Intn(len(entry.origins))
+				peer := entry.origins[idx]
+				entry.origins = append(entry.origins[:idx], entry.origins[idx+1:]...)
+				entry.fetching, entry.requested = peer, time.Now()
+
+				request[peer] = append(request[peer], hash)
+			}
+			// Send out all transaction requests
+			for peer, hashes := range request {
+				log.Trace("Fetching scheduled transactions", "peer", peer, "count", len(hashes))
+
+				// Create a closure of the fetch and schedule it on a new thread
+				peer, fetchTxs, hashes := peer, f.requesters[peer], hashes
+				go func() {
+					if f.fetchingHook != nil {
+						f.fetchingHook(peer, hashes)
+					}
+					for len(hashes) > 0 {
+						batch := hashes
+						if len(batch) > MaxTxFetch {
+							batch = batch[:MaxTxFetch]
+						}
+						hashes = hashes[len(batch):]
+
+						txFetchMeter.Mark(int64(len(batch)))
+						fetchTxs(batch)
+					}
+				}()
+			}
+			// Schedule the next fetch if transactions are still pending
+			f.rescheduleFetch(fetchTimer)
+		}
+	}
+}
+
+// rescheduleFetch resets the specified fetch timer to the next announce or
+// request timeout.
+func (f *TxFetcher) rescheduleFetch(fetch *time.Timer) {
+	// Short circuit if no transactions are announced
+	if len(f.announced) == 0 {
+		return
+	}
+	// Otherwise find the earliest expiring announcement or request
+	earliest := time.Now().Add(txFetchTimeout)
+	for _, entry := range f.announced {
+		deadline := entry.time.Add(txArriveTimeout)
+		if entry.fetching != "" {
+			deadline = entry.requested.Add(txFetchTimeout)
+		}
+		if deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	fetch.Reset(time.Until(earliest))
+}
+
+// forgetHash removes all traces of a transaction announcement from the fetcher's
+// internal state.
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	entry := f.announced[hash]
+	if entry == nil {
+		return
+	}
+	for _, peer := range entry.origins {
+		f.release(peer)
+	}
+	if entry.fetching != "" {
+		f.release(entry.fetching)
+	}
+	delete(f.announced, hash)
+}
+
+// release decrements the announce count of a peer.
+func (f *TxFetcher) release(peer string) {
+	f.announces[peer]--
+	if f.announces[peer] <= 0 {
+		delete(f.announces, peer)
+	}
+}
+
+// has reports whecer the peer announced the transaction.
+func (entry *txAnnounced) has(peer string) bool {
+	if entry.fetching == peer {
+		return true
+	}
+	for _, origin := range entry.origins {
+		if origin == peer {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOrigin removes a peer from a list of announcers.
+func removeOrigin(origins []string, peer string) []string {
+	for i, origin := range origins {
+		if origin == peer {
+			return append(origins[:i], origins[i+1:]...)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/types"
+)
+
+// txRequest is a transaction retrieval request made by the fetcher.
+type txRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// txFetcherTester is a test simulator for mocking out the local transaction pool.
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	requests chan *txRequest
+
+	lock sync.RWMutex
+	pool map[common.Hash]*types.Transaction
+}
+
+// newTxTester creates a new transaction fetcher test mocker.
+func newTxTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		requests: make(chan *txRequest, 16),
+		pool:     make(map[common.Hash]*types.Transaction),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.addTxs)
+	tester.fetcher.Start()
+	return tester
+}
+
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.pool[hash] != nil
+}
+
+func (f *txFetcherTester) addTxs(txs []*types.Transaction) []error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, tx := range txs {
+		f.pool[tx.Hash()] = tx
+	}
+	return make([]error, len(txs))
+}
+
+// makeTxRequester creates a function recording the retrieval requests made to
+// the given peer.
+func (f *txFetcherTester) makeTxRequester(peer string) txRequesterFn {
+	return func(hashes []common.Hash) error {
+		f.requests <- &txRequest{peer: peer, hashes: hashes}
+		return nil
+	}
+}
+
+// expectRequest waits for a retrieval request, checking its peer.
+func (f *txFetcherTester) expectRequest(t *testing.T, timeout time.Duration, peers ...string) *txRequest {
+	select {
+	case req := <-f.requests:
+		for _, peer := range peers {
+			if req.peer == peer {
+				return req
+			}
+		}
+		t.Fatalf("request sent to %s, want one of %v", req.peer, peers)
+	case <-time.After(timeout):
+		t.Fatalf("no request sent within %v", timeout)
+	}
+	return nil
+}
+
+// expectNoRequest checks that no retrieval request is made for a while.
+func (f *txFetcherTester) expectNoRequest(t *testing.T, wait time.Duration) {
+	select {
+	case req := <-f.requests:
+		t.Fatalf("unexpected request to %s: %x", req.peer, req.hashes)
+	case <-time.After(wait):
+	}
+}
+
+func makeTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	}
+	return txs
+}
+
+// Tests that transactions announced by multiple peers are requested once, from
+// one of the announcers.
+func TestTxFetcherDeduplication(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(3)
+	hashes := []common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}
+
+	tester.fetcher.Notify("A", hashes, time.Now(), tester.makeTxRequester("A"))
+	tester.fetcher.Notify("B", hashes[1:], time.Now(), tester.makeTxRequester("B"))
+	tester.fetcher.Notify("A", hashes, time.Now(), tester.makeTxRequester("A"))
+
+	requested := make(map[common.Hash]int)
+	for len(requested) < len(hashes) {
+		req := tester.expectRequest(t, time.Second, "A", "B")
+		for _, hash := range req.hashes {
+			requested[hash]++
+		}
+	}
+	for hash, count := range requested {
+		if count != 1 {
+			t.Errorf("transaction %x requested %d times", hash, count)
+		}
+	}
+	tester.expectNoRequest(t, txArriveTimeout)
+}
+
+// Tests that transactions arriving before the announce timeout are not requested.
+func TestTxFetcherArrivedTransactions(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(2)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash(), txs[1].Hash()}, time.Now(), tester.makeTxRequester("A"))
+	tester.fetcher.Enqueue("B", txs[:1])
+
+	if req := tester.expectRequest(t, time.Second, "A"); len(req.hashes) != 1 || req.hashes[0] != txs[1].Hash() {
+		t.Fatalf("requested hashes mismatch: have %x, want %x", req.hashes, txs[1].Hash())
+	}
+	// Transactions already in the pool must not be scheduled at all
+	tester.fetcher.Notify("C", []common.Hash{txs[0].Hash()}, time.Now(), tester.makeTxRequester("C"))
+	tester.expectNoRequest(t, 2*txArriveTimeout)
+}
+
+// Tests that transactions not delivered in time are requested from another
+// announcing peer.
+func TestTxFetcherTimeout(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	hash := makeTxs(1)[0].Hash()
+	tester.fetcher.Notify("A", []common.Hash{hash}, time.Now(), tester.makeTxRequester("A"))
+	tester.fetcher.Notify("B", []common.Hash{hash}, time.Now(), tester.makeTxRequester("B"))
+
+	first := tester.expectRequest(t, time.Second, "A", "B")
+	tester.expectNoRequest(t, txFetchTimeout-time.Second)
+
+	second := tester.expectRequest(t, 2*time.Second, "A", "B")
+	if first.peer == second.peer {
+		t.Fatalf("request retried from the same peer %s", first.peer)
+	}
+	// With all announcers tried, the transaction must be given up on
+	tester.expectNoRequest(t, txFetchTimeout+time.Second)
+}
+
+// Tests that requests pending at a dropped peer are retried from another
+// announcing peer right away.
+func TestTxFetcherDrop(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	hash := makeTxs(1)[0].Hash()
+	tester.fetcher.Notify("A", []common.Hash{hash}, time.Now(), tester.makeTxRequester("A"))
+	tester.fetcher.Notify("B", []common.Hash{hash}, time.Now(), tester.makeTxRequester("B"))
+
+	first := tester.expectRequest(t, time.Second, "A", "B")
+	tester.fetcher.Drop(first.peer)
+
+	other := map[string]string{"A": "B", "B": "A"}[first.peer]
+	tester.expectRequest(t, time.Second, other)
+}
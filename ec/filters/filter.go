@@ -38,6 +38,7 @@ type Backend interface {
 
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+	SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription
 	SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
@@ -105,10 +106,24 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
+	if f.begin == rpc.FinalizedBlockNumber.Int64() || f.begin == rpc.SafeBlockNumber.Int64() {
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
+		if header == nil || err != nil {
+			return nil, err
+		}
+		f.begin = header.Number.Int64()
+	}
 	end := uint64(f.end)
 	if f.end == -1 {
 		end = head
 	}
+	if f.end == rpc.FinalizedBlockNumber.Int64() || f.end == rpc.SafeBlockNumber.Int64() {
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.end))
+		if header == nil || err != nil {
+			return nil, err
+		}
+		end = header.Number.Uint64()
+	}
 	// Gather all indexed logs, and finish with non indexed ones
 	var (
 		logs []*types.Log
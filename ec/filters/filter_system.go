@@ -51,6 +51,8 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// FinalizedBlocksSubscription queries headers for blocks that are finalized
+	FinalizedBlocksSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -266,6 +268,22 @@ func (es *EventSystem) SubscribeNewHeads(headers chan *types.Header) *Subscripti
 	return es.subscribe(sub)
 }
 
+// SubscribeFinalizedHeads creates a subscription that writes the header of each
+// block that becomes the finalized checkpoint of the chain.
+func (es *EventSystem) SubscribeFinalizedHeads(headers chan *types.Header) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       FinalizedBlocksSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan common.Hash),
+		headers:   headers,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 // SubscribePendingTxEvents creates a subscription that writes transaction hashes for
 // transactions that enter the transaction pool.
 func (es *EventSystem) SubscribePendingTxEvents(hashes chan common.Hash) *Subscription {
@@ -333,6 +351,10 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 				}
 			})
 		}
+	case core.ChainFinalizedEvent:
+		for _, f := range filters[FinalizedBlocksSubscription] {
+			f.headers <- e.Header
+		}
 	}
 }
 
@@ -426,6 +448,9 @@ func (es *EventSystem) eventLoop() {
 		// Subscribe ChainEvent
 		chainEvCh  = make(chan core.ChainEvent, chainEvChanSize)
 		chainEvSub = es.backend.SubscribeChainEvent(chainEvCh)
+		// Subscribe ChainFinalizedEvent
+		finalizedCh  = make(chan core.ChainFinalizedEvent, chainEvChanSize)
+		finalizedSub = es.backend.SubscribeChainFinalizedEvent(finalizedCh)
 	)
 
 	// Unsubscribe all events
@@ -434,6 +459,7 @@ func (es *EventSystem) eventLoop() {
 	defer rmLogsSub.Unsubscribe()
 	defer logsSub.Unsubscribe()
 	defer chainEvSub.Unsubscribe()
+	defer finalizedSub.Unsubscribe()
 
 	for i := UnknownSubscription; i < LastIndexSubscription; i++ {
 		index[i] = make(map[rpc.ID]*subscription)
@@ -456,6 +482,8 @@ func (es *EventSystem) eventLoop() {
 			es.broadcast(index, ev)
 		case ev := <-chainEvCh:
 			es.broadcast(index, ev)
+		case ev := <-finalizedCh:
+			es.broadcast(index, ev)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
@@ -485,6 +513,8 @@ func (es *EventSystem) eventLoop() {
 			return
 		case <-chainEvSub.Err():
 			return
+		case <-finalizedSub.Err():
+			return
 		}
 	}
 }
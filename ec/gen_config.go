@@ -4,6 +4,7 @@ package ec
 
 import (
 	"math/big"
+	"time"
 
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/hexutil"
@@ -29,6 +30,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerRecommit           time.Duration
+		MinerBuilder            string           `toml:",omitempty"`
+		MinerReservedGas        uint64           `toml:",omitempty"`
+		MinerReservedSenders    []common.Address `toml:",omitempty"`
+		MinerGasTarget          uint64
+		MinerGasLimit           uint64 `toml:",omitempty"`
+		StratumAddr             string           `toml:",omitempty"`
+		StratumDifficulty       uint64           `toml:",omitempty"`
 		ecash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
@@ -48,6 +57,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.MinerRecommit = c.MinerRecommit
+	enc.MinerBuilder = c.MinerBuilder
+	enc.MinerReservedGas = c.MinerReservedGas
+	enc.MinerReservedSenders = c.MinerReservedSenders
+	enc.MinerGasTarget = c.MinerGasTarget
+	enc.MinerGasLimit = c.MinerGasLimit
+	enc.StratumAddr = c.StratumAddr
+	enc.StratumDifficulty = c.StratumDifficulty
 	enc.ecash = c.ecash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
@@ -70,6 +87,14 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		MinerRecommit           *time.Duration
+		MinerBuilder            *string          `toml:",omitempty"`
+		MinerReservedGas        *uint64          `toml:",omitempty"`
+		MinerReservedSenders    []common.Address `toml:",omitempty"`
+		MinerGasTarget          *uint64
+		MinerGasLimit           *uint64 `toml:",omitempty"`
+		StratumAddr             *string          `toml:",omitempty"`
+		StratumDifficulty       *uint64          `toml:",omitempty"`
 		ecash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
@@ -116,6 +141,30 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.MinerRecommit != nil {
+		c.MinerRecommit = *dec.MinerRecommit
+	}
+	if dec.MinerBuilder != nil {
+		c.MinerBuilder = *dec.MinerBuilder
+	}
+	if dec.MinerReservedGas != nil {
+		c.MinerReservedGas = *dec.MinerReservedGas
+	}
+	if dec.MinerReservedSenders != nil {
+		c.MinerReservedSenders = dec.MinerReservedSenders
+	}
+	if dec.MinerGasTarget != nil {
+		c.MinerGasTarget = *dec.MinerGasTarget
+	}
+	if dec.MinerGasLimit != nil {
+		c.MinerGasLimit = *dec.MinerGasLimit
+	}
+	if dec.StratumAddr != nil {
+		c.StratumAddr = *dec.StratumAddr
+	}
+	if dec.StratumDifficulty != nil {
+		c.StratumDifficulty = *dec.StratumDifficulty
+	}
 	if dec.ecash != nil {
 		c.ecash = *dec.ecash
 	}
@@ -38,6 +38,7 @@ import (
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/p2p"
 	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/params"
 	"github.com/ecchain/go-ecchain/rlp"
 )
@@ -59,8 +60,19 @@ var (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// protocolError is returned by the message handlers if a peer violates the
+// protocol. Such peers are penalized in addition to being disconnected.
+type protocolError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protocolError{code, fmt.Sprintf(format, v...)}
 }
 
 type ProtocolManager struct {
@@ -76,6 +88,7 @@ type ProtocolManager struct {
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
 
 	SubProtocols []p2p.Protocol
@@ -117,11 +130,16 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
+	if mode == downloader.FastSync && config.Clique != nil && config.Clique.Governance != nil {
+		log.Warn("Governance managed signers need the chain state, fast sync disabled")
+		mode = downloader.FullSync
+	}
 	if mode == downloader.FastSync {
 		manager.fastSync = uint32(1)
 	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
+	entry, filter := currentENREntry(blockchain), newDialFilter(blockchain)
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
 		if mode == downloader.FastSync && version < ec63 {
@@ -153,13 +171,15 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{entry},
+			DialFilter: filter,
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
 	// Construct the different synchronisation mechanisms
-	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
+	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.dropPeer)
 
 	validator := func(header *types.Header) error {
 		return engine.VerifyHeader(blockchain, header, true)
@@ -176,7 +196,12 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		atomic.StoreUint32(&manager.acceptTxs, 1) // Mark initial sync done on any fetcher import
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropPeer)
+
+	hasTx := func(hash common.Hash) bool {
+		return txpool.Get(hash) != nil
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, txpool.AddRemotes)
 
 	return manager, nil
 }
@@ -191,6 +216,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 	// Unregister the peer from the downloader and ecchain peer set
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -200,6 +226,15 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+// dropPeer penalizes a peer the downloader or fetcher caught stalling or
+// delivering bad data, and disconnects it.
+func (pm *ProtocolManager) dropPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Peer.Penalize(p2p.PenaltyUseless, "dropped by synchronisation")
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -308,6 +343,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("ecchain message handling failed", "err", err)
+			if _, ok := err.(*protocolError); ok {
+				p.Peer.Penalize(p2p.PenaltyInvalid, err.Error())
+			}
 			return err
 		}
 	}
@@ -671,7 +709,67 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+		pm.txFetcher.Enqueue(p.id, txs)
+
+	case p.version >= ec64 && msg.Code == NewPooledTransactionHashesMsg:
+		// Transactions were announced, make sure we have a valid and fresh chain to handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Mark the hashes as present at the remote node and schedule the unknown ones for retrieval
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes, time.Now(), p.RequestTxs)
+
+	case p.version >= ec64 && msg.Code == GetPooledTransactionsMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather transactions until the fetch or network limits is reached
+		var (
+			hash  common.Hash
+			bytes common.StorageSize
+			txs   types.Transactions
+		)
+		for bytes < softResponseLimit && len(txs) < fetcher.MaxTxFetch {
+			// Retrieve the hash of the next transaction
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			// Retrieve the requested transaction, skipping if unknown to us
+			if tx := pm.txpool.Get(hash); tx != nil {
+				txs = append(txs, tx)
+				bytes += tx.Size()
+			}
+		}
+		return p.SendPooledTransactions(txs)
+
+	case p.version >= ec64 && msg.Code == PooledTransactionsMsg:
+		// Requested transactions arrived, make sure we have a valid and fresh chain to handle them
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for i, tx := range txs {
+			// Validate and mark the remote transaction
+			if tx == nil {
+				return errResp(ErrDecode, "transaction %d is nil", i)
+			}
+			p.MarkTransaction(tx.Hash())
+		}
+		pm.txFetcher.Enqueue(p.id, txs)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -712,16 +810,37 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 	}
 }
 
-// BroadcastTx will propagate a transaction to all peers which are not known to
-// already have the given transaction.
+// BroadcastTx will propagate a transaction to a subset of the peers which are
+// not known to already have the given transaction, and announce its availability
+// to the rest.
 func (pm *ProtocolManager) BroadcastTx(hash common.Hash, tx *types.Transaction) {
-	// Broadcast transaction to a batch of peers not knowing about it
 	peers := pm.peers.PeersWithoutTx(hash)
-	//FIXME include this again: peers = peers[:int(math.Sqrt(float64(len(peers))))]
+
+	// Send the transaction to a square root of the peers and announce it to the
+	// rest. Peers not supporting announcements always get it and count towards
+	// the square root.
+	direct := int(math.Sqrt(float64(len(peers))))
+	for _, peer := range peers {
+		if peer.version < ec64 {
+			direct--
+		}
+	}
+	var transfer, announce int
 	for _, peer := range peers {
-		peer.SendTransactions(types.Transactions{tx})
+		switch {
+		case peer.version < ec64:
+			peer.SendTransactions(types.Transactions{tx})
+			transfer++
+		case direct > 0:
+			peer.SendTransactions(types.Transactions{tx})
+			transfer++
+			direct--
+		default:
+			peer.SendPooledTransactionHashes([]common.Hash{hash})
+			announce++
+		}
 	}
-	log.Trace("Broadcast transaction", "hash", hash, "recipients", len(peers))
+	log.Trace("Broadcast transaction", "hash", hash, "recipients", transfer, "announced", announce)
 }
 
 // Mined broadcast loop
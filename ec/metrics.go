@@ -22,38 +22,46 @@ import (
 )
 
 var (
-	propTxnInPacketsMeter     = metrics.NewRegisteredMeter("ec/prop/txns/in/packets", nil)
-	propTxnInTrafficMeter     = metrics.NewRegisteredMeter("ec/prop/txns/in/traffic", nil)
-	propTxnOutPacketsMeter    = metrics.NewRegisteredMeter("ec/prop/txns/out/packets", nil)
-	propTxnOutTrafficMeter    = metrics.NewRegisteredMeter("ec/prop/txns/out/traffic", nil)
-	propHashInPacketsMeter    = metrics.NewRegisteredMeter("ec/prop/hashes/in/packets", nil)
-	propHashInTrafficMeter    = metrics.NewRegisteredMeter("ec/prop/hashes/in/traffic", nil)
-	propHashOutPacketsMeter   = metrics.NewRegisteredMeter("ec/prop/hashes/out/packets", nil)
-	propHashOutTrafficMeter   = metrics.NewRegisteredMeter("ec/prop/hashes/out/traffic", nil)
-	propBlockInPacketsMeter   = metrics.NewRegisteredMeter("ec/prop/blocks/in/packets", nil)
-	propBlockInTrafficMeter   = metrics.NewRegisteredMeter("ec/prop/blocks/in/traffic", nil)
-	propBlockOutPacketsMeter  = metrics.NewRegisteredMeter("ec/prop/blocks/out/packets", nil)
-	propBlockOutTrafficMeter  = metrics.NewRegisteredMeter("ec/prop/blocks/out/traffic", nil)
-	reqHeaderInPacketsMeter   = metrics.NewRegisteredMeter("ec/req/headers/in/packets", nil)
-	reqHeaderInTrafficMeter   = metrics.NewRegisteredMeter("ec/req/headers/in/traffic", nil)
-	reqHeaderOutPacketsMeter  = metrics.NewRegisteredMeter("ec/req/headers/out/packets", nil)
-	reqHeaderOutTrafficMeter  = metrics.NewRegisteredMeter("ec/req/headers/out/traffic", nil)
-	reqBodyInPacketsMeter     = metrics.NewRegisteredMeter("ec/req/bodies/in/packets", nil)
-	reqBodyInTrafficMeter     = metrics.NewRegisteredMeter("ec/req/bodies/in/traffic", nil)
-	reqBodyOutPacketsMeter    = metrics.NewRegisteredMeter("ec/req/bodies/out/packets", nil)
-	reqBodyOutTrafficMeter    = metrics.NewRegisteredMeter("ec/req/bodies/out/traffic", nil)
-	reqStateInPacketsMeter    = metrics.NewRegisteredMeter("ec/req/states/in/packets", nil)
-	reqStateInTrafficMeter    = metrics.NewRegisteredMeter("ec/req/states/in/traffic", nil)
-	reqStateOutPacketsMeter   = metrics.NewRegisteredMeter("ec/req/states/out/packets", nil)
-	reqStateOutTrafficMeter   = metrics.NewRegisteredMeter("ec/req/states/out/traffic", nil)
-	reqReceiptInPacketsMeter  = metrics.NewRegisteredMeter("ec/req/receipts/in/packets", nil)
-	reqReceiptInTrafficMeter  = metrics.NewRegisteredMeter("ec/req/receipts/in/traffic", nil)
-	reqReceiptOutPacketsMeter = metrics.NewRegisteredMeter("ec/req/receipts/out/packets", nil)
-	reqReceiptOutTrafficMeter = metrics.NewRegisteredMeter("ec/req/receipts/out/traffic", nil)
-	miscInPacketsMeter        = metrics.NewRegisteredMeter("ec/misc/in/packets", nil)
-	miscInTrafficMeter        = metrics.NewRegisteredMeter("ec/misc/in/traffic", nil)
-	miscOutPacketsMeter       = metrics.NewRegisteredMeter("ec/misc/out/packets", nil)
-	miscOutTrafficMeter       = metrics.NewRegisteredMeter("ec/misc/out/traffic", nil)
+	propTxnInPacketsMeter      = metrics.NewRegisteredMeter("ec/prop/txns/in/packets", nil)
+	propTxnInTrafficMeter      = metrics.NewRegisteredMeter("ec/prop/txns/in/traffic", nil)
+	propTxnOutPacketsMeter     = metrics.NewRegisteredMeter("ec/prop/txns/out/packets", nil)
+	propTxnOutTrafficMeter     = metrics.NewRegisteredMeter("ec/prop/txns/out/traffic", nil)
+	propTxnHashInPacketsMeter  = metrics.NewRegisteredMeter("ec/prop/txhashes/in/packets", nil)
+	propTxnHashInTrafficMeter  = metrics.NewRegisteredMeter("ec/prop/txhashes/in/traffic", nil)
+	propTxnHashOutPacketsMeter = metrics.NewRegisteredMeter("ec/prop/txhashes/out/packets", nil)
+	propTxnHashOutTrafficMeter = metrics.NewRegisteredMeter("ec/prop/txhashes/out/traffic", nil)
+	propHashInPacketsMeter     = metrics.NewRegisteredMeter("ec/prop/hashes/in/packets", nil)
+	propHashInTrafficMeter     = metrics.NewRegisteredMeter("ec/prop/hashes/in/traffic", nil)
+	propHashOutPacketsMeter    = metrics.NewRegisteredMeter("ec/prop/hashes/out/packets", nil)
+	propHashOutTrafficMeter    = metrics.NewRegisteredMeter("ec/prop/hashes/out/traffic", nil)
+	propBlockInPacketsMeter    = metrics.NewRegisteredMeter("ec/prop/blocks/in/packets", nil)
+	propBlockInTrafficMeter    = metrics.NewRegisteredMeter("ec/prop/blocks/in/traffic", nil)
+	propBlockOutPacketsMeter   = metrics.NewRegisteredMeter("ec/prop/blocks/out/packets", nil)
+	propBlockOutTrafficMeter   = metrics.NewRegisteredMeter("ec/prop/blocks/out/traffic", nil)
+	reqHeaderInPacketsMeter    = metrics.NewRegisteredMeter("ec/req/headers/in/packets", nil)
+	reqHeaderInTrafficMeter    = metrics.NewRegisteredMeter("ec/req/headers/in/traffic", nil)
+	reqHeaderOutPacketsMeter   = metrics.NewRegisteredMeter("ec/req/headers/out/packets", nil)
+	reqHeaderOutTrafficMeter   = metrics.NewRegisteredMeter("ec/req/headers/out/traffic", nil)
+	reqBodyInPacketsMeter      = metrics.NewRegisteredMeter("ec/req/bodies/in/packets", nil)
+	reqBodyInTrafficMeter      = metrics.NewRegisteredMeter("ec/req/bodies/in/traffic", nil)
+	reqBodyOutPacketsMeter     = metrics.NewRegisteredMeter("ec/req/bodies/out/packets", nil)
+	reqBodyOutTrafficMeter     = metrics.NewRegisteredMeter("ec/req/bodies/out/traffic", nil)
+	reqStateInPacketsMeter     = metrics.NewRegisteredMeter("ec/req/states/in/packets", nil)
+	reqStateInTrafficMeter     = metrics.NewRegisteredMeter("ec/req/states/in/traffic", nil)
+	reqStateOutPacketsMeter    = metrics.NewRegisteredMeter("ec/req/states/out/packets", nil)
+	reqStateOutTrafficMeter    = metrics.NewRegisteredMeter("ec/req/states/out/traffic", nil)
+	reqReceiptInPacketsMeter   = metrics.NewRegisteredMeter("ec/req/receipts/in/packets", nil)
+	reqReceiptInTrafficMeter   = metrics.NewRegisteredMeter("ec/req/receipts/in/traffic", nil)
+	reqReceiptOutPacketsMeter  = metrics.NewRegisteredMeter("ec/req/receipts/out/packets", nil)
+	reqReceiptOutTrafficMeter  = metrics.NewRegisteredMeter("ec/req/receipts/out/traffic", nil)
+	reqTxnInPacketsMeter       = metrics.NewRegisteredMeter("ec/req/txns/in/packets", nil)
+	reqTxnInTrafficMeter       = metrics.NewRegisteredMeter("ec/req/txns/in/traffic", nil)
+	reqTxnOutPacketsMeter      = metrics.NewRegisteredMeter("ec/req/txns/out/packets", nil)
+	reqTxnOutTrafficMeter      = metrics.NewRegisteredMeter("ec/req/txns/out/traffic", nil)
+	miscInPacketsMeter         = metrics.NewRegisteredMeter("ec/misc/in/packets", nil)
+	miscInTrafficMeter         = metrics.NewRegisteredMeter("ec/misc/in/traffic", nil)
+	miscOutPacketsMeter        = metrics.NewRegisteredMeter("ec/misc/out/packets", nil)
+	miscOutTrafficMeter        = metrics.NewRegisteredMeter("ec/misc/out/traffic", nil)
 )
 
 // meteredMsgReadWriter is a wrapper around a p2p.MsgReadWriter, capable of
@@ -96,6 +104,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
 	case rw.version >= ec63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptInPacketsMeter, reqReceiptInTrafficMeter
+	case rw.version >= ec64 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = reqTxnInPacketsMeter, reqTxnInTrafficMeter
 
 	case msg.Code == NewBlockHashesMsg:
 		packets, traffic = propHashInPacketsMeter, propHashInTrafficMeter
@@ -103,6 +113,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
+	case rw.version >= ec64 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxnHashInPacketsMeter, propTxnHashInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -123,6 +135,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
 	case rw.version >= ec63 && msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptOutPacketsMeter, reqReceiptOutTrafficMeter
+	case rw.version >= ec64 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = reqTxnOutPacketsMeter, reqTxnOutTrafficMeter
 
 	case msg.Code == NewBlockHashesMsg:
 		packets, traffic = propHashOutPacketsMeter, propHashOutTrafficMeter
@@ -130,6 +144,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
+	case rw.version >= ec64 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxnHashOutPacketsMeter, propTxnHashOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -32,16 +32,17 @@ import (
 const (
 	ec62 = 62
 	ec63 = 63
+	ec64 = 64
 )
 
 // Official short name of the protocol used during capability negotiation.
 var ProtocolName = "ec"
 
 // Supported versions of the ec protocol (first is primary).
-var ProtocolVersions = []uint{ec63, ec62}
+var ProtocolVersions = []uint{ec64, ec63, ec62}
 
 // Number of implemented message corresponding to different protocol versions.
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{20, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
@@ -62,6 +63,11 @@ const (
 	NodeDataMsg    = 0x0e
 	GetReceiptsMsg = 0x0f
 	ReceiptsMsg    = 0x10
+
+	// Protocol messages belonging to ec/64
+	NewPooledTransactionHashesMsg = 0x11
+	GetPooledTransactionsMsg      = 0x12
+	PooledTransactionsMsg         = 0x13
 )
 
 type errCode int
@@ -103,6 +109,10 @@ type txPool interface {
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
 
+	// Get should return the transaction with the given hash, nil if it is not
+	// in the pool.
+	Get(hash common.Hash) *types.Transaction
+
 	// SubscribeTxPreEvent should return an event subscription of
 	// TxPreEvent and send events to the given channel.
 	SubscribeTxPreEvent(chan<- core.TxPreEvent) event.Subscription
@@ -95,6 +95,7 @@ func tesecatusMsgErrors(t *testing.T, protocol int) {
 // This test checks that received transactions are added to the local pool.
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -123,6 +124,7 @@ func testRecvTransactions(t *testing.T, protocol int) {
 // This test checks that pending transactions are sent.
 func TestSendTransactions62(t *testing.T) { testSendTransactions(t, 62) }
 func TestSendTransactions63(t *testing.T) { testSendTransactions(t, 63) }
+func TestSendTransactions64(t *testing.T) { testSendTransactions(t, 64) }
 
 func testSendTransactions(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
@@ -178,6 +180,113 @@ func testSendTransactions(t *testing.T, protocol int) {
 	wg.Wait()
 }
 
+// Tests that announced transactions are retrieved from the announcing peer and
+// added to the pool.
+func TestRecvPooledTransactionHashes64(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	p, _ := newTestPeer("peer", 64, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, GetPooledTransactionsMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("transaction request mismatch: %v", err)
+	}
+	if err := p2p.Send(p.app, PooledTransactionsMsg, []interface{}{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added wrong transactions: got %v, want %v", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no transaction added within 2 seconds")
+	}
+}
+
+// Tests that transactions in the pool can be retrieved by hash, unknown ones
+// being skipped.
+func TestGetPooledTransactions64(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	txs := []*types.Transaction{newTestTransaction(testAccount, 0, 0), newTestTransaction(testAccount, 1, 0)}
+	pm.txpool.AddRemotes(txs)
+
+	p, _ := newTestPeer("peer", 64, pm, true)
+	defer p.close()
+
+	// Drain the pool content sent on connection before requesting
+	if err := p2p.ExpectMsg(p.app, TxMsg, txs); err != nil {
+		t.Fatalf("initial transactions mismatch: %v", err)
+	}
+	hashes := []common.Hash{txs[1].Hash(), {}, txs[0].Hash()}
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, hashes); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if err := p2p.ExpectMsg(p.app, PooledTransactionsMsg, []*types.Transaction{txs[1], txs[0]}); err != nil {
+		t.Errorf("transactions mismatch: %v", err)
+	}
+}
+
+// Tests that transactions are sent to a square root of the peers and only
+// announced to the rest, peers without announcement support always getting them.
+func TestBroadcastTransactionAnnounce(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var (
+		versions = []int{64, 64, 64, 64, 63}
+		peers    = make([]*testPeer, len(versions))
+		codes    = make(chan uint64, len(versions))
+	)
+	for i, version := range versions {
+		peers[i], _ = newTestPeer(fmt.Sprintf("peer #%d", i), version, pm, true)
+		defer peers[i].close()
+	}
+	// Wait until all peers are registered
+	for pm.peers.Len() < len(versions) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tx := newTestTransaction(testAccount, 0, 0)
+	go pm.BroadcastTx(tx.Hash(), tx)
+
+	for _, p := range peers {
+		go func(p *testPeer) {
+			msg, err := p.app.ReadMsg()
+			if err != nil {
+				t.Errorf("%v: read error: %v", p.Peer, err)
+				codes <- 0
+				return
+			}
+			msg.Discard()
+			if p.version < ec64 && msg.Code != TxMsg {
+				t.Errorf("%v: got code %d, want TxMsg", p.Peer, msg.Code)
+			}
+			codes <- msg.Code
+		}(p)
+	}
+	count := make(map[uint64]int)
+	for range versions {
+		select {
+		case code := <-codes:
+			count[code]++
+		case <-time.After(2 * time.Second):
+			t.Fatalf("transaction not broadcast to all peers")
+		}
+	}
+	// The old peer and one other peer get the transaction, the rest an announcement
+	if count[TxMsg] != 2 || count[NewPooledTransactionHashesMsg] != 3 {
+		t.Errorf("broadcast mismatch: have %d transfers and %d announcements, want 2 and 3", count[TxMsg], count[NewPooledTransactionHashesMsg])
+	}
+}
+
 // Tests that the custom union field encoder and decoder works correctly.
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 	// Create a "random" hash for testing
@@ -18,6 +18,7 @@ package ecdb_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,6 +27,7 @@ import (
 	"testing"
 
 	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 func newTestLDB() (*ecdb.LDBDatabase, func()) {
@@ -190,3 +192,54 @@ func testParallelPutGet(db ecdb.Database, t *testing.T) {
 	}
 	pending.Wait()
 }
+
+// spanCollector gathers all exported tracing spans in memory.
+type spanCollector struct {
+	spans []*tracing.Span
+}
+
+func (c *spanCollector) Export(spans []*tracing.Span) error {
+	c.spans = append(c.spans, spans...)
+	return nil
+}
+
+func (c *spanCollector) Close() error { return nil }
+
+// Tests that database reads are traced as children of the span in the context,
+// and not at all without one.
+func TestTracedReads(t *testing.T) {
+	db, _ := ecdb.NewMemDatabase()
+	db.Put([]byte("key"), []byte("value"))
+
+	collector := new(spanCollector)
+	tracing.Start(collector)
+
+	ecdb.TracedGet(context.Background(), db, []byte("key"))
+	ecdb.TracedHas(context.Background(), db, []byte("key"))
+
+	ctx, parent := tracing.StartSpan(context.Background(), "test")
+	if blob, err := ecdb.TracedGet(ctx, db, []byte("key")); err != nil || string(blob) != "value" {
+		t.Errorf("traced get mismatch: have %q (err %v), want %q", blob, err, "value")
+	}
+	if ok, err := ecdb.TracedHas(ctx, db, []byte("missing")); ok || err != nil {
+		t.Errorf("traced has mismatch: have %v (err %v), want false", ok, err)
+	}
+	parent.End()
+
+	if err := tracing.Stop(); err != nil {
+		t.Fatalf("failed to stop tracer: %v", err)
+	}
+	var names []string
+	for _, span := range collector.spans {
+		if span == parent {
+			continue
+		}
+		if span.ParentID != parent.SpanID {
+			t.Errorf("span %s not a child of the context span", span.Name)
+		}
+		names = append(names, span.Name)
+	}
+	if len(names) != 2 || names[0] != "ecdb.Get" || names[1] != "ecdb.Has" {
+		t.Errorf("traced reads mismatch: have %v, want [ecdb.Get ecdb.Has]", names)
+	}
+}
@@ -16,6 +16,12 @@
 
 package ecdb
 
+import (
+	"context"
+
+	"github.com/ecchain/go-ecchain/tracing"
+)
+
 // Code using batches should try to add this much data to the batch.
 // The value was determined empirically.
 const IdealBatchSize = 100 * 1024
@@ -35,6 +41,34 @@ type Database interface {
 	NewBatch() Batch
 }
 
+// TracedGet retrieves the given key from the database. If the context carries a
+// span, the read is traced as a child of it.
+func TracedGet(ctx context.Context, db Database, key []byte) ([]byte, error) {
+	if tracing.FromContext(ctx) == nil {
+		return db.Get(key)
+	}
+	_, span := tracing.StartSpan(ctx, "ecdb.Get")
+	defer span.End()
+
+	dat, err := db.Get(key)
+	span.SetAttribute("db.bytes", len(dat))
+	return dat, err
+}
+
+// TracedHas checks whecer the given key is present in the database. If the
+// context carries a span, the lookup is traced as a child of it.
+func TracedHas(ctx context.Context, db Database, key []byte) (bool, error) {
+	if tracing.FromContext(ctx) == nil {
+		return db.Has(key)
+	}
+	_, span := tracing.StartSpan(ctx, "ecdb.Has")
+	defer span.End()
+
+	ok, err := db.Has(key)
+	span.SetAttribute("db.found", ok)
+	return ok, err
+}
+
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
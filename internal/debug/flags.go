@@ -52,6 +52,30 @@ var (
 		Name:  "debug",
 		Usage: "Prepends log messages with call-site location (file and line number)",
 	}
+	logFormatFlag = cli.StringFlag{
+		Name:  "log.format",
+		Usage: "Log output format: terminal, logfmt or json",
+		Value: "terminal",
+	}
+	logFileFlag = cli.StringFlag{
+		Name:  "log.file",
+		Usage: "Write logs to the given file in addition to stderr",
+		Value: "",
+	}
+	logMaxSizeFlag = cli.UintFlag{
+		Name:  "log.maxsize",
+		Usage: "Maximum size in megabytes of the log file before it gets rotated (0 = no rotation)",
+		Value: 100,
+	}
+	logMaxAgeFlag = cli.DurationFlag{
+		Name:  "log.maxage",
+		Usage: "Maximum age of rotated log files before they are deleted (0 = keep forever)",
+		Value: 0,
+	}
+	logCompressFlag = cli.BoolFlag{
+		Name:  "log.compress",
+		Usage: "Compress rotated log files using gzip",
+	}
 	pprofFlag = cli.BoolFlag{
 		Name:  "pprof",
 		Usage: "Enable the pprof HTTP server",
@@ -88,6 +112,7 @@ var (
 // Flags holds all command-line flags required for debugging.
 var Flags = []cli.Flag{
 	verbosityFlag, vmoduleFlag, backtraceAtFlag, debugFlag,
+	logFormatFlag, logFileFlag, logMaxSizeFlag, logMaxAgeFlag, logCompressFlag,
 	pprofFlag, pprofAddrFlag, pprofPortFlag,
 	memprofilerateFlag, blockprofilerateFlag, cpuprofileFlag, traceFlag,
 }
@@ -107,6 +132,9 @@ func init() {
 // It should be called as early as possible in the program.
 func Setup(ctx *cli.Context) error {
 	// logging
+	if err := setupLogOutput(ctx); err != nil {
+		return err
+	}
 	log.PrintOrigins(ctx.GlobalBool(debugFlag.Name))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(verbosityFlag.Name)))
 	glogger.Vmodule(ctx.GlobalString(vmoduleFlag.Name))
@@ -144,6 +172,59 @@ func Setup(ctx *cli.Context) error {
 	return nil
 }
 
+// setupLogOutput replaces the default terminal output of the glog handler with
+// the format and optional rotating log file requested on the command line. The
+// verbosity and vmodule filters apply to all outputs alike.
+func setupLogOutput(ctx *cli.Context) error {
+	format := ctx.GlobalString(logFormatFlag.Name)
+	file := ctx.GlobalString(logFileFlag.Name)
+
+	if format == logFormatFlag.Value && file == "" {
+		return nil // keep the default (colored) terminal output
+	}
+	var (
+		usecolor = format == "terminal" && term.IsTty(os.Stderr.Fd()) && os.Getenv("TERM") != "dumb"
+		output   = io.Writer(os.Stderr)
+	)
+	if usecolor {
+		output = colorable.NewColorableStderr()
+	}
+	fmtr, err := logFormat(format, usecolor)
+	if err != nil {
+		return err
+	}
+	handler := log.StreamHandler(output, fmtr)
+
+	if file != "" {
+		// Never write colored output or escape codes into files
+		if fmtr, err = logFormat(format, false); err != nil {
+			return err
+		}
+		limit := ctx.GlobalUint(logMaxSizeFlag.Name) * 1024 * 1024
+		rotating, err := log.RotatingFileHandler(file, limit, ctx.GlobalDuration(logMaxAgeFlag.Name), ctx.GlobalBool(logCompressFlag.Name), fmtr)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		handler = log.MultiHandler(handler, rotating)
+	}
+	glogger.SetHandler(handler)
+	return nil
+}
+
+// logFormat maps a log format name from the command line to a log formatter.
+func logFormat(name string, usecolor bool) (log.Format, error) {
+	switch name {
+	case "terminal":
+		return log.TerminalFormat(usecolor), nil
+	case "logfmt":
+		return log.LogfmtFormat(), nil
+	case "json":
+		return log.JsonFormat(), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want terminal, logfmt or json)", name)
+	}
+}
+
 // Exit stops all running profiles, flushing their output to the
 // respective file.
 func Exit() {
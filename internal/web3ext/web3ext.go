@@ -19,9 +19,11 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":      Admin_JS,
+	"bft":        BFT_JS,
 	"chequebook": Chequebook_JS,
 	"clique":     Clique_JS,
 	"debug":      Debug_JS,
+	"dev":        Dev_JS,
 	"ec":        ec_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
@@ -99,6 +101,11 @@ web3._extend({
 			call: 'clique_discard',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'status',
+			call: 'clique_status',
+			params: 0
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -109,6 +116,52 @@ web3._extend({
 });
 `
 
+const BFT_JS = `
+web3._extend({
+	property: 'bft',
+	methods: [
+		new web3._extend.Method({
+			name: 'getSnapshot',
+			call: 'bft_getSnapshot',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSnapshotAtHash',
+			call: 'bft_getSnapshotAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getValidators',
+			call: 'bft_getValidators',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getValidatorsAtHash',
+			call: 'bft_getValidatorsAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'bft_propose',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'discard',
+			call: 'bft_discard',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'proposals',
+			getter: 'bft_proposals'
+		}),
+	]
+});
+`
+
 const Admin_JS = `
 web3._extend({
 	property: 'admin',
@@ -123,6 +176,34 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'registerTopic',
+			call: 'admin_registerTopic',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unregisterTopic',
+			call: 'admin_unregisterTopic',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'searchTopic',
+			call: 'admin_searchTopic',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -169,6 +250,10 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'peerScores',
+			getter: 'admin_peerScores'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
@@ -177,6 +262,45 @@ web3._extend({
 });
 `
 
+const Dev_JS = `
+web3._extend({
+	property: 'dev',
+	methods: [
+		new web3._extend.Method({
+			name: 'mine',
+			call: 'dev_mine',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'setAutomine',
+			call: 'dev_setAutomine',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTimestamp',
+			call: 'dev_setTimestamp',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'increaseTime',
+			call: 'dev_increaseTime',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'snapshot',
+			call: 'dev_snapshot'
+		}),
+		new web3._extend.Method({
+			name: 'revert',
+			call: 'dev_revert',
+			params: 1
+		}),
+	]
+});
+`
+
 const Debug_JS = `
 web3._extend({
 	property: 'debug',
@@ -339,6 +463,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'standardTraceBlockToFile',
+			call: 'debug_standardTraceBlockToFile',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
@@ -474,6 +604,17 @@ web3._extend({
 			call: 'miner_setExtra',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'setGasLimit',
+			call: 'miner_setGasLimit',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setRecommitInterval',
+			call: 'miner_setRecommitInterval',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'setGasPrice',
 			call: 'miner_setGasPrice',
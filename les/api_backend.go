@@ -59,6 +59,21 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNum
 	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.PendingBlockNumber {
 		return b.ec.blockchain.CurrentHeader(), nil
 	}
+	if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		// Light clients don't track checkpoints, derive them from the head
+		depth, ok := b.ec.chainConfig.Finality()
+		if !ok {
+			return nil, core.ErrNoFinality
+		}
+		if blockNr == rpc.SafeBlockNumber {
+			depth /= 2
+		}
+		head := b.ec.blockchain.CurrentHeader().Number.Uint64()
+		if head < depth {
+			depth = head
+		}
+		return b.ec.blockchain.GetHeaderByNumberOdr(ctx, head-depth)
+	}
 
 	return b.ec.blockchain.GetHeaderByNumberOdr(ctx, uint64(blockNr))
 }
@@ -141,6 +156,10 @@ func (b *LesApiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) e
 	return b.ec.blockchain.SubscribeChainHeadEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return b.ec.blockchain.SubscribeChainFinalizedEvent(ch)
+}
+
 func (b *LesApiBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
 	return b.ec.blockchain.SubscribeChainSideEvent(ch)
 }
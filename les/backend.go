@@ -18,10 +18,12 @@
 package les
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	ecereum "github.com/ecchain/go-ecchain"
 	"github.com/ecchain/go-ecchain/accounts"
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/common/hexutil"
@@ -89,6 +91,9 @@ func New(ctx *node.ServiceContext, config *ec.Config) (*Lightecchain, error) {
 		return nil, genesisErr
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
+	if chainConfig.Clique != nil && chainConfig.Clique.Governance != nil {
+		return nil, errors.New("light client can't verify governance managed clique signers")
+	}
 
 	peers := newPeerSet()
 	quitSync := make(chan struct{})
@@ -212,6 +217,18 @@ func (s *Lightecchain) LesVersion() int                    { return int(s.protoc
 func (s *Lightecchain) Downloader() *downloader.Downloader { return s.protocolManager.downloader }
 func (s *Lightecchain) EventMux() *event.TypeMux           { return s.eventMux }
 
+// SyncProgress implements node.ChainHealthReporter, returning the current
+// synchronisation status of the header downloader.
+func (s *Lightecchain) SyncProgress() ecereum.SyncProgress {
+	return s.protocolManager.downloader.Progress()
+}
+
+// HeadTime implements node.ChainHealthReporter, returning the timestamp of the
+// current head header.
+func (s *Lightecchain) HeadTime() time.Time {
+	return time.Unix(s.blockchain.CurrentHeader().Time.Int64(), 0)
+}
+
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Lightecchain) Protocols() []p2p.Protocol {
@@ -66,8 +66,19 @@ const (
 // not compatible (low protocol version restrictions and high requirements).
 var errIncompatibleConfig = errors.New("incompatible configuration")
 
+// protocolError is returned by the message handlers if a peer violates the
+// protocol. Such peers are penalized in addition to being disconnected.
+type protocolError struct {
+	code errCode
+	msg  string
+}
+
+func (e *protocolError) Error() string {
+	return fmt.Sprintf("%v - %v", e.code, e.msg)
+}
+
 func errResp(code errCode, format string, v ...interface{}) error {
-	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+	return &protocolError{code, fmt.Sprintf(format, v...)}
 }
 
 type BlockChain interface {
@@ -198,7 +209,7 @@ func NewProtocolManager(chainConfig *params.ChainConfig, lightSync bool, protoco
 		return nil, errIncompatibleConfig
 	}
 
-	removePeer := manager.removePeer
+	removePeer := manager.dropPeer
 	if disableClientRemovePeer {
 		removePeer = func(id string) {}
 	}
@@ -217,6 +228,15 @@ func (pm *ProtocolManager) removePeer(id string) {
 	pm.peers.Unregister(id)
 }
 
+// dropPeer penalizes a peer the downloader or fetcher caught stalling or
+// delivering bad data, and removes it.
+func (pm *ProtocolManager) dropPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Peer.Penalize(p2p.PenaltyUseless, "dropped by synchronisation")
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -325,6 +345,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	for {
 		if err := pm.handleMsg(p); err != nil {
 			p.Log().Debug("Light ecchain message handling failed", "err", err)
+			if _, ok := err.(*protocolError); ok {
+				p.Peer.Penalize(p2p.PenaltyInvalid, err.Error())
+			}
 			return err
 		}
 	}
@@ -494,6 +494,12 @@ func (self *LightChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return self.scope.Track(new(event.Feed).Subscribe(ch))
 }
 
+// SubscribeChainFinalizedEvent implements the interface of filters.Backend
+// LightChain does not track finalized blocks, so return an empty subscription.
+func (self *LightChain) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return self.scope.Track(new(event.Feed).Subscribe(ch))
+}
+
 // SubscribeRemovedLogsEvent implements the interface of filters.Backend
 // LightChain does not send core.RemovedLogsEvent, so return an empty subscription.
 func (self *LightChain) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
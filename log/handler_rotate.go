@@ -0,0 +1,206 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the timestamp format appended to rotated log files.
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFileHandler returns a handler which writes log records to the file at
+// the given path, rotating it whenever its size would exceed limit bytes. A zero
+// limit disables rotation. Rotated files are suffixed with their rotation time,
+// optionally gzip compressed, and deleted once they are older than maxAge (zero
+// keeps them forever).
+func RotatingFileHandler(path string, limit uint, maxAge time.Duration, compress bool, fmtr Format) (Handler, error) {
+	w, err := newRotatingWriter(path, limit, maxAge, compress)
+	if err != nil {
+		return nil, err
+	}
+	return closingHandler{w, StreamHandler(w, fmtr)}, nil
+}
+
+// rotatingWriter is an io.WriteCloser appending to a file which gets rotated
+// out once it reaches a size limit.
+type rotatingWriter struct {
+	path     string        // Path of the active log file
+	limit    uint          // Maximum size of the active log file in bytes
+	maxAge   time.Duration // Maximum age of rotated files before deletion
+	compress bool          // Whecer to gzip rotated files
+
+	file *os.File   // Currently active log file
+	size uint       // Number of bytes written into the active file
+	lock sync.Mutex // Lock protecting the file and size during rotation
+}
+
+// newRotatingWriter opens (or creates) the log file at path for appending.
+func newRotatingWriter(path string, limit uint, maxAge time.Duration, compress bool) (*rotatingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	w := &rotatingWriter{
+		path:     path,
+		limit:    limit,
+		maxAge:   maxAge,
+		compress: compress,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the active log file, picking up the size of any existing content.
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, uint(info.Size())
+	return nil
+}
+
+// Write implements io.Writer, rotating the active file if the new content would
+// push it over the size limit.
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.limit > 0 && w.size > 0 && w.size+uint(len(p)) > w.limit {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += uint(n)
+	return n, err
+}
+
+// Close implements io.Closer, closing the active log file.
+func (w *rotatingWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.file.Close()
+}
+
+// rotate moves the active log file out of the way, opens a fresh one and starts
+// post-processing the old files in the background.
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	// Pick a unique name for the rotated file, even with sub-millisecond rotations
+	var rotated string
+	for stamp := time.Now(); ; stamp = stamp.Add(time.Millisecond) {
+		rotated = w.path + "." + stamp.Format(rotatedTimeFormat)
+		if !fileExists(rotated) && !fileExists(rotated+".gz") {
+			break
+		}
+	}
+	if err := os.Rename(w.path, rotated); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	go w.cleanup(rotated)
+	return nil
+}
+
+// cleanup compresses the freshly rotated log file if requested and deletes all
+// rotated files that exceeded their maximum age.
+func (w *rotatingWriter) cleanup(rotated string) {
+	if w.compress {
+		if err := compressFile(rotated); err != nil {
+			Root().Warn("Failed to compress rotated log file", "file", rotated, "err", err)
+		}
+	}
+	if w.maxAge == 0 {
+		return
+	}
+	// List the directory instead of globbing, the path may contain metacharacters
+	dir, prefix := filepath.Dir(w.path), filepath.Base(w.path)+"."
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, info := range files {
+		if info.IsDir() || !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+		// Skip any file not created by us (e.g. compression leftovers)
+		suffix := strings.TrimSuffix(strings.TrimPrefix(info.Name(), prefix), ".gz")
+		if _, err := time.Parse(rotatedTimeFormat, suffix); err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) <= w.maxAge {
+			continue
+		}
+		os.Remove(filepath.Join(dir, info.Name()))
+	}
+}
+
+// fileExists reports whecer a file exists at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+// compressFile gzips the given file into a new one with a .gz extension and
+// removes the original.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatedFiles returns the names of the rotated log files next to path.
+func rotatedFiles(t *testing.T, path string) []string {
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to list log directory: %v", err)
+	}
+	var rotated []string
+	for _, info := range files {
+		if strings.HasPrefix(info.Name(), filepath.Base(path)+".") {
+			rotated = append(rotated, info.Name())
+		}
+	}
+	sort.Strings(rotated)
+	return rotated
+}
+
+// Tests that the active log file is rotated out once a write would push it over
+// the size limit, without losing or splitting any writes.
+func TestRotatingWriterSizeLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gecchain.log")
+	w, err := newRotatingWriter(path, 10, 0, false)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	rotated := rotatedFiles(t, path)
+	if len(rotated) != 1 {
+		t.Fatalf("rotated file count mismatch: have %v, want 1", rotated)
+	}
+	if blob, _ := ioutil.ReadFile(filepath.Join(dir, rotated[0])); string(blob) != "aaaa\nbbbb\n" {
+		t.Errorf("rotated content mismatch: have %q, want %q", blob, "aaaa\nbbbb\n")
+	}
+	if blob, _ := ioutil.ReadFile(path); string(blob) != "cccc\n" {
+		t.Errorf("active content mismatch: have %q, want %q", blob, "cccc\n")
+	}
+	// Writes larger than the limit must still be accepted into a fresh file
+	if _, err := w.Write(bytes.Repeat([]byte("x"), 20)); err != nil {
+		t.Fatalf("failed to write oversized record: %v", err)
+	}
+	if rotated := rotatedFiles(t, path); len(rotated) != 2 {
+		t.Fatalf("rotated file count mismatch: have %v, want 2", rotated)
+	}
+	if info, _ := os.Stat(path); info.Size() != 20 {
+		t.Errorf("active size mismatch: have %d, want 20", info.Size())
+	}
+}
+
+// Tests that rotated log files are gzip compressed when requested.
+func TestRotatingWriterCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gecchain.log")
+	w, err := newRotatingWriter(path, 0, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("compressed\n")); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	// Rotate manually and post-process synchronously
+	w.lock.Lock()
+	if err := w.file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rotated := path + "." + time.Now().Format(rotatedTimeFormat)
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.open(); err != nil {
+		t.Fatal(err)
+	}
+	w.lock.Unlock()
+	w.cleanup(rotated)
+
+	if fileExists(rotated) {
+		t.Errorf("uncompressed rotated file left behind")
+	}
+	f, err := os.Open(rotated + ".gz")
+	if err != nil {
+		t.Fatalf("compressed file missing: %v", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("invalid gzip file: %v", err)
+	}
+	if blob, err := ioutil.ReadAll(gz); err != nil || string(blob) != "compressed\n" {
+		t.Errorf("compressed content mismatch: have %q (err %v), want %q", blob, err, "compressed\n")
+	}
+}
+
+// Tests that rotated files older than the maximum age are deleted, leaving
+// fresh ones and unrelated files alone, even if the log path contains glob
+// metacharacters.
+func TestRotatingWriterMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log-rotate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "[node]*.log")
+	w, err := newRotatingWriter(path, 0, time.Hour, false)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	var (
+		now   = time.Now()
+		old   = path + "." + now.Add(-3*time.Hour).Format(rotatedTimeFormat) + ".gz"
+		fresh = path + "." + now.Add(-time.Minute).Format(rotatedTimeFormat)
+		other = path + ".backup"
+	)
+	for _, file := range []string{old, fresh, other} {
+		if err := ioutil.WriteFile(file, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := now.Add(-2 * time.Hour)
+	for _, file := range []string{old, other} {
+		if err := os.Chtimes(file, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.cleanup(fresh)
+
+	if fileExists(old) {
+		t.Errorf("expired rotated file not removed")
+	}
+	if !fileExists(fresh) {
+		t.Errorf("fresh rotated file removed")
+	}
+	if !fileExists(other) {
+		t.Errorf("unrelated file removed")
+	}
+	if !fileExists(path) {
+		t.Errorf("active log file removed")
+	}
+}
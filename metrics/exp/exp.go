@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/metrics"
+	"github.com/ecchain/go-ecchain/metrics/prometheus"
 )
 
 type exp struct {
@@ -44,6 +46,22 @@ func Exp(r metrics.Registry) {
 	http.Handle("/debug/metrics", h)
 }
 
+// Setup starts a dedicated metrics server at the given address, serving the
+// expvar metrics on "/debug/metrics" and the Prometheus exposition format on
+// "/debug/metrics/prometheus".
+func Setup(address string) {
+	m := http.NewServeMux()
+	m.Handle("/debug/metrics", ExpHandler(metrics.DefaultRegistry))
+	m.Handle("/debug/metrics/prometheus", prometheus.Handler(metrics.DefaultRegistry))
+
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/debug/metrics", address))
+	go func() {
+		if err := http.ListenAndServe(address, m); err != nil {
+			log.Error("Failure in running metrics server", "err", err)
+		}
+	}()
+}
+
 // ExpHandler will return an expvar powered metrics handler.
 func ExpHandler(r metrics.Registry) http.Handler {
 	e := exp{sync.Mutex{}, r}
@@ -0,0 +1,146 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ecchain/go-ecchain/metrics"
+)
+
+var (
+	typeGaugeTpl           = "# TYPE %s gauge\n"
+	typeCounterTpl         = "# TYPE %s counter\n"
+	typeSummaryTpl         = "# TYPE %s summary\n"
+	keyValueTpl            = "%s %v\n\n"
+	keyQuantileTagValueTpl = "%s{quantile=\"%s\"} %v\n"
+)
+
+// quantiles are the summary quantiles reported for every histogram and timer.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// collector is a collection of byte buffers that aggregate Prometheus reports
+// for different metric types.
+type collector struct {
+	buff *bytes.Buffer
+}
+
+// newCollector creates a new Prometheus metric aggregator.
+func newCollector() *collector {
+	return &collector{
+		buff: &bytes.Buffer{},
+	}
+}
+
+// addCounter reports a counter. Since counters in the metrics package may be
+// decremented too, they are exposed as Prometheus gauges.
+func (c *collector) addCounter(name string, m metrics.Counter) {
+	c.writeGauge(name, m.Count())
+}
+
+// addGauge reports an integer gauge.
+func (c *collector) addGauge(name string, m metrics.Gauge) {
+	c.writeGauge(name, m.Value())
+}
+
+// addGaugeFloat64 reports a floating point gauge.
+func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
+	c.writeGauge(name, m.Value())
+}
+
+// addMeter reports the monotonically increasing number of events marked on a
+// meter as a Prometheus counter, leaving rate calculation to the server.
+func (c *collector) addMeter(name string, m metrics.Meter) {
+	c.writeCounter(name, m.Count())
+}
+
+// addHistogram reports the distribution of a histogram as a Prometheus summary.
+func (c *collector) addHistogram(name string, m metrics.Histogram) {
+	h := m.Snapshot()
+	c.writeSummary(name, h.Count(), h.Sum(), quantiles, h.Percentiles(quantiles))
+}
+
+// addTimer reports the distribution of a timer as a Prometheus summary.
+func (c *collector) addTimer(name string, m metrics.Timer) {
+	t := m.Snapshot()
+	c.writeSummary(name, t.Count(), t.Sum(), quantiles, t.Percentiles(quantiles))
+}
+
+// addResettingTimer reports the values gathered by a resetting timer since the
+// last flush as a Prometheus summary. Empty timers are skipped.
+func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
+	t := m.Snapshot()
+
+	values := t.Values()
+	if len(values) == 0 {
+		return
+	}
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	// Resetting timers expect percentiles on a 0-100 scale instead of 0-1
+	pcts := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		pcts[i] = q * 100
+	}
+	ps := t.Percentiles(pcts)
+
+	fs := make([]float64, len(ps))
+	for i, p := range ps {
+		fs[i] = float64(p)
+	}
+	c.writeSummary(name, int64(len(values)), sum, quantiles, fs)
+}
+
+func (c *collector) writeGauge(name string, value interface{}) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
+	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+}
+
+func (c *collector) writeCounter(name string, value interface{}) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
+	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+}
+
+func (c *collector) writeSummary(name string, count, sum int64, qs []float64, values []float64) {
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, name))
+	for i, q := range qs {
+		c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, strconv.FormatFloat(q, 'f', -1, 64), values[i]))
+	}
+	c.buff.WriteString(fmt.Sprintf("%s_sum %v\n", name, sum))
+	c.buff.WriteString(fmt.Sprintf("%s_count %v\n\n", name, count))
+}
+
+// mutateKey converts a metrics registry name into a valid Prometheus metric
+// name by replacing all disallowed characters with underscores.
+func mutateKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,280 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/log"
+)
+
+// TransactionSource is a set of transactions offered for inclusion into a block
+// one by one, in the order a block builder chose. It is satisfied by
+// types.TransactionsByPriceAndNonce.
+type TransactionSource interface {
+	// Peek returns the next transaction to attempt, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same
+	// account, after it was included or skipped.
+	Shift()
+
+	// Pop drops the current transaction along with all later ones of the same
+	// account, after it was found not to fit.
+	Pop()
+}
+
+// SimulateFn executes the transactions of a source on a copy of the pending
+// block, returning the fees the miner would collect.
+type SimulateFn func(txs TransactionSource) *big.Int
+
+// BlockBuilder decides which pending transactions are attempted for inclusion
+// into a block and in what order. Transactions of the same account must always
+// be offered in nonce order.
+type BlockBuilder interface {
+	// Order returns the pending transactions in the order they should be attempted.
+	// The simulate callback may be used to evaluate candidate orderings.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, simulate SimulateFn) TransactionSource
+}
+
+// txObserver is implemented by block builders interested in the arrival of new
+// transactions.
+type txObserver interface {
+	observe(tx *types.Transaction)
+}
+
+// NewBlockBuilder creates the block builder with the given name:
+//
+//   - "price":  transactions ordered by gas price (default)
+//   - "fifo":   transactions ordered by arrival, for fair private networks
+//   - "profit": simulates several orderings, picking the most profitable one
+func NewBlockBuilder(name string) (BlockBuilder, error) {
+	switch name {
+	case "", "price":
+		return new(PriceBuilder), nil
+	case "fifo":
+		return NewFIFOBuilder(), nil
+	case "profit":
+		return NewProfitBuilder(), nil
+	}
+	return nil, fmt.Errorf("unknown block builder %q", name)
+}
+
+// PriceBuilder orders transactions by gas price, the most paying first.
+type PriceBuilder struct{}
+
+// Order implements BlockBuilder.
+func (b *PriceBuilder) Order(signer types.Signer, pending map[common.Address]types.Transactions, simulate SimulateFn) TransactionSource {
+	return types.NewTransactionsByPriceAndNonce(signer, pending)
+}
+
+// FIFOBuilder orders transactions by the time they arrived at the node, so no
+// sender can jump the queue by bidding a higher gas price.
+type FIFOBuilder struct {
+	arrivals map[common.Hash]uint64 // Arrival sequence numbers of the known transactions
+	next     uint64                 // Sequence number of the next arriving transaction
+	lock     sync.Mutex
+}
+
+// NewFIFOBuilder creates an arrival ordering block builder.
+func NewFIFOBuilder() *FIFOBuilder {
+	return &FIFOBuilder{arrivals: make(map[common.Hash]uint64)}
+}
+
+// observe records the arrival of a new transaction.
+func (b *FIFOBuilder) observe(tx *types.Transaction) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.arrivals[tx.Hash()]; !ok {
+		b.arrivals[tx.Hash()] = b.next
+		b.next++
+	}
+}
+
+// Order implements BlockBuilder. Transactions that arrived before the builder
+// was created are queued ahead of the observed ones, ordered by hash.
+func (b *FIFOBuilder) Order(signer types.Signer, pending map[common.Address]types.Transactions, simulate SimulateFn) TransactionSource {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Forget about transactions no longer pending and catch up on unknown ones
+	arrivals := make(map[common.Hash]uint64)
+	var unknown []common.Hash
+	for _, txs := range pending {
+		for _, tx := range txs {
+			if seq, ok := b.arrivals[tx.Hash()]; ok {
+				arrivals[tx.Hash()] = seq
+			} else {
+				unknown = append(unknown, tx.Hash())
+			}
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return bytes.Compare(unknown[i][:], unknown[j][:]) < 0 })
+	for _, hash := range unknown {
+		arrivals[hash] = b.next
+		b.next++
+	}
+	b.arrivals = arrivals
+
+	return newOrderedTransactions(signer, pending, func(a, b *types.Transaction) bool {
+		return arrivals[a.Hash()] < arrivals[b.Hash()]
+	})
+}
+
+// ProfitBuilder simulates a number of candidate orderings on the pending block,
+// picking the one earning the miner the most fees.
+type ProfitBuilder struct {
+	candidates []BlockBuilder
+}
+
+// NewProfitBuilder creates a block builder evaluating the given candidate
+// builders. If none are given, the gas price ordering and an ordering by the
+// total fee of each transaction are compared.
+func NewProfitBuilder(candidates ...BlockBuilder) *ProfitBuilder {
+	if len(candidates) == 0 {
+		candidates = []BlockBuilder{new(PriceBuilder), new(feeBuilder)}
+	}
+	return &ProfitBuilder{candidates: candidates}
+}
+
+// observe forwards the arrival of a transaction to the interested candidates.
+func (b *ProfitBuilder) observe(tx *types.Transaction) {
+	for _, candidate := range b.candidates {
+		if observer, ok := candidate.(txObserver); ok {
+			observer.observe(tx)
+		}
+	}
+}
+
+// Order implements BlockBuilder.
+func (b *ProfitBuilder) Order(signer types.Signer, pending map[common.Address]types.Transactions, simulate SimulateFn) TransactionSource {
+	var (
+		best     = 0
+		bestFees *big.Int
+	)
+	for i, candidate := range b.candidates {
+		fees := simulate(candidate.Order(signer, copyPending(pending), simulate))
+		if bestFees == nil || fees.Cmp(bestFees) > 0 {
+			best, bestFees = i, fees
+		}
+	}
+	log.Trace("Picked most profitable transaction ordering", "candidate", best, "fees", bestFees)
+	return b.candidates[best].Order(signer, pending, simulate)
+}
+
+// copyPending creates a shallow copy of a set of pending transactions, as the
+// transaction sources take ownership of the lists given to them.
+func copyPending(pending map[common.Address]types.Transactions) map[common.Address]types.Transactions {
+	cpy := make(map[common.Address]types.Transactions, len(pending))
+	for from, txs := range pending {
+		cpy[from] = txs
+	}
+	return cpy
+}
+
+// feeBuilder orders transactions by the maximum total fee they may pay (gas
+// price times gas limit), favouring large transactions a price ordering might
+// leave no room for.
+type feeBuilder struct{}
+
+// Order implements BlockBuilder.
+func (b *feeBuilder) Order(signer types.Signer, pending map[common.Address]types.Transactions, simulate SimulateFn) TransactionSource {
+	return newOrderedTransactions(signer, pending, func(a, b *types.Transaction) bool {
+		return maxFee(a).Cmp(maxFee(b)) > 0
+	})
+}
+
+// maxFee returns the fee a transaction pays if it uses up all its gas.
+func maxFee(tx *types.Transaction) *big.Int {
+	return new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+}
+
+// txHeads is a heap of the next transaction of each account.
+type txHeads struct {
+	txs  []*types.Transaction
+	less func(a, b *types.Transaction) bool
+}
+
+func (h *txHeads) Len() int           { return len(h.txs) }
+func (h *txHeads) Less(i, j int) bool { return h.less(h.txs[i], h.txs[j]) }
+func (h *txHeads) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+func (h *txHeads) Push(x interface{}) { h.txs = append(h.txs, x.(*types.Transaction)) }
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
+// orderedTransactions is a TransactionSource offering the account heads in the
+// order of a custom comparator, while keeping the nonce order of each account.
+type orderedTransactions struct {
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *txHeads                              // Next transaction for each unique account
+	signer types.Signer                          // Signer for the set of transactions
+}
+
+// newOrderedTransactions creates a transaction source ordering the accounts by
+// their next transaction using the given comparator. The pending lists must be
+// sorted by nonce; they are copied, not modified.
+func newOrderedTransactions(signer types.Signer, pending map[common.Address]types.Transactions, less func(a, b *types.Transaction) bool) *orderedTransactions {
+	txs := make(map[common.Address]types.Transactions, len(pending))
+	heads := &txHeads{less: less}
+	for from, accTxs := range pending {
+		if len(accTxs) == 0 {
+			continue
+		}
+		heads.txs = append(heads.txs, accTxs[0])
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(heads)
+
+	return &orderedTransactions{txs: txs, heads: heads, signer: signer}
+}
+
+// Peek implements TransactionSource.
+func (t *orderedTransactions) Peek() *types.Transaction {
+	if t.heads.Len() == 0 {
+		return nil
+	}
+	return t.heads.txs[0]
+}
+
+// Shift implements TransactionSource.
+func (t *orderedTransactions) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads.txs[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
+	} else {
+		heap.Pop(t.heads)
+	}
+}
+
+// Pop implements TransactionSource.
+func (t *orderedTransactions) Pop() {
+	heap.Pop(t.heads)
+}
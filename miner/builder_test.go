@@ -0,0 +1,166 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/core/state"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/event"
+	"github.com/ecchain/go-ecchain/params"
+)
+
+var builderTestSigner = types.HomesteadSigner{}
+
+// newBuilderTestTx creates a signed value transfer.
+func newBuilderTestTx(key *ecdsa.PrivateKey, nonce uint64, gas uint64, price int64) *types.Transaction {
+	tx := types.NewTransaction(nonce, common.Address{0xff}, big.NewInt(1), gas, big.NewInt(price), nil)
+	tx, _ = types.SignTx(tx, builderTestSigner, key)
+	return tx
+}
+
+// newBuilderTestWork creates an empty block of the given gas limit, funding the
+// given accounts.
+func newBuilderTestWork(gasLimit uint64, keys ...*ecdsa.PrivateKey) *Work {
+	db, _ := ecdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+	for _, key := range keys {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(params.ecer))
+	}
+	return &Work{
+		config: params.TestChainConfig,
+		signer: builderTestSigner,
+		state:  statedb,
+		fees:   new(big.Int),
+		header: &types.Header{
+			Number:     big.NewInt(1),
+			GasLimit:   gasLimit,
+			Difficulty: big.NewInt(1),
+			Time:       big.NewInt(time.Now().Unix()),
+		},
+	}
+}
+
+// newBuilderTestWorker creates a bare worker able to fill blocks.
+func newBuilderTestWorker(builder BlockBuilder) *worker {
+	return &worker{config: params.TestChainConfig, mux: new(event.TypeMux), builder: builder}
+}
+
+// Tests that the FIFO builder offers transactions in arrival order, regardless
+// of their price, but still in nonce order per account.
+func TestFIFOBuilder(t *testing.T) {
+	early, _ := crypto.GenerateKey()
+	late, _ := crypto.GenerateKey()
+
+	txs := []*types.Transaction{
+		newBuilderTestTx(early, 0, params.TxGas, 1),
+		newBuilderTestTx(late, 0, params.TxGas, 100),
+		newBuilderTestTx(early, 1, params.TxGas, 1),
+	}
+	builder := NewFIFOBuilder()
+	for _, tx := range txs {
+		builder.observe(tx)
+	}
+	pending := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(early.PublicKey): {txs[0], txs[2]},
+		crypto.PubkeyToAddress(late.PublicKey):  {txs[1]},
+	}
+	source := builder.Order(builderTestSigner, pending, nil)
+	for i, want := range txs {
+		tx := source.Peek()
+		if tx == nil || tx.Hash() != want.Hash() {
+			t.Fatalf("transaction %d: ordering mismatch", i)
+		}
+		source.Shift()
+	}
+	if tx := source.Peek(); tx != nil {
+		t.Fatalf("unexpected extra transaction: %x", tx.Hash())
+	}
+}
+
+// Tests that gas reserved for local senders is kept free from the transactions
+// of other senders, even if they pay more.
+func TestGasReservation(t *testing.T) {
+	remote, _ := crypto.GenerateKey()
+	local, _ := crypto.GenerateKey()
+
+	pending := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(remote.PublicKey): {
+			newBuilderTestTx(remote, 0, params.TxGas, 100),
+			newBuilderTestTx(remote, 1, params.TxGas, 100),
+			newBuilderTestTx(remote, 2, params.TxGas, 100),
+		},
+		crypto.PubkeyToAddress(local.PublicKey): {
+			newBuilderTestTx(local, 0, params.TxGas, 1),
+		},
+	}
+	// Without a reservation the remote sender fills the block
+	work := newBuilderTestWork(3*params.TxGas, remote, local)
+	newBuilderTestWorker(new(PriceBuilder)).commitPending(work, copyPending(pending), nil)
+
+	for i, tx := range work.txs {
+		if from, _ := types.Sender(builderTestSigner, tx); from != crypto.PubkeyToAddress(remote.PublicKey) {
+			t.Errorf("unreserved transaction %d: sender mismatch: have %x", i, from)
+		}
+	}
+	// With a reservation the local sender gets its share
+	work = newBuilderTestWork(3*params.TxGas, remote, local)
+	worker := newBuilderTestWorker(new(PriceBuilder))
+	worker.setGasReservation(params.TxGas, []common.Address{crypto.PubkeyToAddress(local.PublicKey)})
+	worker.commitPending(work, copyPending(pending), worker.mux)
+
+	if len(work.txs) != 3 {
+		t.Fatalf("included transaction count mismatch: have %d, want 3", len(work.txs))
+	}
+	if from, _ := types.Sender(builderTestSigner, work.txs[2]); from != crypto.PubkeyToAddress(local.PublicKey) {
+		t.Errorf("reserved gas used by %x", from)
+	}
+}
+
+// Tests that the profit builder picks the ordering that fits more fees into the
+// block than the plain price ordering.
+func TestProfitBuilder(t *testing.T) {
+	cheap, _ := crypto.GenerateKey()
+	large, _ := crypto.GenerateKey()
+
+	// The pricier transaction leaves no room for the large one if included first
+	pending := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(cheap.PublicKey): {newBuilderTestTx(cheap, 0, params.TxGas, 10)},
+		crypto.PubkeyToAddress(large.PublicKey): {newBuilderTestTx(large, 0, 30000, 9)},
+	}
+	for _, test := range []struct {
+		builder BlockBuilder
+		want    int
+	}{
+		{new(PriceBuilder), 1},
+		{NewProfitBuilder(), 2},
+	} {
+		work := newBuilderTestWork(50000, cheap, large)
+		newBuilderTestWorker(test.builder).commitPending(work, copyPending(pending), nil)
+
+		if len(work.txs) != test.want {
+			t.Errorf("%T: included transaction count mismatch: have %d, want %d", test.builder, len(work.txs), test.want)
+		}
+	}
+}
@@ -20,6 +20,7 @@ package miner
 import (
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/ecchain/go-ecchain/accounts"
 	"github.com/ecchain/go-ecchain/common"
@@ -163,6 +164,37 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetRecommitInterval sets the interval at which the pending block is re-filled
+// with newly arrived transactions while mining. Zero disables recommits.
+func (self *Miner) SetRecommitInterval(interval time.Duration) {
+	self.worker.setRecommitInterval(interval)
+}
+
+// SetGasLimit sets the gas limit the mined blocks are raised towards, as well as
+// the one they are lowered towards (zero leaves them uncapped). The gas limit of
+// each block may only move by a fraction of its parent's, so the targets are
+// approached gradually.
+func (self *Miner) SetGasLimit(target, ceil uint64) {
+	self.worker.setGasLimit(target, ceil)
+}
+
+// SetBuilder sets the strategy used to order pending transactions into blocks.
+func (self *Miner) SetBuilder(builder BlockBuilder) {
+	self.worker.setBuilder(builder)
+}
+
+// SetGasReservation reserves the given amount of gas in every block for the
+// transactions of the given local senders.
+func (self *Miner) SetGasReservation(gas uint64, senders []common.Address) {
+	self.worker.setGasReservation(gas, senders)
+}
+
+// Refresh discards the pending block and assembles a new one on top of the
+// current chain head, e.g. after the consensus engine changed the header rules.
+func (self *Miner) Refresh() {
+	self.worker.commitNewWork()
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -27,6 +27,7 @@ import (
 	"github.com/ecchain/go-ecchain/consensus"
 	"github.com/ecchain/go-ecchain/consensus/ethash"
 	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/event"
 	"github.com/ecchain/go-ecchain/log"
 )
 
@@ -50,6 +51,8 @@ type RemoteAgent struct {
 	hashrateMu sync.RWMutex
 	hashrate   map[common.Hash]hashrate
 
+	workFeed event.Feed // Feed announcing every new work package to push based miners
+
 	running int32 // running indicates whecer the agent is active. Call atomically
 }
 
@@ -115,22 +118,52 @@ func (a *RemoteAgent) GetWork() ([3]string, error) {
 	if a.currentWork != nil {
 		block := a.currentWork.Block
 
-		res[0] = block.HashNoNonce().Hex()
-		seedHash := ethash.SeedHash(block.NumberU64())
-		res[1] = common.BytesToHash(seedHash).Hex()
-		// Calculate the "target" to be returned to the external miner
-		n := big.NewInt(1)
-		n.Lsh(n, 255)
-		n.Div(n, block.Difficulty())
-		n.Lsh(n, 1)
-		res[2] = common.BytesToHash(n.Bytes()).Hex()
-
 		a.work[block.HashNoNonce()] = a.currentWork
-		return res, nil
+		return workPackage(block, block.Difficulty()), nil
 	}
 	return res, errors.New("No work available yet, don't panic.")
 }
 
+// SubscribeWork registers a subscription for the work packages of every new
+// block the agent is asked to seal, in the same format GetWork returns.
+func (a *RemoteAgent) SubscribeWork(ch chan<- [3]string) event.Subscription {
+	return a.workFeed.Subscribe(ch)
+}
+
+// pendingBlock returns the block of a work package still awaiting a solution.
+func (a *RemoteAgent) pendingBlock(hash common.Hash) *types.Block {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if work := a.work[hash]; work != nil {
+		return work.Block
+	}
+	return nil
+}
+
+// workPackage assembles the work package of a block for external miners with
+// the boundary condition of the given difficulty.
+func workPackage(block *types.Block, difficulty *big.Int) [3]string {
+	var res [3]string
+
+	res[0] = block.HashNoNonce().Hex()
+	seedHash := ethash.SeedHash(block.NumberU64())
+	res[1] = common.BytesToHash(seedHash).Hex()
+	res[2] = common.BytesToHash(difficultyTarget(difficulty).Bytes()).Hex()
+
+	return res
+}
+
+// difficultyTarget calculates the "target" to be returned to the external miner,
+// which is 2^256 / difficulty.
+func difficultyTarget(difficulty *big.Int) *big.Int {
+	n := big.NewInt(1)
+	n.Lsh(n, 255)
+	n.Div(n, difficulty)
+	n.Lsh(n, 1)
+	return n
+}
+
 // SubmitWork tries to inject a pow solution into the remote agent, returning
 // whecer the solution was accepted or not (not can be both a bad pow as well as
 // any other error, like no work pending).
@@ -179,7 +212,10 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 		case work := <-workCh:
 			a.mu.Lock()
 			a.currentWork = work
+			a.work[work.Block.HashNoNonce()] = work
 			a.mu.Unlock()
+
+			a.workFeed.Send(workPackage(work.Block, work.Block.Difficulty()))
 		case <-ticker.C:
 			// cleanup
 			a.mu.Lock()
@@ -0,0 +1,670 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/consensus"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/log"
+)
+
+const (
+	stratumVersion      = "EthereumStratum/1.0.0" // Protocol version announced to EthereumStratum miners
+	stratumMaxLine      = 4096                    // Maximum length of a request line
+	stratumReadTimeout  = 10 * time.Minute        // Time after which silent miners are dropped
+	stratumWriteTimeout = 10 * time.Second        // Time allowed for a miner to accept a message
+
+	hashrateWindow    = 10 * time.Minute // Time window over which share based hashrates are averaged
+	hashrateRefresh   = 5 * time.Second  // Interval of feeding the worker hashrates to the remote agent
+	hashrateReportTTL = time.Minute      // Time a hashrate reported by the miner itself is preferred
+)
+
+// stratumBaseDifficulty is the share difficulty corresponding to a difficulty
+// of 1 in EthereumStratum/1.0 (2^32).
+var stratumBaseDifficulty = new(big.Float).SetInt(new(big.Int).Lsh(common.Big1, 32))
+
+var (
+	errStratumNoEngine     = errors.New("stratum requires the ethash consensus engine")
+	errStratumRunning      = errors.New("stratum server already running")
+	errStratumUnauthorized = errors.New("unauthorized worker")
+	errStratumNoWork       = errors.New("no work available yet")
+	errStratumStaleShare   = errors.New("stale share")
+	errStratumDuplicate    = errors.New("duplicate share")
+	errStratumInvalidShare = errors.New("invalid share")
+	errStratumLowShare     = errors.New("low difficulty share")
+	errStratumParams       = errors.New("invalid parameters")
+	errStratumMethod       = errors.New("method not found")
+)
+
+// hashimotoEngine is a proof-of-work engine able to compute the PoW value of a
+// nonce, needed to verify shares below the block difficulty.
+type hashimotoEngine interface {
+	Hashimoto(number uint64, hash []byte, nonce uint64) ([]byte, []byte)
+}
+
+// stratumProtocol is the dialect of the stratum protocol spoken by a miner.
+type stratumProtocol int
+
+const (
+	stratumUnknown  stratumProtocol = iota // Miner didn't log in yet
+	stratumProxy                           // Classic eth-proxy dialect (eth_getWork over TCP)
+	stratumNiceHash                        // EthereumStratum/1.0 dialect (mining.notify)
+)
+
+// stratumRequest is a request sent by a miner.
+type stratumRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []string        `json:"params"`
+	Worker string          `json:"worker"`
+}
+
+// stratumResponse is a reply to a miner request, or a work push in the eth-proxy
+// dialect.
+type stratumResponse struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc,omitempty"`
+	Result  interface{}     `json:"result"`
+	Error   interface{}     `json:"error"`
+}
+
+// stratumNotification is a server initiated message in the EthereumStratum
+// dialect.
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// stratumShare is an accepted share of a worker.
+type stratumShare struct {
+	time       time.Time
+	difficulty *big.Int
+}
+
+// stratumWorker tracks the hashrate of a single mining rig.
+type stratumWorker struct {
+	id     common.Hash    // Identifier the hashrate is reported to the remote agent with
+	since  time.Time      // Time the worker first logged in
+	shares []stratumShare // Accepted shares within the hashrate window
+
+	reported   uint64    // Hashrate reported by the miner itself
+	reportedAt time.Time // Time of the last self reported hashrate
+}
+
+// hashrate estimates the hashrate of the worker. Recent self reported figures
+// are preferred, otherwise the rate is derived from the accepted shares, each
+// of which took the share difficulty number of hashes to find on average.
+func (w *stratumWorker) hashrate(now time.Time) uint64 {
+	if now.Sub(w.reportedAt) < hashrateReportTTL {
+		return w.reported
+	}
+	for len(w.shares) > 0 && now.Sub(w.shares[0].time) > hashrateWindow {
+		w.shares = w.shares[1:]
+	}
+	hashes := new(big.Int)
+	for _, share := range w.shares {
+		hashes.Add(hashes, share.difficulty)
+	}
+	elapsed := now.Sub(w.since)
+	if elapsed > hashrateWindow {
+		elapsed = hashrateWindow
+	}
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return hashes.Div(hashes, big.NewInt(int64(elapsed/time.Second))).Uint64()
+}
+
+// StratumServer is a TCP server pushing the work of the remote agent to mining
+// rigs over the stratum protocol, accepting their share submissions. Both the
+// EthereumStratum/1.0 and the classic eth-proxy dialects are supported.
+type StratumServer struct {
+	agent      *RemoteAgent
+	engine     hashimotoEngine
+	difficulty *big.Int // Share difficulty, nil to only accept full solutions
+
+	listener net.Listener
+	sessions map[*stratumSession]struct{}
+	workers  map[common.Hash]*stratumWorker
+	shares   map[common.Hash]map[uint64]struct{} // Accepted nonces per work package to reject duplicates
+	current  [3]string                           // Latest work package of the remote agent
+	nonce    uint16                              // Extranonce of the next EthereumStratum session
+	lock     sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStratumServer creates a stratum server for the given remote agent. Shares
+// are accepted at the given difficulty, or at the block difficulty if it's nil
+// or higher.
+func NewStratumServer(agent *RemoteAgent, engine consensus.Engine, difficulty *big.Int) (*StratumServer, error) {
+	hasher, ok := engine.(hashimotoEngine)
+	if !ok {
+		return nil, errStratumNoEngine
+	}
+	if difficulty != nil && difficulty.Sign() <= 0 {
+		difficulty = nil
+	}
+	return &StratumServer{
+		agent:      agent,
+		engine:     hasher,
+		difficulty: difficulty,
+		sessions:   make(map[*stratumSession]struct{}),
+		workers:    make(map[common.Hash]*stratumWorker),
+		shares:     make(map[common.Hash]map[uint64]struct{}),
+	}, nil
+}
+
+// Start begins listening for miners on the given address.
+func (s *StratumServer) Start(addr string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return errStratumRunning
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.quit = make(chan struct{})
+
+	workCh := make(chan [3]string, 1)
+	sub := s.agent.SubscribeWork(workCh)
+
+	s.wg.Add(2)
+	go s.accept(listener)
+	go s.loop(workCh, sub.Err(), sub.Unsubscribe)
+
+	log.Info("Stratum server started", "addr", listener.Addr())
+	return nil
+}
+
+// Stop closes the listener and disconnects all miners.
+func (s *StratumServer) Stop() {
+	s.lock.Lock()
+	if s.listener == nil {
+		s.lock.Unlock()
+		return
+	}
+	close(s.quit)
+	s.listener.Close()
+	s.listener = nil
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	log.Info("Stratum server stopped")
+}
+
+// Addr returns the address the server is listening on, or nil if it's stopped.
+func (s *StratumServer) Addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// accept serves incoming miner connections until the listener is closed.
+func (s *StratumServer) accept(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		session := newStratumSession(s, conn)
+
+		s.lock.Lock()
+		select {
+		case <-s.quit:
+			s.lock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		s.sessions[session] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+
+		go session.serve()
+	}
+}
+
+// loop pushes every new work package to the miners and periodically feeds the
+// worker hashrates to the remote agent.
+func (s *StratumServer) loop(workCh chan [3]string, errc <-chan error, unsubscribe func()) {
+	defer s.wg.Done()
+	defer unsubscribe()
+
+	refresh := time.NewTicker(hashrateRefresh)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case work := <-workCh:
+			s.lock.Lock()
+			s.current = work
+			for session := range s.sessions {
+				if session.worker != nil {
+					session.queue(work)
+				}
+			}
+			s.lock.Unlock()
+
+		case <-refresh.C:
+			s.refresh()
+
+		case <-errc:
+			return
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// refresh feeds the hashrates of the active workers to the remote agent and
+// drops the bookkeeping of idle workers and expired work packages.
+func (s *StratumServer) refresh() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for id, worker := range s.workers {
+		rate := worker.hashrate(now)
+		if rate == 0 && now.Sub(worker.since) > hashrateWindow && now.Sub(worker.reportedAt) > hashrateWindow {
+			delete(s.workers, id)
+			continue
+		}
+		s.agent.SubmitHashrate(id, rate)
+	}
+	for hash := range s.shares {
+		if s.agent.pendingBlock(hash) == nil {
+			delete(s.shares, hash)
+		}
+	}
+}
+
+// shareDifficulty returns the difficulty shares of the given block must meet.
+func (s *StratumServer) shareDifficulty(block *types.Block) *big.Int {
+	if s.difficulty == nil || s.difficulty.Cmp(block.Difficulty()) > 0 {
+		return block.Difficulty()
+	}
+	return s.difficulty
+}
+
+// login registers the named worker, returning its hashrate tracker.
+func (s *StratumServer) login(name string) *stratumWorker {
+	id := crypto.Keccak256Hash([]byte(name))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	worker := s.workers[id]
+	if worker == nil {
+		worker = &stratumWorker{id: id, since: time.Now()}
+		s.workers[id] = worker
+	}
+	return worker
+}
+
+// reportHashrate records the hashrate a worker reported about itself.
+func (s *StratumServer) reportHashrate(worker *stratumWorker, rate uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	worker.reported, worker.reportedAt = rate, time.Now()
+}
+
+// submit verifies a share of the given worker, crediting it to the worker's
+// hashrate and passing it on to the remote agent if it solves the block too.
+func (s *StratumServer) submit(worker *stratumWorker, hash common.Hash, nonce uint64, mixDigest *common.Hash) error {
+	block := s.agent.pendingBlock(hash)
+	if block == nil {
+		return errStratumStaleShare
+	}
+	digest, result := s.engine.Hashimoto(block.NumberU64(), hash.Bytes(), nonce)
+	if mixDigest != nil && !bytes.Equal(mixDigest[:], digest) {
+		return errStratumInvalidShare
+	}
+	value := new(big.Int).SetBytes(result)
+	difficulty := s.shareDifficulty(block)
+	if value.Cmp(difficultyTarget(difficulty)) > 0 {
+		return errStratumLowShare
+	}
+	s.lock.Lock()
+	if _, ok := s.shares[hash][nonce]; ok {
+		s.lock.Unlock()
+		return errStratumDuplicate
+	}
+	if s.shares[hash] == nil {
+		s.shares[hash] = make(map[uint64]struct{})
+	}
+	s.shares[hash][nonce] = struct{}{}
+	worker.shares = append(worker.shares, stratumShare{time.Now(), difficulty})
+	s.lock.Unlock()
+
+	if value.Cmp(difficultyTarget(block.Difficulty())) <= 0 {
+		if s.agent.SubmitWork(types.EncodeNonce(nonce), common.BytesToHash(digest), hash) {
+			log.Info("Stratum share solved block", "number", block.Number(), "hash", hash)
+		}
+	}
+	return nil
+}
+
+// stratumSession is the connection of a single miner.
+type stratumSession struct {
+	server *StratumServer
+	conn   net.Conn
+
+	protocol   stratumProtocol
+	extranonce string         // Nonce prefix assigned to EthereumStratum miners (hex)
+	worker     *stratumWorker // Logged in worker, nil until authorized
+
+	work       chan [3]string // Latest work package waiting to be pushed
+	difficulty *big.Int       // Share difficulty last announced to the miner
+	encoder    *json.Encoder
+	writeLock  sync.Mutex
+	closed     chan struct{}
+}
+
+func newStratumSession(server *StratumServer, conn net.Conn) *stratumSession {
+	return &stratumSession{
+		server:  server,
+		conn:    conn,
+		work:    make(chan [3]string, 1),
+		encoder: json.NewEncoder(conn),
+		closed:  make(chan struct{}),
+	}
+}
+
+// serve processes the requests of the miner until the connection is closed.
+func (sess *stratumSession) serve() {
+	defer sess.server.wg.Done()
+	defer func() {
+		sess.server.lock.Lock()
+		delete(sess.server.sessions, sess)
+		sess.server.lock.Unlock()
+
+		close(sess.closed)
+		sess.conn.Close()
+	}()
+	go sess.pushLoop()
+
+	log.Debug("Stratum miner connected", "addr", sess.conn.RemoteAddr())
+	defer log.Debug("Stratum miner disconnected", "addr", sess.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(sess.conn)
+	scanner.Buffer(make([]byte, 0, stratumMaxLine), stratumMaxLine)
+	for {
+		sess.conn.SetReadDeadline(time.Now().Add(stratumReadTimeout))
+		if !scanner.Scan() {
+			return
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req stratumRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debug("Invalid stratum request", "addr", sess.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := sess.handle(&req)
+		if err := sess.reply(req.ID, result, err); err != nil {
+			return
+		}
+		// Freshly authorized miners need a job to work on
+		if err == nil && req.Method == "mining.authorize" {
+			sess.server.lock.Lock()
+			current := sess.server.current
+			sess.server.lock.Unlock()
+
+			if current[0] != "" {
+				sess.push(current)
+			}
+		}
+	}
+}
+
+// handle executes a single request of the miner.
+func (sess *stratumSession) handle(req *stratumRequest) (interface{}, error) {
+	switch req.Method {
+	case "mining.subscribe":
+		if sess.protocol != stratumUnknown {
+			return nil, errStratumParams
+		}
+		sess.protocol = stratumNiceHash
+
+		sess.server.lock.Lock()
+		sess.extranonce = hex.EncodeToString([]byte{byte(sess.server.nonce >> 8), byte(sess.server.nonce)})
+		sess.server.nonce++
+		sess.server.lock.Unlock()
+
+		return []interface{}{[]string{"mining.notify", sess.extranonce, stratumVersion}, sess.extranonce}, nil
+
+	case "mining.extranonce.subscribe":
+		return true, nil
+
+	case "mining.authorize":
+		if sess.protocol != stratumNiceHash || len(req.Params) < 1 {
+			return nil, errStratumParams
+		}
+		sess.authorize(req.Params[0], "")
+		return true, nil
+
+	case "mining.submit":
+		if sess.worker == nil {
+			return nil, errStratumUnauthorized
+		}
+		if len(req.Params) < 3 {
+			return nil, errStratumParams
+		}
+		nonce, err := hex.DecodeString(sess.extranonce + strings.TrimPrefix(req.Params[2], "0x"))
+		if err != nil || len(nonce) != 8 {
+			return nil, errStratumParams
+		}
+		hash := common.HexToHash(req.Params[1])
+		if err := sess.server.submit(sess.worker, hash, binary.BigEndian.Uint64(nonce), nil); err != nil {
+			return nil, err
+		}
+		return true, nil
+
+	case "eth_submitLogin":
+		if sess.protocol == stratumNiceHash || len(req.Params) < 1 {
+			return nil, errStratumParams
+		}
+		if sess.protocol == stratumUnknown {
+			sess.protocol = stratumProxy
+		}
+		sess.authorize(req.Params[0], req.Worker)
+		return true, nil
+
+	case "eth_getWork":
+		if sess.worker == nil {
+			return nil, errStratumUnauthorized
+		}
+		sess.server.lock.Lock()
+		current := sess.server.current
+		sess.server.lock.Unlock()
+
+		if block := sess.server.agent.pendingBlock(common.HexToHash(current[0])); block != nil {
+			return workPackage(block, sess.server.shareDifficulty(block)), nil
+		}
+		return nil, errStratumNoWork
+
+	case "eth_submitWork":
+		if sess.worker == nil {
+			return nil, errStratumUnauthorized
+		}
+		if len(req.Params) < 3 {
+			return nil, errStratumParams
+		}
+		nonce, err := hexutil.DecodeUint64(req.Params[0])
+		if err != nil {
+			return nil, errStratumParams
+		}
+		hash, mixDigest := common.HexToHash(req.Params[1]), common.HexToHash(req.Params[2])
+		if err := sess.server.submit(sess.worker, hash, nonce, &mixDigest); err != nil {
+			log.Debug("Stratum share rejected", "worker", sess.worker.id, "err", err)
+			return false, nil
+		}
+		return true, nil
+
+	case "eth_submitHashrate":
+		if sess.worker == nil {
+			return nil, errStratumUnauthorized
+		}
+		if len(req.Params) < 1 {
+			return nil, errStratumParams
+		}
+		rate, err := hexutil.DecodeUint64(req.Params[0])
+		if err != nil {
+			return nil, errStratumParams
+		}
+		sess.server.reportHashrate(sess.worker, rate)
+		return true, nil
+	}
+	return nil, errStratumMethod
+}
+
+// authorize logs the miner in. Worker names are either given separately or
+// appended to the login with a dot. Repeated logins keep the first worker.
+func (sess *stratumSession) authorize(login, name string) {
+	if sess.worker != nil {
+		return
+	}
+	if name != "" {
+		login = login + "." + name
+	}
+	worker := sess.server.login(login)
+
+	sess.server.lock.Lock()
+	sess.worker = worker
+	sess.server.lock.Unlock()
+
+	log.Debug("Stratum worker authorized", "addr", sess.conn.RemoteAddr(), "login", login, "id", worker.id)
+}
+
+// reply sends the result of a request to the miner.
+func (sess *stratumSession) reply(id json.RawMessage, result interface{}, err error) error {
+	res := &stratumResponse{ID: id, Result: result}
+	if sess.protocol == stratumProxy {
+		res.Version = "2.0"
+	}
+	if err != nil {
+		if sess.protocol == stratumNiceHash {
+			res.Error = []interface{}{20, err.Error(), nil}
+		} else {
+			res.Error = map[string]interface{}{"code": -1, "message": err.Error()}
+		}
+	}
+	sess.writeLock.Lock()
+	defer sess.writeLock.Unlock()
+
+	return sess.write(res)
+}
+
+// write sends a message to the miner, dropping the connection if it's not
+// accepted in time. The write lock must be held.
+func (sess *stratumSession) write(msg interface{}) error {
+	sess.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	if err := sess.encoder.Encode(msg); err != nil {
+		sess.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// queue schedules a work package to be pushed to the miner, replacing any older
+// one not yet sent.
+func (sess *stratumSession) queue(work [3]string) {
+	select {
+	case <-sess.work:
+	default:
+	}
+	select {
+	case sess.work <- work:
+	default:
+	}
+}
+
+// pushLoop pushes the queued work packages until the session is closed, so a
+// slow miner doesn't delay the others.
+func (sess *stratumSession) pushLoop() {
+	for {
+		select {
+		case work := <-sess.work:
+			sess.push(work)
+		case <-sess.closed:
+			return
+		}
+	}
+}
+
+// push sends a work package to the miner at the share difficulty.
+func (sess *stratumSession) push(work [3]string) {
+	block := sess.server.agent.pendingBlock(common.HexToHash(work[0]))
+	if block == nil {
+		return
+	}
+	difficulty := sess.server.shareDifficulty(block)
+
+	sess.writeLock.Lock()
+	defer sess.writeLock.Unlock()
+
+	switch sess.protocol {
+	case stratumProxy:
+		sess.write(&stratumResponse{ID: json.RawMessage("0"), Version: "2.0", Result: workPackage(block, difficulty)})
+
+	case stratumNiceHash:
+		if sess.difficulty == nil || sess.difficulty.Cmp(difficulty) != 0 {
+			scaled, _ := new(big.Float).Quo(new(big.Float).SetInt(difficulty), stratumBaseDifficulty).Float64()
+			if err := sess.write(&stratumNotification{Method: "mining.set_difficulty", Params: []interface{}{scaled}}); err != nil {
+				return
+			}
+			sess.difficulty = difficulty
+		}
+		seedHash, headerHash := strings.TrimPrefix(work[1], "0x"), strings.TrimPrefix(work[0], "0x")
+		sess.write(&stratumNotification{Method: "mining.notify", Params: []interface{}{headerHash, seedHash, headerHash, true}})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/common/hexutil"
+	"github.com/ecchain/go-ecchain/consensus/ethash"
+	"github.com/ecchain/go-ecchain/core/types"
+)
+
+// stratumTestClient is a line based JSON client talking to a stratum server.
+type stratumTestClient struct {
+	t       *testing.T
+	conn    net.Conn
+	decoder *json.Decoder
+	id      int
+}
+
+// stratumTestMessage is any message the server may send.
+type stratumTestMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  json.RawMessage   `json:"error"`
+}
+
+func newStratumTestClient(t *testing.T, server *StratumServer) *stratumTestClient {
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial stratum server: %v", err)
+	}
+	return &stratumTestClient{t: t, conn: conn, decoder: json.NewDecoder(conn)}
+}
+
+// read waits for the next message from the server.
+func (c *stratumTestClient) read() *stratumTestMessage {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	msg := new(stratumTestMessage)
+	if err := c.decoder.Decode(msg); err != nil {
+		c.t.Fatalf("failed to read stratum message: %v", err)
+	}
+	return msg
+}
+
+// call sends a request and waits for its reply, skipping any pushed work.
+func (c *stratumTestClient) call(method string, params ...string) *stratumTestMessage {
+	c.id++
+	req := map[string]interface{}{"id": c.id, "method": method, "params": params}
+	if err := json.NewEncoder(c.conn).Encode(req); err != nil {
+		c.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	for {
+		if msg := c.read(); msg.Method == "" && string(msg.ID) != "0" {
+			return msg
+		}
+	}
+}
+
+// newStratumTestServer creates a remote agent and a stratum server accepting
+// shares at the given difficulty.
+func newStratumTestServer(t *testing.T, difficulty int64) (*RemoteAgent, *StratumServer, chan *Result) {
+	engine := ethash.NewTester()
+	results := make(chan *Result, 1)
+
+	agent := NewRemoteAgent(nil, engine)
+	agent.SetReturnCh(results)
+	agent.Start()
+
+	server, err := NewStratumServer(agent, engine, big.NewInt(difficulty))
+	if err != nil {
+		t.Fatalf("failed to create stratum server: %v", err)
+	}
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	return agent, server, results
+}
+
+// newStratumTestWork creates a work package of the given block difficulty.
+func newStratumTestWork(difficulty *big.Int) *Work {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: difficulty, Time: big.NewInt(0), Extra: []byte("stratum")}
+	return &Work{Block: types.NewBlockWithHeader(header), createdAt: time.Now()}
+}
+
+// Tests the EthereumStratum/1.0 dialect: work is pushed after authorization and
+// shares below the block difficulty are credited to the worker's hashrate.
+func TestStratumNiceHash(t *testing.T) {
+	agent, server, _ := newStratumTestServer(t, 2)
+	defer agent.Stop()
+	defer server.Stop()
+
+	client := newStratumTestClient(t, server)
+	defer client.conn.Close()
+
+	var subscription []json.RawMessage
+	if err := json.Unmarshal(client.call("mining.subscribe", "miner/1.0", stratumVersion).Result, &subscription); err != nil || len(subscription) != 2 {
+		t.Fatalf("invalid subscription reply: %v", err)
+	}
+	if reply := client.call("mining.authorize", "0x0000000000000000000000000000000000000001.rig1", "x"); string(reply.Result) != "true" {
+		t.Fatalf("authorization failed: %s", reply.Error)
+	}
+	// Hand out a block no share will solve and wait for the job
+	work := newStratumTestWork(new(big.Int).Lsh(common.Big1, 200))
+	agent.Work() <- work
+
+	if msg := client.read(); msg.Method != "mining.set_difficulty" {
+		t.Fatalf("difficulty not announced: have %q", msg.Method)
+	}
+	notify := client.read()
+	if notify.Method != "mining.notify" || len(notify.Params) != 4 {
+		t.Fatalf("job not pushed: have %q with %d params", notify.Method, len(notify.Params))
+	}
+	var job string
+	json.Unmarshal(notify.Params[0], &job)
+	if common.HexToHash(job) != work.Block.HashNoNonce() {
+		t.Fatalf("job mismatch: have %s, want %x", job, work.Block.HashNoNonce())
+	}
+	// Submit shares until one meets the share difficulty, then try to replay it
+	var accepted string
+	for i := 0; i < 64 && accepted == ""; i++ {
+		nonce := hexutil.EncodeUint64(uint64(i))[2:]
+		for len(nonce) < 12 {
+			nonce = "0" + nonce
+		}
+		if reply := client.call("mining.submit", "rig1", job, nonce); string(reply.Result) == "true" {
+			accepted = nonce
+		}
+	}
+	if accepted == "" {
+		t.Fatalf("no share accepted")
+	}
+	if reply := client.call("mining.submit", "rig1", job, accepted); string(reply.Result) == "true" {
+		t.Fatalf("duplicate share accepted")
+	}
+	server.refresh()
+	if rate := agent.GetHashRate(); rate == 0 {
+		t.Fatalf("share hashrate not reported")
+	}
+}
+
+// Tests the eth-proxy dialect: work is fetched explicitly, forged mix digests are
+// rejected and solutions are passed on to the miner.
+func TestStratumProxy(t *testing.T) {
+	agent, server, results := newStratumTestServer(t, 2)
+	defer agent.Stop()
+	defer server.Stop()
+
+	client := newStratumTestClient(t, server)
+	defer client.conn.Close()
+
+	if reply := client.call("eth_submitLogin", "0x0000000000000000000000000000000000000001"); string(reply.Result) != "true" {
+		t.Fatalf("login failed: %s", reply.Error)
+	}
+	// Hand out a block any nonce solves
+	work := newStratumTestWork(big.NewInt(1))
+	agent.Work() <- work
+
+	var pkg [3]string
+	for i := 0; i < 50 && pkg[0] == ""; i++ {
+		json.Unmarshal(client.call("eth_getWork").Result, &pkg)
+		time.Sleep(10 * time.Millisecond)
+	}
+	hash := work.Block.HashNoNonce()
+	if common.HexToHash(pkg[0]) != hash {
+		t.Fatalf("work mismatch: have %s, want %x", pkg[0], hash)
+	}
+	engine := ethash.NewTester()
+	digest, _ := engine.Hashimoto(1, hash.Bytes(), 1)
+
+	nonce := hexutil.EncodeUint64(1)
+	if reply := client.call("eth_submitWork", nonce, hash.Hex(), common.Hash{}.Hex()); string(reply.Result) != "false" {
+		t.Fatalf("forged mix digest accepted")
+	}
+	if reply := client.call("eth_submitWork", nonce, hash.Hex(), common.BytesToHash(digest).Hex()); string(reply.Result) != "true" {
+		t.Fatalf("valid solution rejected: %s", reply.Error)
+	}
+	select {
+	case result := <-results:
+		if result.Block.Nonce() != 1 || result.Block.MixDigest() != common.BytesToHash(digest) {
+			t.Fatalf("sealed block mismatch: nonce %d, mix %x", result.Block.Nonce(), result.Block.MixDigest())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("solution not passed on to the miner")
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/ecchain/go-ecchain/ecdb"
 	"github.com/ecchain/go-ecchain/event"
 	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/metrics"
 	"github.com/ecchain/go-ecchain/params"
 	"gopkg.in/fatih/set.v0"
 )
@@ -51,6 +52,12 @@ const (
 	chainSideChanSize = 10
 )
 
+var (
+	recommitTxsHistogram  = metrics.NewRegisteredHistogram("miner/recommit/txs", nil, metrics.NewExpDecaySample(1028, 0.015))  // Transactions added by each recommit
+	recommitFeesHistogram = metrics.NewRegisteredHistogram("miner/recommit/fees", nil, metrics.NewExpDecaySample(1028, 0.015)) // Fees (in gwei) added by each recommit
+	recommitSkipMeter     = metrics.NewRegisteredMeter("miner/recommit/skipped", nil)                                          // Recommits not improving the sealed block
+)
+
 // Agent can register themself with the worker
 type Agent interface {
 	Work() chan<- *Work
@@ -71,6 +78,7 @@ type Work struct {
 	family    *set.Set       // family set (used for checking uncle invalidity)
 	uncles    *set.Set       // uncle set
 	tcount    int            // tx count in cycle
+	fees      *big.Int       // fees collected by the included transactions
 
 	Block *types.Block // the new block
 
@@ -114,6 +122,16 @@ type worker struct {
 	coinbase common.Address
 	extra    []byte
 
+	builder         BlockBuilder                // Strategy ordering the pending transactions into blocks
+	reservedGas     uint64                      // Block gas reserved for the local senders
+	reservedSenders map[common.Address]struct{} // Local senders the reserved gas is available to
+
+	gasTarget uint64 // Gas limit the mined blocks are raised towards
+	gasCeil   uint64 // Gas limit the mined blocks are lowered towards (0 = uncapped)
+
+	recommit   time.Duration // Interval of re-filling the pending block while mining (0 = disabled)
+	recommitCh chan struct{} // Notification channel for recommit interval changes
+
 	currentMu sync.Mutex
 	current   *Work
 
@@ -143,6 +161,9 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		possibleUncles: make(map[common.Hash]*types.Block),
 		coinbase:       coinbase,
 		agents:         make(map[Agent]struct{}),
+		builder:        new(PriceBuilder),
+		gasTarget:      params.GenesisGasLimit,
+		recommitCh:     make(chan struct{}, 1),
 		unconfirmed:    newUnconfirmedBlocks(ec.BlockChain(), miningLogAtDepth),
 	}
 	// Subscribe TxPreEvent for tx pool
@@ -170,6 +191,47 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setBuilder(builder BlockBuilder) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.builder = builder
+}
+
+func (self *worker) setGasReservation(gas uint64, senders []common.Address) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.reservedGas = gas
+	self.reservedSenders = make(map[common.Address]struct{})
+	for _, sender := range senders {
+		self.reservedSenders[sender] = struct{}{}
+	}
+}
+
+func (self *worker) setGasLimit(target, ceil uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.gasTarget, self.gasCeil = target, ceil
+}
+
+func (self *worker) setRecommitInterval(interval time.Duration) {
+	self.mu.Lock()
+	self.recommit = interval
+	self.mu.Unlock()
+
+	select {
+	case self.recommitCh <- struct{}{}:
+	default:
+	}
+}
+
+func (self *worker) recommitInterval() time.Duration {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.recommit
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -245,12 +307,41 @@ func (self *worker) update() {
 	defer self.chainHeadSub.Unsubscribe()
 	defer self.chainSideSub.Unsubscribe()
 
+	// Set up the timer re-filling the pending block while mining
+	recommit := time.NewTimer(0)
+	defer recommit.Stop()
+	<-recommit.C
+
+	resetRecommit := func() {
+		if !recommit.Stop() {
+			select {
+			case <-recommit.C:
+			default:
+			}
+		}
+		if interval := self.recommitInterval(); interval > 0 {
+			recommit.Reset(interval)
+		}
+	}
+	resetRecommit()
+
 	for {
 		// A real event arrived, process interesting content
 		select {
 		// Handle ChainHeadEvent
 		case <-self.chainHeadCh:
 			self.commitNewWork()
+			resetRecommit()
+
+		// Handle recommit interval expiration or changes
+		case <-recommit.C:
+			if atomic.LoadInt32(&self.mining) == 1 {
+				self.commitWork(true)
+			}
+			resetRecommit()
+
+		case <-self.recommitCh:
+			resetRecommit()
 
 		// Handle ChainSideEvent
 		case ev := <-self.chainSideCh:
@@ -260,18 +351,30 @@ func (self *worker) update() {
 
 		// Handle TxPreEvent
 		case ev := <-self.txCh:
+			self.mu.Lock()
+			if observer, ok := self.builder.(txObserver); ok {
+				observer.observe(ev.Tx)
+			}
+			self.mu.Unlock()
+
 			// Apply transaction to the pending state if we're not mining
 			if atomic.LoadInt32(&self.mining) == 0 {
+				self.mu.Lock()
 				self.currentMu.Lock()
 				acc, _ := types.Sender(self.current.signer, ev.Tx)
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
 				txset := types.NewTransactionsByPriceAndNonce(self.current.signer, txs)
 
-				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+				reserve := self.reservedGas
+				if _, ok := self.reservedSenders[acc]; ok {
+					reserve = 0
+				}
+				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase, reserve)
 				self.currentMu.Unlock()
+				self.mu.Unlock()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
-				if self.config.Clique != nil && self.config.Clique.Period == 0 {
+				if self.config.Clique != nil && self.config.Clique.Period == 0 || self.config.Dev != nil {
 					self.commitNewWork()
 				}
 			}
@@ -355,10 +458,10 @@ func (self *worker) push(work *Work) {
 }
 
 // makeCurrent creates a new environment for the current cycle.
-func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error {
+func (self *worker) makeCurrent(parent *types.Block, header *types.Header) (*Work, error) {
 	state, err := self.chain.StateAt(parent.Root())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	work := &Work{
 		config:    self.config,
@@ -368,6 +471,7 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 		family:    set.New(),
 		uncles:    set.New(),
 		header:    header,
+		fees:      new(big.Int),
 		createdAt: time.Now(),
 	}
 
@@ -382,11 +486,18 @@ func (self *worker) makeCurrent(parent *types.Block, header *types.Header) error
 
 	// Keep track of transactions which return errors so they can be removed
 	work.tcount = 0
-	self.current = work
-	return nil
+	return work, nil
 }
 
 func (self *worker) commitNewWork() {
+	self.commitWork(false)
+}
+
+// commitWork assembles a new block on top of the current head and hands it to
+// the agents for sealing. If recommit is set, the block is a refill of the one
+// currently being sealed, replacing it only if it earns more fees or includes
+// more transactions.
+func (self *worker) commitWork(recommit bool) {
 	self.mu.Lock()
 	defer self.mu.Unlock()
 	self.uncleMu.Lock()
@@ -397,6 +508,11 @@ func (self *worker) commitNewWork() {
 	ecart := time.Now()
 	parent := self.chain.CurrentBlock()
 
+	prev := self.current
+	if recommit && (prev == nil || prev.Block == nil || prev.header.ParentHash != parent.Hash()) {
+		return // New head arrived meanwhile, it'll get its own work
+	}
+
 	ecamp := ecart.Unix()
 	if parent.Time().Cmp(new(big.Int).SetInt64(ecamp)) >= 0 {
 		ecamp = parent.Time().Int64() + 1
@@ -412,7 +528,7 @@ func (self *worker) commitNewWork() {
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     num.Add(num, common.Big1),
-		GasLimit:   core.CalcGasLimit(parent),
+		GasLimit:   core.CalcGasLimit(parent, self.gasTarget, self.gasCeil),
 		Extra:      self.extra,
 		Time:       big.NewInt(ecamp),
 	}
@@ -438,13 +554,15 @@ func (self *worker) commitNewWork() {
 		}
 	}
 	// Could potentially happen if starting to mine in an odd state.
-	err := self.makeCurrent(parent, header)
+	work, err := self.makeCurrent(parent, header)
 	if err != nil {
 		log.Error("Failed to create mining context", "err", err)
 		return
 	}
-	// Create the current work task and check any fork transitions needed
-	work := self.current
+	if !recommit {
+		self.current = work
+	}
+	// Check any fork transitions needed on the current work task
 	if self.config.DAOForkSupport && self.config.DAOForkBlock != nil && self.config.DAOForkBlock.Cmp(header.Number) == 0 {
 		misc.ApplyDAOHardFork(work.state)
 	}
@@ -453,8 +571,13 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(self.current.signer, pending)
-	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
+	// Refills only announce their logs and state once they replace the block
+	// being sealed, otherwise subscribers would see discarded candidates
+	mux := self.mux
+	if recommit {
+		mux = nil
+	}
+	self.commitPending(work, pending, mux)
 
 	// compute uncles for the new block.
 	var (
@@ -483,6 +606,27 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to finalize block for sealing", "err", err)
 		return
 	}
+	// Only replace the block being sealed if the refill improved it
+	if recommit {
+		addedTxs, addedFees := work.tcount-prev.tcount, new(big.Int).Sub(work.fees, prev.fees)
+		if addedFees.Sign() < 0 || (addedFees.Sign() == 0 && addedTxs <= 0) {
+			recommitSkipMeter.Mark(1)
+			return
+		}
+		recommitTxsHistogram.Update(int64(addedTxs))
+		recommitFeesHistogram.Update(new(big.Int).Div(addedFees, big.NewInt(params.Shannon)).Int64())
+
+		log.Debug("Recommitted mining work", "number", work.Block.Number(), "txs", work.tcount, "added", addedTxs, "fees", work.fees, "elapsed", common.PrettyDuration(time.Since(ecart)))
+		self.current = work
+		self.push(work)
+
+		var logs []*types.Log
+		for _, receipt := range work.receipts {
+			logs = append(logs, receipt.Logs...)
+		}
+		postPendingEvents(self.mux, logs, work.tcount)
+		return
+	}
 	// We only care about logging if we're actually mining.
 	if atomic.LoadInt32(&self.mining) == 1 {
 		log.Info("Commit new mining work", "number", work.Block.Number(), "txs", work.tcount, "uncles", len(uncles), "elapsed", common.PrettyDuration(time.Since(ecart)))
@@ -506,8 +650,93 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
-	gp := new(core.GasPool).AddGas(env.header.GasLimit)
+// commitPending fills the work with the pending transactions in the order of
+// the configured block builder. If gas is reserved for local senders, everyone
+// may first use the block up to the reservation, then the local senders may use
+// the rest, before any leftovers are made available to everyone again.
+//
+// The pending logs and state events are posted to the given mux, if any.
+func (self *worker) commitPending(work *Work, pending map[common.Address]types.Transactions, mux *event.TypeMux) {
+	simulate := func(txs TransactionSource) *big.Int {
+		return work.simulate(txs, self.chain, self.coinbase)
+	}
+	if self.reservedGas == 0 || len(self.reservedSenders) == 0 {
+		work.commitTransactions(mux, self.builder.Order(work.signer, pending, simulate), self.chain, self.coinbase, 0)
+		return
+	}
+	work.commitTransactions(mux, self.builder.Order(work.signer, work.executable(pending, nil), simulate), self.chain, self.coinbase, self.reservedGas)
+	work.commitTransactions(mux, self.builder.Order(work.signer, work.executable(pending, self.reservedSenders), simulate), self.chain, self.coinbase, 0)
+	work.commitTransactions(mux, self.builder.Order(work.signer, work.executable(pending, nil), simulate), self.chain, self.coinbase, 0)
+}
+
+// executable returns the pending transactions still executable on top of the
+// work's state, optionally limited to a set of senders.
+func (env *Work) executable(pending map[common.Address]types.Transactions, senders map[common.Address]struct{}) map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for from, accTxs := range pending {
+		if senders != nil {
+			if _, ok := senders[from]; !ok {
+				continue
+			}
+		}
+		nonce := env.state.GetNonce(from)
+		for len(accTxs) > 0 && accTxs[0].Nonce() < nonce {
+			accTxs = accTxs[1:]
+		}
+		if len(accTxs) > 0 {
+			txs[from] = accTxs
+		}
+	}
+	return txs
+}
+
+// simulate executes the transactions on a copy of the work's state, returning
+// the fees they would pay the miner.
+func (env *Work) simulate(txs TransactionSource, bc *core.BlockChain, coinbase common.Address) *big.Int {
+	var (
+		statedb = env.state.Copy()
+		header  = types.CopyHeader(env.header)
+		gp      = new(core.GasPool).AddGas(header.GasLimit - header.GasUsed)
+		fees    = new(big.Int)
+	)
+	for gp.Gas() >= params.TxGas {
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
+		if tx.Protected() && !env.config.IsEIP155(header.Number) {
+			txs.Pop()
+			continue
+		}
+		snap := statedb.Snapshot()
+		statedb.Prepare(tx.Hash(), common.Hash{}, 0)
+
+		_, gas, err := core.ApplyTransaction(env.config, bc, &coinbase, gp, statedb, header, tx, &header.GasUsed, vm.Config{})
+		switch err {
+		case nil:
+			fees.Add(fees, new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(gas)))
+			txs.Shift()
+
+		case core.ErrGasLimitReached, core.ErrNonceTooHigh:
+			statedb.RevertToSnapshot(snap)
+			txs.Pop()
+
+		default:
+			statedb.RevertToSnapshot(snap)
+			txs.Shift()
+		}
+	}
+	return fees
+}
+
+// commitTransactions applies transactions from the source to the work until it
+// runs dry or the block is full, leaving the reserved amount of gas unused. The
+// pending logs and state events are only posted if a mux is given.
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TransactionSource, bc *core.BlockChain, coinbase common.Address, reserve uint64) {
+	if env.header.GasUsed+reserve >= env.header.GasLimit {
+		return
+	}
+	gp := new(core.GasPool).AddGas(env.header.GasLimit - env.header.GasUsed - reserve)
 
 	var coalescedLogs []*types.Log
 
@@ -569,24 +798,32 @@ func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsB
 		}
 	}
 
-	if len(coalescedLogs) > 0 || env.tcount > 0 {
-		// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
-		// logs by filling in the block hash when the block was mined by the local miner. This can
-		// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
-		cpy := make([]*types.Log, len(coalescedLogs))
-		for i, l := range coalescedLogs {
-			cpy[i] = new(types.Log)
-			*cpy[i] = *l
-		}
-		go func(logs []*types.Log, tcount int) {
-			if len(logs) > 0 {
-				mux.Post(core.PendingLogsEvent{Logs: logs})
-			}
-			if tcount > 0 {
-				mux.Post(core.PendingStateEvent{})
-			}
-		}(cpy, env.tcount)
+	if mux != nil {
+		postPendingEvents(mux, coalescedLogs, env.tcount)
+	}
+}
+
+// postPendingEvents announces the logs and the state change of the pending block.
+func postPendingEvents(mux *event.TypeMux, logs []*types.Log, tcount int) {
+	if len(logs) == 0 && tcount == 0 {
+		return
 	}
+	// make a copy, the state caches the logs and these logs get "upgraded" from pending to mined
+	// logs by filling in the block hash when the block was mined by the local miner. This can
+	// cause a race condition if a log was "upgraded" before the PendingLogsEvent is processed.
+	cpy := make([]*types.Log, len(logs))
+	for i, l := range logs {
+		cpy[i] = new(types.Log)
+		*cpy[i] = *l
+	}
+	go func(logs []*types.Log, tcount int) {
+		if len(logs) > 0 {
+			mux.Post(core.PendingLogsEvent{Logs: logs})
+		}
+		if tcount > 0 {
+			mux.Post(core.PendingStateEvent{})
+		}
+	}(cpy, tcount)
 }
 
 func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, coinbase common.Address, gp *core.GasPool) (error, []*types.Log) {
@@ -599,6 +836,7 @@ func (env *Work) commitTransaction(tx *types.Transaction, bc *core.BlockChain, c
 	}
 	env.txs = append(env.txs, tx)
 	env.receipts = append(env.receipts, receipt)
+	env.fees.Add(env.fees, new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(receipt.GasUsed)))
 
 	return nil, receipt.Logs
 }
@@ -0,0 +1,194 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/accounts"
+	"github.com/ecchain/go-ecchain/common"
+	"github.com/ecchain/go-ecchain/consensus/ethash"
+	"github.com/ecchain/go-ecchain/core"
+	"github.com/ecchain/go-ecchain/core/types"
+	"github.com/ecchain/go-ecchain/core/vm"
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
+	"github.com/ecchain/go-ecchain/event"
+	"github.com/ecchain/go-ecchain/params"
+)
+
+// testWorkerBackend implements Backend with a chain and transaction pool on top
+// of a genesis block funding a single account.
+type testWorkerBackend struct {
+	db     ecdb.Database
+	chain  *core.BlockChain
+	txPool *core.TxPool
+}
+
+func newTestWorkerBackend(t *testing.T, key *ecdsa.PrivateKey) *testWorkerBackend {
+	db, _ := ecdb.NewMemDatabase()
+	gspec := core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(params.ecer)}},
+	}
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return &testWorkerBackend{
+		db:     db,
+		chain:  chain,
+		txPool: core.NewTxPool(core.DefaultTxPoolConfig, gspec.Config, chain),
+	}
+}
+
+func (b *testWorkerBackend) AccountManager() *accounts.Manager { return nil }
+func (b *testWorkerBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testWorkerBackend) TxPool() *core.TxPool              { return b.txPool }
+func (b *testWorkerBackend) ChainDb() ecdb.Database            { return b.db }
+
+func (b *testWorkerBackend) close() {
+	b.txPool.Stop()
+	b.chain.Stop()
+}
+
+// testWorkerAgent is a mining agent collecting the work pushed to it.
+type testWorkerAgent struct {
+	workCh chan *Work
+}
+
+func (a *testWorkerAgent) Work() chan<- *Work         { return a.workCh }
+func (a *testWorkerAgent) SetReturnCh(chan<- *Result) {}
+func (a *testWorkerAgent) Stop()                      {}
+func (a *testWorkerAgent) Start()                     {}
+func (a *testWorkerAgent) GetHashRate() int64         { return 0 }
+
+// newTestWorker creates a mining worker without its event loops, so that new
+// work is only committed when the test asks for it.
+func newTestWorker(backend *testWorkerBackend, agent Agent) *worker {
+	return &worker{
+		config:         params.TestChainConfig,
+		engine:         backend.chain.Engine(),
+		ec:             backend,
+		mux:            new(event.TypeMux),
+		chain:          backend.chain,
+		chainDb:        backend.db,
+		proc:           backend.chain.Validator(),
+		possibleUncles: make(map[common.Hash]*types.Block),
+		agents:         map[Agent]struct{}{agent: {}},
+		builder:        new(PriceBuilder),
+		gasTarget:      params.GenesisGasLimit,
+		recommitCh:     make(chan struct{}, 1),
+		unconfirmed:    newUnconfirmedBlocks(backend.chain, miningLogAtDepth),
+		mining:         1,
+	}
+}
+
+// Tests that refilling the block being sealed only replaces it if the new one
+// earns more fees, and never if the chain head moved meanwhile. Pending state
+// events are only posted for refills which replace the block.
+func TestWorkerRecommit(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	backend := newTestWorkerBackend(t, key)
+	defer backend.close()
+
+	agent := &testWorkerAgent{workCh: make(chan *Work, 4)}
+	worker := newTestWorker(backend, agent)
+
+	addTx := func(nonce uint64, price int64) {
+		tx := newBuilderTestTx(key, nonce, params.TxGas, price)
+		if err := backend.txPool.AddLocal(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	expectWork := func(txs int) *Work {
+		select {
+		case work := <-agent.workCh:
+			if have := len(work.Block.Transactions()); have != txs {
+				t.Fatalf("transaction count mismatch: have %d, want %d", have, txs)
+			}
+			return work
+		default:
+			t.Fatalf("no work pushed")
+		}
+		return nil
+	}
+	expectNoWork := func() {
+		select {
+		case work := <-agent.workCh:
+			t.Fatalf("unexpected work pushed with %d transactions", len(work.Block.Transactions()))
+		default:
+		}
+	}
+	events := worker.mux.Subscribe(core.PendingStateEvent{})
+	defer events.Unsubscribe()
+
+	expectEvent := func(want bool) {
+		select {
+		case <-events.Chan():
+			if !want {
+				t.Fatalf("unexpected pending state event")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Fatalf("no pending state event posted")
+			}
+		}
+	}
+	// Start sealing an empty block, then refill it as transactions arrive
+	worker.commitNewWork()
+	empty := expectWork(0)
+
+	addTx(0, 1)
+	worker.commitWork(true)
+	refilled := expectWork(1)
+	expectEvent(true)
+	if worker.current != refilled || refilled.Block.ParentHash() != empty.Block.ParentHash() {
+		t.Fatalf("refilled block not being sealed on the same parent")
+	}
+	if refilled.fees.Cmp(empty.fees) <= 0 {
+		t.Fatalf("refill fees not improved: have %v, previous %v", refilled.fees, empty.fees)
+	}
+	// Refilling without new transactions must keep the block being sealed
+	worker.commitWork(true)
+	expectNoWork()
+	expectEvent(false)
+	if worker.current != refilled {
+		t.Fatalf("block replaced by refill without improvement")
+	}
+	// Refilling after a new head arrived must bail out, leaving it to new work
+	blocks, _ := core.GenerateChain(params.TestChainConfig, backend.chain.Genesis(), backend.chain.Engine(), backend.db, 1, nil)
+	if _, err := backend.chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert new head: %v", err)
+	}
+	addTx(1, 2)
+	worker.commitWork(true)
+	expectNoWork()
+	if worker.current != refilled {
+		t.Fatalf("block replaced by refill on stale parent")
+	}
+	// New work on the new head picks the pending transactions up again
+	worker.commitNewWork()
+	if work := expectWork(2); work.Block.ParentHash() != blocks[0].Hash() {
+		t.Fatalf("new work parent mismatch: have %x, want %x", work.Block.ParentHash(), blocks[0].Hash())
+	}
+}
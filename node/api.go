@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ecchain/go-ecchain/common/hexutil"
@@ -34,12 +35,15 @@ import (
 // over a secure RPC channel.
 type PrivateAdminAPI struct {
 	node *Node // Node interfaced by this API
+
+	topics map[string]chan struct{} // Stop channels of the topics registered through the API
+	lock   sync.Mutex
 }
 
 // NewPrivateAdminAPI creates a new API definition for the private admin methods
 // of the node itself.
 func NewPrivateAdminAPI(node *Node) *PrivateAdminAPI {
-	return &PrivateAdminAPI{node: node}
+	return &PrivateAdminAPI{node: node, topics: make(map[string]chan struct{})}
 }
 
 // AddPeer requests connecting to a remote node, and also maintaining the new
@@ -75,6 +79,122 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer bans a node ID, enode URL or IP, disconnecting matching peers. The
+// optional duration (e.g. "24h") defaults to the configured ban duration.
+func (api *PrivateAdminAPI) BanPeer(target string, duration *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	var d time.Duration
+	if duration != nil {
+		var err error
+		if d, err = time.ParseDuration(*duration); err != nil {
+			return false, fmt.Errorf("invalid duration: %v", err)
+		}
+	}
+	if err := server.BanPeer(target, d); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a node ID, enode URL or IP, returning whecer it
+// was banned.
+func (api *PrivateAdminAPI) UnbanPeer(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	return server.UnbanPeer(target)
+}
+
+// RegisterTopic advertises the node under a discovery v5 topic. The optional
+// duration (e.g. "1h") limits the advertisement, which otherwise lasts until
+// the topic is unregistered or the node stops.
+func (api *PrivateAdminAPI) RegisterTopic(topic string, duration *string) (bool, error) {
+	var d time.Duration
+	if duration != nil {
+		var err error
+		if d, err = time.ParseDuration(*duration); err != nil {
+			return false, fmt.Errorf("invalid duration: %v", err)
+		}
+	}
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	// Replace any earlier registration of the topic, restarting its duration
+	if stop, ok := api.topics[topic]; ok {
+		close(stop)
+		delete(api.topics, topic)
+	}
+	stop := make(chan struct{})
+	if err := api.node.RegisterTopic(topic, stop); err != nil {
+		return false, err
+	}
+	api.topics[topic] = stop
+	if d > 0 {
+		time.AfterFunc(d, func() { api.stopTopic(topic, stop) })
+	}
+	return true, nil
+}
+
+// UnregisterTopic stops advertising the node under a discovery v5 topic
+// registered through the API, returning whecer it was registered.
+func (api *PrivateAdminAPI) UnregisterTopic(topic string) (bool, error) {
+	api.lock.Lock()
+	stop, ok := api.topics[topic]
+	api.lock.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return api.stopTopic(topic, stop), nil
+}
+
+// stopTopic ends the registration of a topic, unless it was replaced since.
+func (api *PrivateAdminAPI) stopTopic(topic string, stop chan struct{}) bool {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	if api.topics[topic] != stop {
+		return false
+	}
+	close(stop)
+	delete(api.topics, topic)
+	return true
+}
+
+// SearchTopic looks up nodes advertising a discovery v5 topic, returning their
+// enode URLs. The search ends when max nodes are found (by default 16) or after
+// the timeout (by default "10s").
+func (api *PrivateAdminAPI) SearchTopic(topic string, max *int, timeout *string) ([]string, error) {
+	var (
+		limit = 16
+		d     = 10 * time.Second
+	)
+	if max != nil {
+		limit = *max
+	}
+	if timeout != nil {
+		var err error
+		if d, err = time.ParseDuration(*timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout: %v", err)
+		}
+	}
+	nodes, err := api.node.SearchTopic(topic, limit, d)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, len(nodes))
+	for i, node := range nodes {
+		urls[i] = node.String()
+	}
+	return urls, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -251,6 +371,16 @@ func (api *PublicAdminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// PeerScores retrieves the reputation scores of recently misbehaving peers and
+// the active peer bans.
+func (api *PublicAdminAPI) PeerScores() (*p2p.ReputationInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.PeerScores()
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *PublicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
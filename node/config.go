@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/ecchain/go-ecchain/accounts"
+	"github.com/ecchain/go-ecchain/accounts/external"
 	"github.com/ecchain/go-ecchain/accounts/keystore"
 	"github.com/ecchain/go-ecchain/accounts/usbwallet"
 	"github.com/ecchain/go-ecchain/common"
@@ -85,6 +86,10 @@ type Config struct {
 	// NoUSB disables hardware wallet monitoring and connectivity.
 	NoUSB bool `toml:",omitempty"`
 
+	// ExternalSigner is the endpoint (IPC path or URL) of a remote signer whose
+	// accounts are made available next to the local ones.
+	ExternalSigner string `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -119,6 +124,10 @@ type Config struct {
 	// exposed.
 	HTTPModules []string `toml:",omitempty"`
 
+	// Health configures the readiness checks served on /ready by the HTTP RPC
+	// endpoint alongside the /health liveness probe.
+	Health HealthConfig
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
@@ -433,5 +442,8 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 			backends = append(backends, trezorhub)
 		}
 	}
+	if conf.ExternalSigner != "" {
+		backends = append(backends, external.NewBackend(conf.ExternalSigner))
+	}
 	return accounts.NewManager(backends...), ephemeral, nil
 }
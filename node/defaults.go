@@ -41,6 +41,10 @@ var DefaultConfig = Config{
 	HTTPVirtualHosts: []string{"localhost"},
 	WSPort:           DefaultWSPort,
 	WSModules:        []string{"net", "web3"},
+	Health: HealthConfig{
+		MinPeers:        1,
+		MaxBlocksBehind: 16,
+	},
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   25,
@@ -24,10 +24,11 @@ import (
 )
 
 var (
-	ErrDatadirUsed    = errors.New("datadir already used by another process")
-	ErrNodeStopped    = errors.New("node not started")
-	ErrNodeRunning    = errors.New("node already running")
-	ErrServiceUnknown = errors.New("unknown service")
+	ErrDatadirUsed      = errors.New("datadir already used by another process")
+	ErrNodeStopped      = errors.New("node not started")
+	ErrNodeRunning      = errors.New("node already running")
+	ErrServiceUnknown   = errors.New("unknown service")
+	ErrNoTopicDiscovery = errors.New("topic discovery (discovery v5) not enabled")
 
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
@@ -0,0 +1,120 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	ecereum "github.com/ecchain/go-ecchain"
+)
+
+// HealthConfig contains the thresholds used by the readiness probe served on
+// the HTTP RPC endpoint. Zero values disable the individual checks.
+type HealthConfig struct {
+	// MinPeers is the minimum number of connected peers for the node to be
+	// considered ready.
+	MinPeers int `toml:",omitempty"`
+
+	// MaxBlocksBehind is the maximum number of blocks the local chain may lag
+	// behind the highest block announced by the network while syncing. Zero
+	// means the node must not be syncing at all.
+	MaxBlocksBehind uint64 `toml:",omitempty"`
+
+	// MaxBlockAge is the maximum age of the current head block for the node to
+	// be considered ready.
+	MaxBlockAge time.Duration `toml:",omitempty"`
+}
+
+// ChainHealthReporter is an optional interface which services tracking a block
+// chain may implement to feed the readiness probe with their sync status.
+type ChainHealthReporter interface {
+	// SyncProgress retrieves the current synchronisation status of the chain.
+	SyncProgress() ecereum.SyncProgress
+
+	// HeadTime retrieves the timestamp of the current head block.
+	HeadTime() time.Time
+}
+
+// healthStatus is the JSON response returned by the health and readiness probes.
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// newHealthHandler wraps an HTTP handler, serving the liveness probe on /health
+// and the readiness probe on /ready while passing all other requests through.
+func (n *Node) newHealthHandler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthStatus(w, http.StatusOK, &healthStatus{Status: "ok"})
+	})
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		failures := n.checkReadiness()
+		if len(failures) > 0 {
+			writeHealthStatus(w, http.StatusServiceUnavailable, &healthStatus{Status: "unavailable", Checks: failures})
+			return
+		}
+		writeHealthStatus(w, http.StatusOK, &healthStatus{Status: "ok"})
+	})
+	mux.Handle("/", next)
+	return mux
+}
+
+// checkReadiness evaluates the configured readiness checks, returning the
+// reason for each failing one keyed by check name.
+func (n *Node) checkReadiness() map[string]string {
+	n.lock.RLock()
+	server, services := n.server, n.services
+	n.lock.RUnlock()
+
+	failures := make(map[string]string)
+	if server == nil {
+		failures["node"] = "not running"
+		return failures
+	}
+	config := n.config.Health
+
+	if peers := server.PeerCount(); peers < config.MinPeers {
+		failures["peers"] = fmt.Sprintf("%d connected, %d required", peers, config.MinPeers)
+	}
+	for _, service := range services {
+		reporter, ok := service.(ChainHealthReporter)
+		if !ok {
+			continue
+		}
+		progress := reporter.SyncProgress()
+		if progress.HighestBlock > progress.CurrentBlock+config.MaxBlocksBehind {
+			failures["sync"] = fmt.Sprintf("block %d of %d", progress.CurrentBlock, progress.HighestBlock)
+		}
+		if config.MaxBlockAge > 0 {
+			if age := time.Since(reporter.HeadTime()); age > config.MaxBlockAge {
+				failures["head"] = fmt.Sprintf("head block is %v old, %v allowed", age.Truncate(time.Second), config.MaxBlockAge)
+			}
+		}
+	}
+	return failures
+}
+
+// writeHealthStatus serializes a probe result into an HTTP response.
+func writeHealthStatus(w http.ResponseWriter, code int, status *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ecereum "github.com/ecchain/go-ecchain"
+)
+
+// chainHealthService is a noop service reporting a configurable chain status.
+type chainHealthService struct {
+	NoopService
+
+	progress ecereum.SyncProgress
+	head     time.Time
+}
+
+func (s *chainHealthService) SyncProgress() ecereum.SyncProgress { return s.progress }
+func (s *chainHealthService) HeadTime() time.Time                { return s.head }
+
+// Tests that the readiness probe reports the configured checks correctly.
+func TestHealthProbes(t *testing.T) {
+	config := testNodeConfig()
+	config.Health = HealthConfig{MaxBlocksBehind: 10, MaxBlockAge: time.Minute}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	service := &chainHealthService{head: time.Now()}
+	if err := stack.Register(func(*ServiceContext) (Service, error) { return service, nil }); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	handler := stack.newHealthHandler(http.NotFoundHandler())
+
+	probe := func(path string) (int, map[string]string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		var status healthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", path, err)
+		}
+		return rec.Code, status.Checks
+	}
+	// A stopped node is alive but not ready
+	if code, _ := probe("/health"); code != http.StatusOK {
+		t.Fatalf("liveness code mismatch: have %d, want %d", code, http.StatusOK)
+	}
+	if code, checks := probe("/ready"); code != http.StatusServiceUnavailable || checks["node"] == "" {
+		t.Fatalf("stopped readiness mismatch: code %d, checks %v", code, checks)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	// A synced node with a fresh head should be ready
+	if code, checks := probe("/ready"); code != http.StatusOK {
+		t.Fatalf("synced readiness mismatch: code %d, checks %v", code, checks)
+	}
+	// A node lagging too far behind or with a stale head should not
+	service.progress = ecereum.SyncProgress{CurrentBlock: 100, HighestBlock: 111}
+	service.head = time.Now().Add(-time.Hour)
+
+	code, checks := probe("/ready")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("lagging readiness code mismatch: have %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if checks["sync"] == "" || checks["head"] == "" {
+		t.Fatalf("missing failed checks: %v", checks)
+	}
+	if _, ok := checks["peers"]; ok {
+		t.Fatalf("unexpected peer failure with zero minimum: %v", checks)
+	}
+	// Other requests should be passed through to the wrapped handler
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("passthrough code mismatch: have %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
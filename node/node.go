@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ecchain/go-ecchain/accounts"
 	"github.com/ecchain/go-ecchain/ecdb"
@@ -32,6 +33,7 @@ import (
 	"github.com/ecchain/go-ecchain/internal/debug"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/p2p"
+	"github.com/ecchain/go-ecchain/p2p/discv5"
 	"github.com/ecchain/go-ecchain/rpc"
 	"github.com/prometheus/prometheus/util/flock"
 )
@@ -171,6 +173,7 @@ func (n *Node) Start() error {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			config:         n.config,
+			nodeKey:        n.serverConfig.PrivateKey,
 			services:       make(map[reflect.Type]Service),
 			EventMux:       n.eventmux,
 			AccountManager: n.accman,
@@ -393,7 +396,9 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return err
 	}
-	go rpc.NewHTTPServer(cors, vhosts, handler).Serve(listener)
+	server := rpc.NewHTTPServer(cors, vhosts, handler)
+	server.Handler = n.newHealthHandler(server.Handler)
+	go server.Serve(listener)
 	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
@@ -583,6 +588,70 @@ func (n *Node) Server() *p2p.Server {
 	return n.server
 }
 
+// topicSearchInterval is the time between the lookups of a topic search.
+const topicSearchInterval = time.Second
+
+// topicDiscovery retrieves the discovery v5 network of the running server.
+func (n *Node) topicDiscovery() (*discv5.Network, error) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.server == nil {
+		return nil, ErrNodeStopped
+	}
+	if n.server.DiscV5 == nil {
+		return nil, ErrNoTopicDiscovery
+	}
+	return n.server.DiscV5, nil
+}
+
+// RegisterTopic advertises the node under the given topic in the discovery v5
+// network, allowing services to be found by other nodes searching the topic.
+// The advertisement lasts until the stop channel is closed or the node stops.
+func (n *Node) RegisterTopic(topic string, stop <-chan struct{}) error {
+	disc, err := n.topicDiscovery()
+	if err != nil {
+		return err
+	}
+	go disc.RegisterTopic(discv5.Topic(topic), stop)
+	return nil
+}
+
+// SearchTopic looks up nodes advertising the given topic in the discovery v5
+// network. It returns once max nodes are found (max <= 0 meaning no limit) or
+// the timeout expires.
+func (n *Node) SearchTopic(topic string, max int, timeout time.Duration) ([]*discv5.Node, error) {
+	disc, err := n.topicDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *discv5.Node, 100)
+		seen      = make(map[discv5.NodeID]bool)
+		nodes     []*discv5.Node
+	)
+	setPeriod <- topicSearchInterval
+	go disc.SearchTopic(discv5.Topic(topic), setPeriod, found, nil)
+	defer close(setPeriod)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for max <= 0 || len(nodes) < max {
+		select {
+		case node := <-found:
+			if !seen[node.ID] {
+				seen[node.ID] = true
+				nodes = append(nodes, node)
+			}
+		case <-deadline.C:
+			return nodes, nil
+		}
+	}
+	return nodes, nil
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (n *Node) Service(service interface{}) error {
 	n.lock.RLock()
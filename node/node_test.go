@@ -573,3 +573,52 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that topics can only be registered and searched with discovery v5, and
+// that the RPC API tracks the registrations it makes.
+func TestNodeTopics(t *testing.T) {
+	stack, err := New(testNodeConfig())
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.RegisterTopic("foo", nil); err != ErrNodeStopped {
+		t.Fatalf("register error mismatch: have %v, want %v", err, ErrNodeStopped)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	if _, err := stack.SearchTopic("foo", 1, time.Millisecond); err != ErrNoTopicDiscovery {
+		t.Fatalf("search error mismatch: have %v, want %v", err, ErrNoTopicDiscovery)
+	}
+	stack.Stop()
+
+	// Enable discovery v5 and check the topic API
+	config := testNodeConfig()
+	config.P2P.ListenAddr = "127.0.0.1:0"
+	config.P2P.DiscoveryV5 = true
+	if stack, err = New(config); err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	defer stack.Stop()
+
+	api := NewPrivateAdminAPI(stack)
+	duration := "1h"
+	if ok, err := api.RegisterTopic("foo", &duration); !ok || err != nil {
+		t.Fatalf("failed to register topic: %v", err)
+	}
+	if ok, _ := api.UnregisterTopic("foo"); !ok {
+		t.Error("registered topic not unregistered")
+	}
+	if ok, _ := api.UnregisterTopic("foo"); ok {
+		t.Error("topic unregistered twice")
+	}
+	// Nobody advertises the topic in the lonely network
+	timeout := "100ms"
+	nodes, err := api.SearchTopic("foo", nil, &timeout)
+	if err != nil || len(nodes) != 0 {
+		t.Errorf("search mismatch: have %v (err %v), want no nodes", nodes, err)
+	}
+}
@@ -17,6 +17,7 @@
 package node
 
 import (
+	"crypto/ecdsa"
 	"reflect"
 
 	"github.com/ecchain/go-ecchain/accounts"
@@ -31,6 +32,7 @@ import (
 // as well as utility methods to operate on the service environment.
 type ServiceContext struct {
 	config         *Config
+	nodeKey        *ecdsa.PrivateKey        // Private key the p2p server identifies with
 	services       map[reflect.Type]Service // Index of the already constructed services
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
@@ -57,6 +59,15 @@ func (ctx *ServiceContext) ResolvePath(path string) string {
 	return ctx.config.resolvePath(path)
 }
 
+// NodeKey retrieves the private key the node uses to identify itself on the p2p
+// network.
+func (ctx *ServiceContext) NodeKey() *ecdsa.PrivateKey {
+	if ctx.nodeKey != nil {
+		return ctx.nodeKey
+	}
+	return ctx.config.NodeKey()
+}
+
 // Service retrieves a currently running service registered of a specific type.
 func (ctx *ServiceContext) Service(service interface{}) error {
 	element := reflect.ValueOf(service).Elem()
@@ -26,6 +26,7 @@ import (
 
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 )
 
@@ -71,6 +72,8 @@ func (t TCPDialer) Dial(dest *discover.Node) (net.Conn, error) {
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
+	dns         nodeSource  // DNS node lists, nil if not configured
+	bans        *reputation // banned nodes, nil if not tracked
 	netrestrict *netutil.Netlist
 
 	lookupRunning bool
@@ -90,6 +93,14 @@ type discoverTable interface {
 	Resolve(target discover.NodeID) *discover.Node
 	Lookup(target discover.NodeID) []*discover.Node
 	ReadRandomNodes([]*discover.Node) int
+	NodeRecord(n *discover.Node) (*enr.Record, error)
+	SetRecordEntry(e enr.Entry) error
+}
+
+// nodeSource provides random dial candidates, in addition to the ones found by
+// the discovery table.
+type nodeSource interface {
+	ReadRandomNodes([]*discover.Node) int
 }
 
 // the dial history remembers recent dials.
@@ -218,7 +229,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	// Use random nodes from the table for half of the necessary
 	// dynamic dials.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
@@ -226,6 +237,20 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 			}
 		}
 	}
+	// Use random nodes from the DNS node lists for half of the remaining
+	// dynamic dials, or all of them if there is no discovery table.
+	if dnsCandidates := needDynDials / 2; s.dns != nil && needDynDials > 0 {
+		if s.ntab == nil {
+			dnsCandidates = needDynDials
+		}
+		nodes := make([]*discover.Node, dnsCandidates)
+		n := s.dns.ReadRandomNodes(nodes)
+		for i := 0; i < n; i++ {
+			if addDial(dynDialedConn, nodes[i]) {
+				needDynDials--
+			}
+		}
+	}
 	// Create dynamic dials from random lookup results, removing tried
 	// items from the result buffer.
 	i := 0
@@ -236,7 +261,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[discover.NodeID]*Peer, now
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 	// Launch a discovery lookup if more candidates are needed.
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if len(s.lookupBuf) < needDynDials && !s.lookupRunning && s.ntab != nil {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
@@ -258,6 +283,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBanned           = errors.New("banned")
 )
 
 func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer) error {
@@ -273,6 +299,8 @@ func (s *dialstate) checkDial(n *discover.Node, peers map[discover.NodeID]*Peer)
 		return errNotWhitelisted
 	case s.hist.contains(n.ID):
 		return errRecentlyDialed
+	case s.bans != nil && s.bans.banned(n.ID, n.IP):
+		return errBanned
 	}
 	return nil
 }
@@ -294,6 +322,9 @@ func (t *dialTask) Do(srv *Server) {
 			return
 		}
 	}
+	if t.flags&dynDialedConn != 0 && !t.checkRecord(srv) {
+		return
+	}
 	err := t.dial(srv, t.dest)
 	if err != nil {
 		log.Trace("Dial error", "task", t, "err", err)
@@ -306,6 +337,36 @@ func (t *dialTask) Do(srv *Server) {
 	}
 }
 
+// checkRecord runs the dial filters of the protocols against the node record of
+// a discovered destination, reporting whecer the node is worth dialing. Nodes
+// whose record can't be retrieved are dialed anyway.
+func (t *dialTask) checkRecord(srv *Server) bool {
+	if srv.ntab == nil {
+		return true
+	}
+	var filters []func(*enr.Record) error
+	for _, proto := range srv.Protocols {
+		if proto.DialFilter != nil {
+			filters = append(filters, proto.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return true
+	}
+	record, err := srv.ntab.NodeRecord(t.dest)
+	if err != nil {
+		log.Trace("Node record unavailable", "id", t.dest.ID, "err", err)
+		return true
+	}
+	for _, filter := range filters {
+		if err := filter(record); err != nil {
+			log.Debug("Skipping dial of filtered node", "id", t.dest.ID, "err", err)
+			return false
+		}
+	}
+	return true
+}
+
 // resolve attempts to find the current endpoint for the destination
 // using discovery.
 //
@@ -18,13 +18,16 @@ package p2p
 
 import (
 	"encoding/binary"
+	"errors"
 	"net"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 )
 
@@ -83,6 +86,11 @@ func (t fakeTable) Close()                                   {}
 func (t fakeTable) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t fakeTable) Resolve(discover.NodeID) *discover.Node   { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*discover.Node) int { return copy(buf, t) }
+func (t fakeTable) SetRecordEntry(enr.Entry) error           { return nil }
+
+func (t fakeTable) NodeRecord(*discover.Node) (*enr.Record, error) {
+	return nil, errors.New("no record")
+}
 
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
@@ -219,6 +227,46 @@ func TestDialStateDynDial(t *testing.T) {
 	})
 }
 
+// Tests that nodes from DNS node lists are dialed when there is no discovery
+// table, and no lookups are launched.
+func TestDialStateDNS(t *testing.T) {
+	state := newDialState(nil, nil, nil, 4, nil)
+	state.dns = fakeTable{
+		{ID: uintID(1)},
+		{ID: uintID(2)},
+		{ID: uintID(3)},
+	}
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			// All nodes are dialed, the lists only hold three.
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+			},
+			// Once connected, there is nothing left to dial.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, id: uintID(1)}},
+					{rw: &conn{flags: dynDialedConn, id: uintID(2)}},
+					{rw: &conn{flags: dynDialedConn, id: uintID(3)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(1)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(2)}},
+					&dialTask{flags: dynDialedConn, dest: &discover.Node{ID: uintID(3)}},
+				},
+				new: []task{
+					&waitExpireTask{Duration: 30 * time.Second},
+				},
+			},
+		},
+	})
+}
+
 // Tests that bootnodes are dialed if no peers are connectd, but not otherwise.
 func TestDialStateDynDialBootnode(t *testing.T) {
 	bootnodes := []*discover.Node{
@@ -432,6 +480,32 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that banned nodes and addresses are not dialed.
+func TestDialStateBanned(t *testing.T) {
+	table := fakeTable{
+		{ID: uintID(1), IP: net.ParseIP("10.0.0.1")},
+		{ID: uintID(2), IP: net.ParseIP("10.0.0.2")},
+		{ID: uintID(3), IP: net.ParseIP("10.0.0.3")},
+	}
+	bans := newReputation(0, 0, nil)
+	bans.banFor(uintID(1).String(), time.Hour)
+	bans.banFor("10.0.0.2", time.Hour)
+
+	dialer := newDialState(nil, nil, table, 10, nil)
+	dialer.bans = bans
+	runDialTest(t, dialtest{
+		init: dialer,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[2]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wanecatic := []*discover.Node{
@@ -655,6 +729,67 @@ func TestDialResolve(t *testing.T) {
 	}
 }
 
+// This test checks that discovered nodes are only dialed if their node record
+// passes the dial filters of the protocols.
+func TestDialFilter(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		record   = new(enr.Record)
+		errMatch = errors.New("incompatible")
+	)
+	record.Set(enr.WithEntry("test", uint(1)))
+	if err := record.Sign(key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	filter := func(record *enr.Record) error {
+		var version uint
+		if err := record.Load(enr.WithEntry("test", &version)); err != nil {
+			return err
+		}
+		if version != 1 {
+			return errMatch
+		}
+		return nil
+	}
+	tests := []struct {
+		flags  connFlag
+		record *enr.Record
+		filter func(*enr.Record) error
+		dialed bool
+	}{
+		{flags: dynDialedConn, record: record, filter: nil, dialed: true},
+		{flags: dynDialedConn, record: record, filter: filter, dialed: true},
+		{flags: dynDialedConn, record: record, filter: func(*enr.Record) error { return errMatch }, dialed: false},
+		{flags: dynDialedConn, record: nil, filter: func(*enr.Record) error { return errMatch }, dialed: true},
+		{flags: staticDialedConn, record: record, filter: func(*enr.Record) error { return errMatch }, dialed: true},
+	}
+	for i, tt := range tests {
+		dialer := new(countingDialer)
+		srv := &Server{
+			ntab: &resolveMock{record: tt.record},
+			Config: Config{
+				Dialer:    dialer,
+				Protocols: []Protocol{{Name: "test", DialFilter: tt.filter}},
+			},
+		}
+		task := &dialTask{flags: tt.flags, dest: discover.NewNode(uintID(1), net.IP{127, 0, 0, 1}, 30303, 30303)}
+		task.Do(srv)
+		if dialed := dialer.dials > 0; dialed != tt.dialed {
+			t.Errorf("test %d: dialed mismatch: have %v, want %v", i, dialed, tt.dialed)
+		}
+	}
+}
+
+// countingDialer counts the dial attempts, failing all of them.
+type countingDialer struct {
+	dials int
+}
+
+func (d *countingDialer) Dial(*discover.Node) (net.Conn, error) {
+	d.dials++
+	return nil, errors.New("dial disabled")
+}
+
 // compares task lists but doesn't care about the order.
 func sametasks(a, b []task) bool {
 	if len(a) != len(b) {
@@ -682,6 +817,7 @@ func uintID(i uint32) discover.NodeID {
 type resolveMock struct {
 	resolveCalls []discover.NodeID
 	answer       *discover.Node
+	record       *enr.Record
 }
 
 func (t *resolveMock) Resolve(id discover.NodeID) *discover.Node {
@@ -694,3 +830,11 @@ func (t *resolveMock) Close()                                   {}
 func (t *resolveMock) Booecrap([]*discover.Node)               {}
 func (t *resolveMock) Lookup(discover.NodeID) []*discover.Node  { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*discover.Node) int { return 0 }
+func (t *resolveMock) SetRecordEntry(enr.Entry) error           { return nil }
+
+func (t *resolveMock) NodeRecord(*discover.Node) (*enr.Record, error) {
+	if t.record == nil {
+		return nil, errors.New("no record")
+	}
+	return t.record, nil
+}
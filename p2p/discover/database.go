@@ -29,6 +29,7 @@ import (
 
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/rlp"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
@@ -54,13 +55,17 @@ type nodeDB struct {
 
 // Schema layout for the node database
 var (
-	nodeDBVersionKey = []byte("version") // Version of the database to flush if changes
-	nodeDBItemPrefix = []byte("n:")      // Identifier to prefix node entries with
+	nodeDBVersionKey  = []byte("version")  // Version of the database to flush if changes
+	nodeDBLocalSeqKey = []byte("localseq") // Sequence number of the local node record
+	nodeDBItemPrefix  = []byte("n:")       // Identifier to prefix node entries with
+	nodeDBBanPrefix   = []byte("ban:")     // Identifier to prefix peer bans with
 
 	nodeDBDiscoverRoot      = ":discover"
 	nodeDBDiscoverPing      = nodeDBDiscoverRoot + ":lastping"
 	nodeDBDiscoverPong      = nodeDBDiscoverRoot + ":lastpong"
 	nodeDBDiscoverFindFails = nodeDBDiscoverRoot + ":findfail"
+	nodeDBDiscoverSeq       = nodeDBDiscoverRoot + ":seq"
+	nodeDBDiscoverRecord    = nodeDBDiscoverRoot + ":enr"
 )
 
 // newNodeDB creates a new node database for storing and retrieving infos about
@@ -138,6 +143,11 @@ func makeKey(id NodeID, field string) []byte {
 	return append(nodeDBItemPrefix, append(id[:], field...)...)
 }
 
+// makeBanKey generates the leveldb key-blob of a ban of a node id or IP.
+func makeBanKey(key string) []byte {
+	return append(append([]byte{}, nodeDBBanPrefix...), key...)
+}
+
 // splitKey tries to split a database key into a node id and a field part.
 func splitKey(key []byte) (id NodeID, field string) {
 	// If the key is not of a node, return it plainly
@@ -303,6 +313,118 @@ func (db *nodeDB) updateFindFails(id NodeID, fails int) error {
 	return db.storeInt64(makeKey(id, nodeDBDiscoverFindFails), int64(fails))
 }
 
+// remoteSeq retrieves the latest node record sequence number announced by a
+// remote node.
+func (db *nodeDB) remoteSeq(id NodeID) uint64 {
+	return uint64(db.fetchInt64(makeKey(id, nodeDBDiscoverSeq)))
+}
+
+// updateRemoteSeq updates the node record sequence number announced by a
+// remote node.
+func (db *nodeDB) updateRemoteSeq(id NodeID, seq uint64) error {
+	return db.storeInt64(makeKey(id, nodeDBDiscoverSeq), int64(seq))
+}
+
+// record retrieves the last node record fetched from a remote node.
+func (db *nodeDB) record(id NodeID) *enr.Record {
+	blob, err := db.lvl.Get(makeKey(id, nodeDBDiscoverRecord), nil)
+	if err != nil {
+		return nil
+	}
+	record := new(enr.Record)
+	if err := rlp.DecodeBytes(blob, record); err != nil {
+		log.Error("Failed to decode node record RLP", "err", err)
+		return nil
+	}
+	return record
+}
+
+// updateRecord stores the node record fetched from a remote node.
+func (db *nodeDB) updateRecord(id NodeID, record *enr.Record) error {
+	blob, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(makeKey(id, nodeDBDiscoverRecord), blob, nil)
+}
+
+// localSeq retrieves the sequence number of the local node record, which must
+// keep increasing across restarts.
+func (db *nodeDB) localSeq() uint64 {
+	return uint64(db.fetchInt64(nodeDBLocalSeqKey))
+}
+
+// storeLocalSeq updates the sequence number of the local node record.
+func (db *nodeDB) storeLocalSeq(seq uint64) error {
+	return db.storeInt64(nodeDBLocalSeqKey, int64(seq))
+}
+
+// bans retrieves all peer bans which haven't expired yet, keyed by the banned
+// node ID or IP. Expired bans are deleted.
+func (db *nodeDB) bans() map[string]time.Time {
+	it := db.lvl.NewIterator(util.BytesPrefix(nodeDBBanPrefix), nil)
+	defer it.Release()
+
+	bans := make(map[string]time.Time)
+	for it.Next() {
+		key := string(it.Key()[len(nodeDBBanPrefix):])
+		until, read := binary.Varint(it.Value())
+		if read <= 0 || time.Now().Unix() >= until {
+			db.lvl.Delete(it.Key(), nil)
+			continue
+		}
+		bans[key] = time.Unix(until, 0)
+	}
+	return bans
+}
+
+// storeBan bans the given node ID or IP until the given time.
+func (db *nodeDB) storeBan(key string, until time.Time) error {
+	return db.storeInt64(makeBanKey(key), until.Unix())
+}
+
+// deleteBan lifts the ban of the given node ID or IP.
+func (db *nodeDB) deleteBan(key string) error {
+	return db.lvl.Delete(makeBanKey(key), nil)
+}
+
+// BanDB persists peer bans in the node database. It is used by servers which
+// don't run a discovery table on top of the database.
+type BanDB struct {
+	db *nodeDB
+}
+
+// OpenBanDB opens the node database at the given path to store peer bans in. If
+// no path is given, an in-memory, temporary database is constructed.
+func OpenBanDB(path string, self NodeID) (*BanDB, error) {
+	db, err := newNodeDB(path, Version, self)
+	if err != nil {
+		return nil, err
+	}
+	return &BanDB{db: db}, nil
+}
+
+// Bans returns the peer bans stored in the node database which haven't expired
+// yet, keyed by the banned node ID or IP.
+func (b *BanDB) Bans() map[string]time.Time {
+	return b.db.bans()
+}
+
+// StoreBan persists a peer ban of the given node ID or IP in the node database.
+func (b *BanDB) StoreBan(key string, until time.Time) error {
+	return b.db.storeBan(key, until)
+}
+
+// DeleteBan removes a peer ban from the node database.
+func (b *BanDB) DeleteBan(key string) error {
+	return b.db.deleteBan(key)
+}
+
+// Close flushes and closes the database files.
+func (b *BanDB) Close() {
+	b.db.close()
+}
+
 // querySeeds retrieves random nodes to be used as potential seed nodes
 // for booecrapping.
 func (db *nodeDB) querySeeds(n int, maxAge time.Duration) []*Node {
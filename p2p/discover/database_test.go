@@ -378,3 +378,30 @@ func TestNodeDBSelfExpiration(t *testing.T) {
 		t.Errorf("self not evacuated")
 	}
 }
+
+func TestNodeDBBans(t *testing.T) {
+	db, _ := newNodeDB("", Version, NodeID{})
+	defer db.close()
+
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := db.storeBan("10.0.0.1", until); err != nil {
+		t.Fatalf("failed to store ban: %v", err)
+	}
+	if err := db.storeBan("10.0.0.2", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("failed to store ban: %v", err)
+	}
+	// Make sure the bans don't interfere with node expiration
+	if err := db.expireNodes(); err != nil {
+		t.Fatalf("failed to expire nodes: %v", err)
+	}
+	bans := db.bans()
+	if len(bans) != 1 || !bans["10.0.0.1"].Equal(until) {
+		t.Fatalf("bans mismatch: have %v, want 10.0.0.1 until %v", bans, until)
+	}
+	if err := db.deleteBan("10.0.0.1"); err != nil {
+		t.Fatalf("failed to delete ban: %v", err)
+	}
+	if bans := db.bans(); len(bans) != 0 {
+		t.Errorf("bans left after deletion: %v", bans)
+	}
+}
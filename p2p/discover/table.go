@@ -36,6 +36,7 @@ import (
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 )
 
@@ -101,6 +102,8 @@ type transport interface {
 	ping(NodeID, *net.UDPAddr) error
 	waitping(NodeID) error
 	findnode(toid NodeID, addr *net.UDPAddr, target NodeID) ([]*Node, error)
+	requestENR(toid NodeID, addr *net.UDPAddr) (*enr.Record, error)
+	setRecordEntry(e enr.Entry) error
 	close()
 }
 
@@ -270,6 +273,50 @@ func (tab *Table) Resolve(targetID NodeID) *Node {
 	return nil
 }
 
+// NodeRecord retrieves the signed node record of the given node. The record is
+// served from the node database if the node didn't announce a newer version
+// since it was fetched, otherwise it is requested from the node.
+func (tab *Table) NodeRecord(n *Node) (*enr.Record, error) {
+	if record := tab.db.record(n.ID); record != nil && record.Seq() >= tab.db.remoteSeq(n.ID) {
+		return record, nil
+	}
+	// Records are only served to bonded nodes
+	if _, err := tab.bond(false, n.ID, n.addr(), n.TCP); err != nil {
+		return nil, err
+	}
+	record, err := tab.net.requestENR(n.ID, n.addr())
+	if err != nil {
+		return nil, err
+	}
+	tab.db.updateRecord(n.ID, record)
+	if record.Seq() > tab.db.remoteSeq(n.ID) {
+		tab.db.updateRemoteSeq(n.ID, record.Seq())
+	}
+	return record, nil
+}
+
+// SetRecordEntry adds or replaces an entry of the local node record. Remote
+// nodes pick up the new record as the sequence number is announced in pings.
+func (tab *Table) SetRecordEntry(e enr.Entry) error {
+	return tab.net.setRecordEntry(e)
+}
+
+// Bans returns the peer bans stored in the node database which haven't expired
+// yet, keyed by the banned node ID or IP.
+func (tab *Table) Bans() map[string]time.Time {
+	return tab.db.bans()
+}
+
+// StoreBan persists a peer ban of the given node ID or IP in the node database.
+func (tab *Table) StoreBan(key string, until time.Time) error {
+	return tab.db.storeBan(key, until)
+}
+
+// DeleteBan removes a peer ban from the node database.
+func (tab *Table) DeleteBan(key string) error {
+	return tab.db.deleteBan(key)
+}
+
 // Lookup performs a network search for nodes close
 // to the given target. It approaches the target by querying
 // nodes that are closer to it on each iteration.
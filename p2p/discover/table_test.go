@@ -30,6 +30,7 @@ import (
 
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 )
 
 func TestTable_pingReplace(t *testing.T) {
@@ -206,7 +207,11 @@ func newPingRecorder() *pingRecorder {
 func (t *pingRecorder) findnode(toid NodeID, toaddr *net.UDPAddr, target NodeID) ([]*Node, error) {
 	return nil, nil
 }
-func (t *pingRecorder) close() {}
+func (t *pingRecorder) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errTimeout
+}
+func (t *pingRecorder) setRecordEntry(e enr.Entry) error { return nil }
+func (t *pingRecorder) close()                           {}
 func (t *pingRecorder) waitping(from NodeID) error {
 	return nil // remote always pings
 }
@@ -579,6 +584,11 @@ func (tn *preminedTestnet) findnode(toid NodeID, toaddr *net.UDPAddr, target Nod
 func (*preminedTestnet) close()                                      {}
 func (*preminedTestnet) waitping(from NodeID) error                  { return nil }
 func (*preminedTestnet) ping(toid NodeID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) setRecordEntry(e enr.Entry) error            { return nil }
+
+func (*preminedTestnet) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	return nil, errTimeout
+}
 
 // mine generates a testnet struct literal with nodes at
 // various distances to the given target.
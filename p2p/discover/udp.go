@@ -23,10 +23,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/ecchain/go-ecchain/crypto"
 	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/p2p/nat"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 	"github.com/ecchain/go-ecchain/rlp"
@@ -44,6 +46,7 @@ var (
 	errTimeout          = errors.New("RPC timeout")
 	errClockWarp        = errors.New("reply deadline too far in the future")
 	errClosed           = errors.New("socket closed")
+	errRecordMismatch   = errors.New("node record doesn't match node ID")
 )
 
 // Timeouts
@@ -63,6 +66,8 @@ const (
 	pongPacket
 	findnodePacket
 	neighborsPacket
+	enrRequestPacket
+	enrResponsePacket
 )
 
 // RPC request structures
@@ -104,6 +109,21 @@ type (
 		Rest []rlp.RawValue `rlp:"tail"`
 	}
 
+	// enrRequest is a query for the node record of the recipient.
+	enrRequest struct {
+		Expiration uint64
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
+	// enrResponse is the reply to enrRequest.
+	enrResponse struct {
+		ReplyTok []byte // Hash of the enrRequest packet.
+		Record   enr.Record
+		// Ignore additional fields (for forward compatibility).
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+
 	rpcNode struct {
 		IP  net.IP // len 4 for IPv4 or 16 for IPv6
 		UDP uint16 // for discovery protocol
@@ -126,6 +146,23 @@ func makeEndpoint(addr *net.UDPAddr, tcpPort uint16) rpcEndpoint {
 	return rpcEndpoint{IP: ip, UDP: uint16(addr.Port), TCP: tcpPort}
 }
 
+// enrSeqField encodes the local node record sequence number as the first of the
+// additional ping and pong fields, which older nodes ignore.
+func enrSeqField(seq uint64) []rlp.RawValue {
+	blob, _ := rlp.EncodeToBytes(seq)
+	return []rlp.RawValue{blob}
+}
+
+// enrSeq retrieves the node record sequence number from the additional ping
+// or pong fields, returning zero if the sender didn't announce one.
+func enrSeq(rest []rlp.RawValue) uint64 {
+	var seq uint64
+	if len(rest) == 0 || rlp.DecodeBytes(rest[0], &seq) != nil {
+		return 0
+	}
+	return seq
+}
+
 func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*Node, error) {
 	if rn.UDP <= 1024 {
 		return nil, errors.New("low port")
@@ -170,6 +207,11 @@ type udp struct {
 	closing chan struct{}
 	nat     nat.Interface
 
+	recordMu sync.RWMutex
+	record   *enr.Record  // signed record of the local node
+	entries  []enr.Entry  // additional entries of the local record
+	realaddr *net.UDPAddr // address announced in the local record
+
 	*Table
 }
 
@@ -227,6 +269,7 @@ type Config struct {
 	NetRestrict  *netutil.Netlist  // network whitelist
 	Bootnodes    []*Node           // list of booecrap nodes
 	Unhandled    chan<- ReadPacket // unhandled packets are sent on this channel
+	Entries      []enr.Entry       // additional entries of the local node record
 }
 
 // ListenUDP returns a new table that listens for UDP packets on laddr.
@@ -260,6 +303,16 @@ func newUDP(c conn, cfg Config) (*Table, *udp, error) {
 	}
 	udp.Table = tab
 
+	udp.recordMu.Lock()
+	udp.realaddr = realaddr
+	udp.entries = append(udp.entries, cfg.Entries...)
+	err = udp.updateRecord()
+	udp.recordMu.Unlock()
+	if err != nil {
+		tab.Close()
+		return nil, nil, err
+	}
+
 	go udp.loop()
 	go udp.readLoop(cfg.Unhandled)
 	return udp.Table, udp, nil
@@ -271,6 +324,65 @@ func (t *udp) close() {
 	// TODO: wait for the loops to end.
 }
 
+// updateRecord signs a new version of the local node record with the current
+// endpoint and entries. The sequence number is persisted, so remote nodes can
+// tell the record changed even across restarts. The caller must hold recordMu.
+func (t *udp) updateRecord() error {
+	record := new(enr.Record)
+	if ip := t.realaddr.IP.To4(); ip != nil && !ip.IsUnspecified() {
+		record.Set(enr.IP4(ip))
+	} else if ip := t.realaddr.IP.To16(); ip != nil && !ip.IsUnspecified() {
+		record.Set(enr.IP6(ip))
+	}
+	record.Set(enr.UDP(t.realaddr.Port))
+	record.Set(enr.TCP(t.ourEndpoint.TCP))
+	for _, e := range t.entries {
+		record.Set(e)
+	}
+	record.SetSeq(t.db.localSeq())
+	if err := record.Sign(t.priv); err != nil {
+		return err
+	}
+	if err := t.db.storeLocalSeq(record.Seq()); err != nil {
+		return err
+	}
+	t.record = record
+	return nil
+}
+
+// localRecord returns the signed record of the local node.
+func (t *udp) localRecord() *enr.Record {
+	t.recordMu.RLock()
+	defer t.recordMu.RUnlock()
+
+	return t.record
+}
+
+// localSeq returns the sequence number of the local node record, or zero if the
+// record isn't signed yet.
+func (t *udp) localSeq() uint64 {
+	if record := t.localRecord(); record != nil {
+		return record.Seq()
+	}
+	return 0
+}
+
+// setRecordEntry adds or replaces an entry of the local node record, signing a
+// new version of it.
+func (t *udp) setRecordEntry(e enr.Entry) error {
+	t.recordMu.Lock()
+	defer t.recordMu.Unlock()
+
+	for i, have := range t.entries {
+		if have.ENRKey() == e.ENRKey() {
+			t.entries[i] = e
+			return t.updateRecord()
+		}
+	}
+	t.entries = append(t.entries, e)
+	return t.updateRecord()
+}
+
 // ping sends a ping message to the given node and waits for a reply.
 func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
 	req := &ping{
@@ -278,6 +390,7 @@ func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
 		From:       t.ourEndpoint,
 		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       enrSeqField(t.localSeq()),
 	}
 	packet, hash, err := encodePacket(t.priv, pingPacket, req)
 	if err != nil {
@@ -290,6 +403,39 @@ func (t *udp) ping(toid NodeID, toaddr *net.UDPAddr) error {
 	return <-errc
 }
 
+// requestENR sends an enrRequest to the given node and waits for its signed
+// node record.
+func (t *udp) requestENR(toid NodeID, toaddr *net.UDPAddr) (*enr.Record, error) {
+	req := &enrRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := encodePacket(t.priv, enrRequestPacket, req)
+	if err != nil {
+		return nil, err
+	}
+	var record *enr.Record
+	errc := t.pending(toid, enrResponsePacket, func(p interface{}) bool {
+		reply := p.(*enrResponse)
+		if !bytes.Equal(reply.ReplyTok, hash) {
+			return false
+		}
+		record = &reply.Record
+		return true
+	})
+	t.write(toaddr, req.name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	var pubkey enr.Secp256k1
+	if err := record.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != toid {
+		return nil, errRecordMismatch
+	}
+	return record, nil
+}
+
 func (t *udp) waitping(from NodeID) error {
 	return <-t.pending(from, pingPacket, func(interface{}) bool { return true })
 }
@@ -571,6 +717,10 @@ func decodePacket(buf []byte) (packet, NodeID, []byte, error) {
 		req = new(findnode)
 	case neighborsPacket:
 		req = new(neighbors)
+	case enrRequestPacket:
+		req = new(enrRequest)
+	case enrResponsePacket:
+		req = new(enrResponse)
 	default:
 		return nil, fromID, hash, fmt.Errorf("unknown type: %d", ptype)
 	}
@@ -587,7 +737,11 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 		To:         makeEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       enrSeqField(t.localSeq()),
 	})
+	if seq := enrSeq(req.Rest); seq > 0 {
+		t.db.updateRemoteSeq(fromID, seq)
+	}
 	if !t.handleReply(fromID, pingPacket, req) {
 		// Note: we're ignoring the provided IP address right now
 		go t.bond(true, fromID, from, req.From.TCP)
@@ -604,6 +758,9 @@ func (req *pong) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) er
 	if !t.handleReply(fromID, pongPacket, req) {
 		return errUnsolicitedReply
 	}
+	if seq := enrSeq(req.Rest); seq > 0 {
+		t.db.updateRemoteSeq(fromID, seq)
+	}
 	return nil
 }
 
@@ -662,6 +819,33 @@ func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byt
 
 func (req *neighbors) name() string { return "NEIGHBORS/v4" }
 
+func (req *enrRequest) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.db.hasBond(fromID) {
+		// Records are larger than the request, so the same amplification
+		// concerns as for findnode apply.
+		return errUnknownNode
+	}
+	t.send(from, enrResponsePacket, &enrResponse{
+		ReplyTok: mac,
+		Record:   *t.localRecord(),
+	})
+	return nil
+}
+
+func (req *enrRequest) name() string { return "ENRREQUEST/v4" }
+
+func (req *enrResponse) handle(t *udp, from *net.UDPAddr, fromID NodeID, mac []byte) error {
+	if !t.handleReply(fromID, enrResponsePacket, req) {
+		return errUnsolicitedReply
+	}
+	return nil
+}
+
+func (req *enrResponse) name() string { return "ENRRESPONSE/v4" }
+
 func expired(ts uint64) bool {
 	return time.Unix(int64(ts), 0).Before(time.Now())
 }
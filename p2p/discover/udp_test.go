@@ -36,6 +36,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/rlp"
 )
 
@@ -321,6 +322,83 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 	}
 }
 
+func TestUDP_enrRequest(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	// unbonded nodes don't get the record.
+	test.packetIn(errUnknownNode, enrRequestPacket, &enrRequest{Expiration: futureExp})
+
+	// bonded nodes do, signed with the local key.
+	test.table.db.updateBondTime(PubkeyID(&test.remotekey.PublicKey), time.Now())
+	test.packetIn(nil, enrRequestPacket, &enrRequest{Expiration: futureExp})
+	hash := test.sent[len(test.sent)-1][:macSize]
+	test.waitPacketOut(func(p *enrResponse) {
+		if !bytes.Equal(p.ReplyTok, hash) {
+			t.Errorf("reply token mismatch: got %x, want %x", p.ReplyTok, hash)
+		}
+		var pubkey enr.Secp256k1
+		if err := p.Record.Load(&pubkey); err != nil {
+			t.Fatalf("can't load public key: %v", err)
+		}
+		if PubkeyID((*ecdsa.PublicKey)(&pubkey)) != test.table.self.ID {
+			t.Errorf("record signed by wrong key")
+		}
+		if p.Record.Seq() != test.udp.localSeq() {
+			t.Errorf("record sequence mismatch: got %d, want %d", p.Record.Seq(), test.udp.localSeq())
+		}
+	})
+	// updating an entry increases the sequence number announced in pongs.
+	seq := test.udp.localSeq()
+	if err := test.table.SetRecordEntry(enr.WithEntry("test", uint(1))); err != nil {
+		t.Fatalf("can't set record entry: %v", err)
+	}
+	test.packetIn(nil, pingPacket, &ping{From: testRemote, To: testLocalAnnounced, Version: Version, Expiration: futureExp})
+	test.waitPacketOut(func(p *pong) {
+		if have := enrSeq(p.Rest); have != seq+1 {
+			t.Errorf("announced sequence mismatch: got %d, want %d", have, seq+1)
+		}
+	})
+}
+
+func TestUDP_requestENR(t *testing.T) {
+	test := newUDPTest(t)
+	defer test.table.Close()
+
+	for _, key := range []*ecdsa.PrivateKey{test.remotekey, newkey()} {
+		record := new(enr.Record)
+		if err := record.Sign(key); err != nil {
+			t.Fatalf("can't sign record: %v", err)
+		}
+		resultc, errc := make(chan *enr.Record, 1), make(chan error, 1)
+		go func() {
+			rid := PubkeyID(&test.remotekey.PublicKey)
+			if record, err := test.udp.requestENR(rid, test.remoteaddr); err != nil {
+				errc <- err
+			} else {
+				resultc <- record
+			}
+		}()
+		hash, _ := test.waitPacketOut(func(p *enrRequest) {})
+		test.packetIn(nil, enrResponsePacket, &enrResponse{ReplyTok: hash, Record: *record})
+
+		select {
+		case result := <-resultc:
+			if key != test.remotekey {
+				t.Errorf("record signed by other node accepted")
+			} else if result.Seq() != record.Seq() {
+				t.Errorf("record mismatch: got seq %d, want %d", result.Seq(), record.Seq())
+			}
+		case err := <-errc:
+			if key == test.remotekey || err != errRecordMismatch {
+				t.Errorf("requestENR error: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("requestENR did not return within 5 seconds")
+		}
+	}
+}
+
 func TestUDP_successfulPing(t *testing.T) {
 	test := newUDPTest(t)
 	added := make(chan *Node, 1)
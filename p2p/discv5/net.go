@@ -567,7 +567,7 @@ loop:
 			}
 			net.tickeecore.searchLookupDone(res.target, res.nodes, func(n *Node, topic Topic) []byte {
 				if n.state != nil && n.state.canQuery {
-					return net.conn.send(n, topicQueryPacket, topicQuery{Topic: topic}) // TODO: set expiration
+					return net.conn.send(n, topicQueryPacket, &topicQuery{Topic: topic}) // TODO: set expiration
 				} else {
 					if n.state == unknown {
 						net.ping(n, n.addr())
@@ -186,6 +186,59 @@ func TestSimTopicHierarchy(t *testing.T) {
 	sim.shutdown()
 }
 
+// In this test, a few nodes advertise a topic and another node searches for them,
+// like a service finding its peers through the node API.
+func TestSimTopicSearch(t *testing.T) {
+	sim := newSimulation()
+	defer sim.shutdown()
+
+	_, nets := sim.launchNodes(16, 100*time.Millisecond)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	advertisers := nets[:4]
+	for _, net := range advertisers {
+		go net.RegisterTopic(testTopic, stop)
+	}
+	if missing := searchTopicNodes(nets[len(nets)-1], testTopic, advertisers, time.Minute); len(missing) > 0 {
+		t.Errorf("%d of %d advertising nodes not found", len(missing), len(advertisers))
+		for _, id := range missing {
+			t.Logf("missing %x", id[:8])
+		}
+	}
+}
+
+// searchTopicNodes searches the topic from the given node until all wanted
+// nodes are found or the timeout expires, returning the IDs of those not found.
+func searchTopicNodes(net *Network, topic Topic, want []*Network, timeout time.Duration) []NodeID {
+	missing := make(map[NodeID]bool)
+	for _, n := range want {
+		missing[n.Self().ID] = true
+	}
+	var (
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *Node, 100)
+		deadline  = time.After(timeout)
+	)
+	setPeriod <- time.Second
+	go net.SearchTopic(topic, setPeriod, found, nil)
+	defer close(setPeriod)
+
+	for len(missing) > 0 {
+		select {
+		case n := <-found:
+			delete(missing, n.ID)
+		case <-deadline:
+			ids := make([]NodeID, 0, len(missing))
+			for id := range missing {
+				ids = append(ids, id)
+			}
+			return ids
+		}
+	}
+	return nil
+}
+
 func randomResolves(t *testing.T, s *simulation, net *Network) {
 	randtime := func() time.Duration {
 		return time.Duration(rand.Intn(50)+20) * time.Second
@@ -294,6 +347,21 @@ func (s *simulation) launchNode(log bool) *Network {
 	return net
 }
 
+// launchNodes launches a bootnode and n nodes joining the network through it,
+// one every interval.
+func (s *simulation) launchNodes(n int, interval time.Duration) (*Network, []*Network) {
+	bootnode := s.launchNode(false)
+	nets := make([]*Network, n)
+	for i := range nets {
+		nets[i] = s.launchNode(false)
+		if err := nets[i].SetFallbackNodes([]*Node{bootnode.Self()}); err != nil {
+			panic(err)
+		}
+		time.Sleep(interval)
+	}
+	return bootnode, nets
+}
+
 func (s *simulation) dropNode(id NodeID) {
 	s.mu.Lock()
 	n := s.nodes[id]
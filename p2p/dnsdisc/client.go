@@ -0,0 +1,395 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via DNS (EIP-1459). Node lists are
+// published as merkle trees of signed node records in DNS TXT records, which
+// the client resolves, verifies and serves as dial candidates.
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	maxLinkDepth = 8 // Maximum number of links followed from a configured tree
+)
+
+var (
+	errNoRoot       = errors.New("no valid root found")
+	errHashMismatch = errors.New("hash mismatch")
+	errNoEntry      = errors.New("no valid tree entry found")
+	errRootEntry    = errors.New("root entry in place of a tree node")
+)
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// Config holds the settings of a DNS discovery client.
+type Config struct {
+	Timeout         time.Duration // Timeout of a single DNS query (default 5s)
+	RecheckInterval time.Duration // Time between checks for tree updates (default 30min)
+	CacheLimit      int           // Maximum number of cached tree entries (default 1000)
+	Resolver        Resolver      // DNS resolver to use (default net.DefaultResolver)
+	Logger          log.Logger    // Logger to use (default log.Root())
+}
+
+// withDefaults fills in the unset fields of the configuration.
+func (cfg Config) withDefaults() Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = 30 * time.Minute
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = 1000
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// Client resolves and verifies node lists published in DNS. Once started, it
+// keeps the nodes of the added trees (and the trees they link to) in sync and
+// serves them as dial candidates.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache // Verified tree entries by subdomain
+
+	lock  sync.Mutex
+	urls  []string              // Configured trees, synced in the background
+	trees map[string]*syncState // Last successful sync per tree domain
+	nodes []*discover.Node      // Nodes of all synced trees
+	rand  *mrand.Rand
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// syncState is the result of the last successful sync of a tree.
+type syncState struct {
+	root  string           // Root entry the tree was synced at
+	nodes []*discover.Node // Dialable nodes of the tree
+	links []string         // Trees linked from the tree
+}
+
+// NewClient creates a DNS discovery client.
+func NewClient(cfg Config) *Client {
+	cfg = cfg.withDefaults()
+	cache, err := lru.New(cfg.CacheLimit)
+	if err != nil {
+		panic(err)
+	}
+	return &Client{
+		cfg:     cfg,
+		entries: cache,
+		trees:   make(map[string]*syncState),
+		rand:    mrand.New(mrand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// AddTree adds a tree to sync in the background, given its enrtree URL.
+func (c *Client) AddTree(url string) error {
+	if _, err := parseLink(url); err != nil {
+		return fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.urls = append(c.urls, url)
+	return nil
+}
+
+// Start launches the background sync of the added trees.
+func (c *Client) Start() {
+	c.quit = make(chan struct{})
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// Stop terminates the background sync.
+func (c *Client) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// ReadRandomNodes fills the given slice with random nodes of the synced trees,
+// returning the number of nodes written.
+func (c *Client) ReadRandomNodes(buf []*discover.Node) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.nodes) == 0 {
+		return 0
+	}
+	perm := c.rand.Perm(len(c.nodes))
+	n := 0
+	for ; n < len(buf) && n < len(perm); n++ {
+		buf[n] = c.nodes[perm[n]]
+	}
+	return n
+}
+
+// loop periodically syncs all added trees.
+func (c *Client) loop() {
+	defer c.wg.Done()
+
+	recheck := time.NewTimer(0)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-recheck.C:
+			c.syncAll()
+			recheck.Reset(c.cfg.RecheckInterval)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// syncAll syncs all added trees and the trees they link to, replacing the set
+// of dial candidates. Trees failing to sync keep their previous nodes.
+func (c *Client) syncAll() {
+	c.lock.Lock()
+	urls := append([]string{}, c.urls...)
+	c.lock.Unlock()
+
+	var (
+		visited = make(map[string]bool)
+		nodes   []*discover.Node
+	)
+	for _, url := range urls {
+		nodes = c.syncLinked(url, visited, 0, nodes)
+	}
+	c.lock.Lock()
+	c.nodes = nodes
+	c.lock.Unlock()
+}
+
+// syncLinked syncs a tree and the trees linked from it, appending their nodes
+// to the given slice.
+func (c *Client) syncLinked(url string, visited map[string]bool, depth int, nodes []*discover.Node) []*discover.Node {
+	if visited[url] || depth > maxLinkDepth {
+		return nodes
+	}
+	visited[url] = true
+
+	found, links := c.syncNodes(url)
+	nodes = append(nodes, found...)
+	for _, link := range links {
+		nodes = c.syncLinked(link, visited, depth+1, nodes)
+	}
+	return nodes
+}
+
+// syncNodes syncs a single tree, returning its nodes and links. If the sync
+// fails, the results of the last successful sync are returned.
+func (c *Client) syncNodes(url string) ([]*discover.Node, []string) {
+	loc, err := parseLink(url)
+	if err != nil {
+		return nil, nil
+	}
+	c.lock.Lock()
+	last := c.trees[loc.domain]
+	c.lock.Unlock()
+	if last == nil {
+		last = new(syncState)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	root, err := c.resolveRoot(ctx, loc)
+	cancel()
+	if err != nil {
+		c.cfg.Logger.Debug("Failed to resolve DNS tree root", "tree", loc.domain, "err", err)
+		return last.nodes, last.links
+	}
+	tree, err := c.syncTree(loc, root)
+	if err != nil {
+		c.cfg.Logger.Debug("Failed to sync DNS tree", "tree", loc.domain, "err", err)
+		return last.nodes, last.links
+	}
+	var nodes []*discover.Node
+	for _, record := range tree.Nodes() {
+		n, err := nodeFromRecord(record)
+		if err != nil {
+			c.cfg.Logger.Trace("Skipping invalid DNS tree node", "tree", loc.domain, "err", err)
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	if root.String() != last.root {
+		c.cfg.Logger.Info("Synced DNS node list", "tree", loc.domain, "seq", root.seq, "nodes", len(nodes))
+	}
+	state := &syncState{root: root.String(), nodes: nodes, links: tree.Links()}
+	c.lock.Lock()
+	c.trees[loc.domain] = state
+	c.lock.Unlock()
+
+	return state.nodes, state.links
+}
+
+// SyncTree downloads and verifies the complete tree at the given URL. Linked
+// trees are not followed, they are returned by the tree's Links method.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	loc, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	root, err := c.resolveRoot(ctx, loc)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	return c.syncTree(loc, root)
+}
+
+// syncTree retrieves all entries below a verified root.
+func (c *Client) syncTree(loc *linkEntry, root rootEntry) (*Tree, error) {
+	t := &Tree{root: &root, entries: make(map[string]entry)}
+	for _, hash := range []string{root.eroot, root.lroot} {
+		if err := c.syncSubtree(loc.domain, hash, t.entries); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// syncSubtree retrieves the entry with the given hash and all entries below it.
+func (c *Client) syncSubtree(domain, hash string, entries map[string]entry) error {
+	pending := []string{hash}
+	for len(pending) > 0 {
+		hash, pending = pending[0], pending[1:]
+		if _, ok := entries[hash]; ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+		e, err := c.resolveEntry(ctx, domain, hash)
+		cancel()
+		if err != nil {
+			return err
+		}
+		entries[hash] = e
+		if branch, ok := e.(*branchEntry); ok {
+			pending = append(pending, branch.children...)
+		}
+	}
+	return nil
+}
+
+// resolveRoot retrieves the root of a tree and verifies its signature.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (rootEntry, error) {
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Resolving DNS tree root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			root, err := parseRoot(txt)
+			if err != nil {
+				return rootEntry{}, err
+			}
+			if !root.verifySignature(loc.pubkey) {
+				return rootEntry{}, entryError{"root", errInvalidSig}
+			}
+			return root, nil
+		}
+	}
+	return rootEntry{}, errNoRoot
+}
+
+// resolveEntry retrieves a tree entry from the cache or DNS, verifying that its
+// contents match the hash it was requested by.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	cacheKey := hash + "." + domain
+	if e, ok := c.entries.Get(cacheKey); ok {
+		return e.(entry), nil
+	}
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, cacheKey)
+	c.cfg.Logger.Trace("Resolving DNS tree entry", "name", cacheKey, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			return nil, errRootEntry
+		}
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(subdomain(e), hash) {
+			return nil, errHashMismatch
+		}
+		c.entries.Add(cacheKey, e)
+		return e, nil
+	}
+	return nil, errNoEntry
+}
+
+// nodeFromRecord converts a node record into a dialable node.
+func nodeFromRecord(record *enr.Record) (*discover.Node, error) {
+	var (
+		pubkey enr.Secp256k1
+		ip     net.IP
+		tcp    enr.TCP
+		udp    enr.UDP
+	)
+	if err := record.Load(&pubkey); err != nil {
+		return nil, err
+	}
+	var ip4 enr.IP4
+	if err := record.Load(&ip4); err == nil {
+		ip = net.IP(ip4)
+	} else {
+		var ip6 enr.IP6
+		if err := record.Load(&ip6); err != nil {
+			return nil, errors.New("no IP address")
+		}
+		ip = net.IP(ip6)
+	}
+	if err := record.Load(&tcp); err != nil {
+		return nil, err
+	}
+	if err := record.Load(&udp); err != nil {
+		udp = enr.UDP(tcp)
+	}
+	id := discover.PubkeyID((*ecdsa.PublicKey)(&pubkey))
+	return discover.NewNode(id, ip, uint16(udp), uint16(tcp)), nil
+}
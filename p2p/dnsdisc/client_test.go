@@ -0,0 +1,209 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
+)
+
+// mapResolver is a DNS resolver serving TXT records from a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// testNodes creates signed node records for the given number of random keys.
+func testNodes(t *testing.T, n int) ([]*enr.Record, []*ecdsa.PrivateKey) {
+	records := make([]*enr.Record, n)
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range records {
+		keys[i], _ = crypto.GenerateKey()
+		records[i] = new(enr.Record)
+		records[i].Set(enr.IP4(net.IP{127, 0, 0, byte(i)}))
+		records[i].Set(enr.TCP(30303))
+		if err := records[i].Sign(keys[i]); err != nil {
+			t.Fatalf("failed to sign record: %v", err)
+		}
+	}
+	return records, keys
+}
+
+// testTree creates a tree of the given records signed by the given key, and
+// the resolver serving it at the given domain.
+func testTree(t *testing.T, key *ecdsa.PrivateKey, domain string, records []*enr.Record, links []string) (string, mapResolver) {
+	tree, err := MakeTree(1, records, links)
+	if err != nil {
+		t.Fatalf("failed to make tree: %v", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		t.Fatalf("failed to sign tree: %v", err)
+	}
+	return url, mapResolver(tree.ToTXT(domain))
+}
+
+// Tests that a signed tree can be synced and yields the original contents.
+func TestClientSyncTree(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	records, _ := testNodes(t, 40) // enough for multiple branch levels
+	link := newLinkEntry("other.example.org", &key.PublicKey).String()
+	url, resolver := testTree(t, key, "nodes.example.org", records, []string{link})
+
+	c := NewClient(Config{Resolver: resolver})
+	tree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if tree.Seq() != 1 {
+		t.Errorf("sequence mismatch: have %d, want 1", tree.Seq())
+	}
+	if links := tree.Links(); !reflect.DeepEqual(links, []string{link}) {
+		t.Errorf("links mismatch: have %v, want %v", links, []string{link})
+	}
+	have, want := recordStrings(tree.Nodes()), recordStrings(records)
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("nodes mismatch:\nhave %v\nwant %v", have, want)
+	}
+}
+
+// Tests that trees with tampered entries or roots are rejected.
+func TestClientSyncTampered(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	records, _ := testNodes(t, 5)
+	url, resolver := testTree(t, key, "nodes.example.org", records, nil)
+
+	// Replace one of the records, keeping the name of its TXT record
+	other, _ := testNodes(t, 1)
+	for name, txt := range resolver {
+		if strings.HasPrefix(txt, enrPrefix) {
+			resolver[name] = (&enrEntry{other[0]}).String()
+			break
+		}
+	}
+	c := NewClient(Config{Resolver: resolver})
+	if _, err := c.SyncTree(url); err != errHashMismatch {
+		t.Errorf("tampered entry error mismatch: have %v, want %v", err, errHashMismatch)
+	}
+	// Sync a valid tree with the wrong key
+	url, resolver = testTree(t, key, "nodes.example.org", records, nil)
+	wrong, _ := crypto.GenerateKey()
+	url = newLinkEntry("nodes.example.org", &wrong.PublicKey).String()
+
+	c = NewClient(Config{Resolver: resolver})
+	if _, err := c.SyncTree(url); err == nil {
+		t.Errorf("tree signed by other key accepted")
+	}
+}
+
+// Tests that the background sync follows links and serves the nodes of all
+// trees as dial candidates.
+func TestClientReadRandomNodes(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	records, keys := testNodes(t, 6)
+
+	linked, resolver := testTree(t, key, "linked.example.org", records[3:], nil)
+	url, primary := testTree(t, key, "nodes.example.org", records[:3], []string{linked})
+	for name, txt := range primary {
+		resolver[name] = txt
+	}
+	c := NewClient(Config{Resolver: resolver})
+	if err := c.AddTree(url); err != nil {
+		t.Fatalf("failed to add tree: %v", err)
+	}
+	c.syncAll()
+
+	buf := make([]*discover.Node, 10)
+	n := c.ReadRandomNodes(buf)
+	if n != len(records) {
+		t.Fatalf("node count mismatch: have %d, want %d", n, len(records))
+	}
+	want := make(map[discover.NodeID]bool)
+	for _, key := range keys {
+		want[discover.PubkeyID(&key.PublicKey)] = true
+	}
+	for _, node := range buf[:n] {
+		if !want[node.ID] {
+			t.Errorf("unexpected node %x", node.ID[:8])
+		}
+		if node.TCP != 30303 || node.UDP != 30303 {
+			t.Errorf("node %x: port mismatch: tcp %d, udp %d", node.ID[:8], node.TCP, node.UDP)
+		}
+		delete(want, node.ID)
+	}
+	// Failing syncs keep the previously synced nodes
+	for name := range resolver {
+		delete(resolver, name)
+	}
+	c.syncAll()
+	if n := c.ReadRandomNodes(buf); n != len(records) {
+		t.Errorf("node count mismatch after failed sync: have %d, want %d", n, len(records))
+	}
+}
+
+// Tests the parsing of tree entries.
+func TestParseEntry(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	link := newLinkEntry("nodes.example.org", &key.PublicKey)
+
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{input: link.String()},
+		{input: branchPrefix},
+		{input: branchPrefix + "AAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBB"},
+		{input: branchPrefix + "AAAAAAAAAAAAAAAAAAAA,!", err: entryError{"branch", errInvalidChild}},
+		{input: enrPrefix + "!!!", err: entryError{"enr", errInvalidENR}},
+		{input: linkPrefix + "nodes.example.org", err: entryError{"link", errNoPubkey}},
+		{input: linkPrefix + "AAAA@nodes.example.org", err: entryError{"link", errBadPubkey}},
+		{input: "foo", err: errUnknownEntry},
+	}
+	for i, tt := range tests {
+		e, err := parseEntry(tt.input)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err == nil && e.String() != tt.input {
+			t.Errorf("test %d: encoding mismatch: have %q, want %q", i, e.String(), tt.input)
+		}
+	}
+}
+
+func recordStrings(records []*enr.Record) []string {
+	strs := make([]string, len(records))
+	for i, r := range records {
+		strs[i] = (&enrEntry{r}).String()
+	}
+	sort.Strings(strs)
+	return strs
+}
@@ -29,6 +29,12 @@ var (
 	ingressTrafficMeter = metrics.NewRegisteredMeter("p2p/InboundTraffic", nil)
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/OutboundConnects", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter("p2p/OutboundTraffic", nil)
+
+	// Payload sizes of snappy compressed messages, before and after compression
+	ingressCompressedMeter = metrics.NewRegisteredMeter("p2p/InboundCompressed", nil)
+	ingressRawMeter        = metrics.NewRegisteredMeter("p2p/InboundRaw", nil)
+	egressCompressedMeter  = metrics.NewRegisteredMeter("p2p/OutboundCompressed", nil)
+	egressRawMeter         = metrics.NewRegisteredMeter("p2p/OutboundRaw", nil)
 )
 
 // meteredConn is a wrapper around a network TCP connection that meters both the
@@ -109,6 +109,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// rep tracks the penalties of misbehaving peers, nil for test peers
+	rep *reputation
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -141,6 +144,14 @@ func (p *Peer) RemoteAddr() net.Addr {
 	return p.rw.fd.RemoteAddr()
 }
 
+// remoteIP returns the IP of the remote end, nil if the connection isn't TCP.
+func (p *Peer) remoteIP() net.IP {
+	if tcp, ok := p.RemoteAddr().(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return nil
+}
+
 // LocalAddr returns the local address of the network connection.
 func (p *Peer) LocalAddr() net.Addr {
 	return p.rw.fd.LocalAddr()
@@ -155,6 +166,19 @@ func (p *Peer) Disconnect(reason DiscReason) {
 	}
 }
 
+// Penalize lowers the reputation of the peer for misbehaving. Penalties fade
+// over time, but if they add up beyond the server's ban threshold the peer is
+// disconnected and banned. Trusted peers are never penalized.
+func (p *Peer) Penalize(penalty int, reason string) {
+	if p.rep == nil || p.rw.is(trustedConn) {
+		return
+	}
+	if p.rep.penalize(p.ID(), p.remoteIP(), penalty, reason) {
+		p.log.Debug("Banning misbehaving peer", "reason", reason)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
 // String implements fmt.Stringer.
 func (p *Peer) String() string {
 	return fmt.Sprintf("Peer %x %v", p.rw.id[:8], p.RemoteAddr())
@@ -323,7 +347,7 @@ outer:
 					offset -= old.Length
 				}
 				// Assign the new match
-				result[cap.Name] = &protoRW{Protocol: proto, offset: offset, in: make(chan Msg), w: rw}
+				result[cap.Name] = &protoRW{Protocol: proto, offset: offset, in: make(chan Msg), w: rw, traffic: newProtoTraffic(proto.Name)}
 				offset += proto.Length
 
 				continue outer
@@ -378,16 +402,36 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+
+	traffic *protoTraffic // message accounting, nil if not tracked
+	limiter *rateLimiter  // egress rate limit of the protocol, nil if unlimited
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	if msg.Code >= rw.Length {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
+	// Wait for the rate limit before acquiring the write slot, so a throttled
+	// protocol doesn't hold up the others.
+	if rw.limiter != nil {
+		if wait := rw.limiter.reserve(msg.Size); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-rw.closed:
+				timer.Stop()
+				return fmt.Errorf("shutting down")
+			}
+		}
+	}
+	code, size := msg.Code, msg.Size
 	msg.Code += rw.offset
 	select {
 	case <-rw.wstart:
 		err = rw.w.WriteMsg(msg)
+		if err == nil && rw.traffic != nil {
+			rw.traffic.markOut(code, size)
+		}
 		// Report write status back to Peer.run. It will initiate
 		// shutdown if the error is non-nil and unblock the next write
 		// otherwise. The calling protocol code should exit for errors
@@ -403,6 +447,9 @@ func (rw *protoRW) ReadMsg() (Msg, error) {
 	select {
 	case msg := <-rw.in:
 		msg.Code -= rw.offset
+		if rw.traffic != nil {
+			rw.traffic.markIn(msg.Code, msg.Size)
+		}
 		return msg, nil
 	case <-rw.closed:
 		return Msg{}, io.EOF
@@ -423,7 +470,8 @@ type PeerInfo struct {
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
 	} `json:"network"`
-	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Protocols map[string]interface{}      `json:"protocols"` // Sub-protocol specific metadata fields
+	Traffic   map[string]*ProtocolTraffic `json:"traffic"`   // Messages exchanged per sub-protocol
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -439,6 +487,7 @@ func (p *Peer) Info() *PeerInfo {
 		Name:      p.Name(),
 		Caps:      caps,
 		Protocols: make(map[string]interface{}),
+		Traffic:   make(map[string]*ProtocolTraffic),
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
@@ -457,6 +506,9 @@ func (p *Peer) Info() *PeerInfo {
 			}
 		}
 		info.Protocols[proto.Name] = protoInfo
+		if proto.traffic != nil {
+			info.Traffic[proto.Name] = proto.traffic.info()
+		}
 	}
 	return info
 }
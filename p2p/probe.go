@@ -0,0 +1,113 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"net"
+
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/rlp"
+)
+
+// ProbeConn is a bare RLPx connection to a remote node, established without
+// running a Server. It is meant for tools inspecting other nodes, e.g. network
+// crawlers, which only need the first few messages of a protocol.
+type ProbeConn struct {
+	t  transport
+	id discover.NodeID
+	hs *protoHandshake
+}
+
+// Probe runs the encryption and protocol handshakes with the given node over
+// the already dialed connection, announcing the given name and capabilities.
+// The connection is closed if the handshakes fail.
+func Probe(fd net.Conn, prv *ecdsa.PrivateKey, dest *discover.Node, name string, caps []Cap) (*ProbeConn, error) {
+	t := newRLPX(fd)
+	id, err := t.doEncHandshake(prv, dest)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	our := &protoHandshake{Version: baseProtocolVersion, Name: name, Caps: caps, ID: discover.PubkeyID(&prv.PublicKey)}
+	their, err := t.doProtoHandshake(our)
+	if err != nil {
+		t.close(err)
+		return nil, err
+	}
+	if their.ID != id {
+		t.close(DiscUnexpectedIdentity)
+		return nil, DiscUnexpectedIdentity
+	}
+	return &ProbeConn{t: t, id: id, hs: their}, nil
+}
+
+// ID returns the node's public key.
+func (c *ProbeConn) ID() discover.NodeID {
+	return c.id
+}
+
+// Name returns the node name that the remote node advertised.
+func (c *ProbeConn) Name() string {
+	return c.hs.Name
+}
+
+// Caps returns the capabilities (supported subprotocols) of the remote node.
+func (c *ProbeConn) Caps() []Cap {
+	return c.hs.Caps
+}
+
+// ReadMsg returns the next subprotocol message, answering pings and skipping
+// other base protocol messages in the meantime. Message codes are relative to
+// the first shared capability. A disconnect by the remote side is returned as
+// a DiscReason error.
+func (c *ProbeConn) ReadMsg() (Msg, error) {
+	for {
+		msg, err := c.t.ReadMsg()
+		if err != nil {
+			return Msg{}, err
+		}
+		switch {
+		case msg.Code == pingMsg:
+			msg.Discard()
+			if err := SendItems(c.t, pongMsg); err != nil {
+				return Msg{}, err
+			}
+		case msg.Code == discMsg:
+			var reason [1]DiscReason
+			rlp.Decode(msg.Payload, &reason)
+			return Msg{}, reason[0]
+		case msg.Code < baseProtocolLength:
+			msg.Discard()
+		default:
+			msg.Code -= baseProtocolLength
+			return msg, nil
+		}
+	}
+}
+
+// WriteMsg sends a subprotocol message, the code being relative to the first
+// shared capability.
+func (c *ProbeConn) WriteMsg(msg Msg) error {
+	msg.Code += baseProtocolLength
+	return c.t.WriteMsg(msg)
+}
+
+// Close disconnects from the remote node.
+func (c *ProbeConn) Close() {
+	c.t.close(DiscRequested)
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ecchain/go-ecchain/p2p/discover"
+)
+
+func TestProbe(t *testing.T) {
+	var (
+		prv0 = newkey()
+		prv1 = newkey()
+		node = &discover.Node{ID: discover.PubkeyID(&prv1.PublicKey), IP: net.IP{127, 0, 0, 1}, TCP: 30303}
+		hs   = &protoHandshake{Version: baseProtocolVersion, Name: "remote", Caps: []Cap{{"a", 1}}, ID: node.ID}
+	)
+	fd0, fd1, err := tcpPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd1.Close()
+
+	// Run the remote side: ping, send a subprotocol message, expect the
+	// pong and the answer, then disconnect.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rlpx := newRLPX(fd1)
+		if _, err := rlpx.doEncHandshake(prv1, nil); err != nil {
+			t.Errorf("remote enc handshake failed: %v", err)
+			return
+		}
+		if _, err := rlpx.doProtoHandshake(hs); err != nil {
+			t.Errorf("remote proto handshake failed: %v", err)
+			return
+		}
+		SendItems(rlpx, pingMsg)
+		SendItems(rlpx, baseProtocolLength+2, uint(1))
+		if err := ExpectMsg(rlpx, pongMsg, nil); err != nil {
+			t.Errorf("error receiving pong: %v", err)
+		}
+		if err := ExpectMsg(rlpx, baseProtocolLength+3, []uint{2}); err != nil {
+			t.Errorf("error receiving answer: %v", err)
+		}
+		SendItems(rlpx, discMsg, DiscTooManyPeers)
+	}()
+
+	c, err := Probe(fd0, prv0, node, "crawler", []Cap{{"a", 1}})
+	if err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	defer c.Close()
+
+	if c.ID() != node.ID {
+		t.Errorf("remote id mismatch: have %v, want %v", c.ID(), node.ID)
+	}
+	if c.Name() != "remote" || !reflect.DeepEqual(c.Caps(), hs.Caps) {
+		t.Errorf("handshake mismatch: name %q, caps %v", c.Name(), c.Caps())
+	}
+	msg, err := c.ReadMsg()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msg.Code != 2 {
+		t.Errorf("message code mismatch: have %d, want 2", msg.Code)
+	}
+	msg.Discard()
+	if err := SendItems(c, 3, uint(2)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := c.ReadMsg(); err != DiscTooManyPeers {
+		t.Errorf("disconnect mismatch: have %v, want %v", err, DiscTooManyPeers)
+	}
+	<-done
+}
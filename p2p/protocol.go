@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 )
 
 // Protocol represents a P2P subprotocol implementation.
@@ -52,6 +53,15 @@ type Protocol struct {
 	// about a certain peer in the network. If an info retrieval function is set,
 	// but returns nil, it is assumed that the protocol handshake is still running.
 	PeerInfo func(id discover.NodeID) interface{}
+
+	// Attributes contains protocol specific entries for the node record of the
+	// host node, announced through discovery.
+	Attributes []enr.Entry
+
+	// DialFilter is an optional helper method to check the node record of a
+	// discovered node before dialing it. If it returns an error, the node is not
+	// dialed.
+	DialFilter func(record *enr.Record) error
 }
 
 func (p Protocol) cap() Cap {
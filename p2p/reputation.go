@@ -0,0 +1,246 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/netutil"
+)
+
+// Penalties applied by protocol handlers to the reputation of misbehaving peers.
+const (
+	PenaltyUseless = 10 // Peer stalled or sent unrequested or useless data
+	PenaltyInvalid = 50 // Peer sent invalid data or violated the protocol
+)
+
+const (
+	defaultBanThreshold = 100
+	defaultBanDuration  = time.Hour
+
+	scoreHalfLife = 10 * time.Minute // Time after which penalties only count half
+	minScore      = 1                // Scores below this are forgotten
+)
+
+// PeerScore is the reputation of a recently penalized peer.
+type PeerScore struct {
+	ID     discover.NodeID `json:"id"`
+	Score  float64         `json:"score"`  // Sum of the decayed penalties
+	Reason string          `json:"reason"` // Reason of the last penalty
+}
+
+// PeerBan is an active ban of a node ID or IP.
+type PeerBan struct {
+	Target string    `json:"target"`
+	Until  time.Time `json:"until"`
+}
+
+// ReputationInfo contains the scores of penalized peers and the active bans.
+type ReputationInfo struct {
+	Scores []PeerScore `json:"scores"`
+	Bans   []PeerBan   `json:"bans"`
+}
+
+// banStore persists peer bans across restarts, it is implemented on top of the
+// node database by the discovery table and by discover.BanDB.
+type banStore interface {
+	Bans() map[string]time.Time
+	StoreBan(key string, until time.Time) error
+	DeleteBan(key string) error
+}
+
+type peerScore struct {
+	score   float64
+	updated time.Time
+	reason  string
+}
+
+// reputation tracks the penalties of peers, banning the node ID and IP of
+// peers whose decayed penalties add up to the threshold.
+type reputation struct {
+	threshold float64
+	duration  time.Duration
+	store     banStore // nil if there is no node database
+
+	lock   sync.Mutex
+	scores map[discover.NodeID]*peerScore
+	bans   map[string]time.Time // Keyed by node ID or IP
+}
+
+func newReputation(threshold int, duration time.Duration, store banStore) *reputation {
+	if threshold <= 0 {
+		threshold = defaultBanThreshold
+	}
+	if duration <= 0 {
+		duration = defaultBanDuration
+	}
+	r := &reputation{
+		threshold: float64(threshold),
+		duration:  duration,
+		store:     store,
+		scores:    make(map[discover.NodeID]*peerScore),
+		bans:      make(map[string]time.Time),
+	}
+	if store != nil {
+		r.bans = store.Bans()
+	}
+	return r
+}
+
+// current returns the score decayed until the given time.
+func (s *peerScore) current(now time.Time) float64 {
+	return s.score * math.Exp2(-float64(now.Sub(s.updated))/float64(scoreHalfLife))
+}
+
+// penalize adds a penalty to the score of a peer, banning it if the score
+// reaches the threshold. It reports whecer the peer got banned.
+func (r *reputation) penalize(id discover.NodeID, ip net.IP, penalty int, reason string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	s := r.scores[id]
+	if s == nil {
+		s = new(peerScore)
+		r.scores[id] = s
+	}
+	s.score = s.current(now) + float64(penalty)
+	s.updated, s.reason = now, reason
+
+	// Compare whole points, so penalties in quick succession add up exactly
+	if float64(int64(s.score+0.5)) < r.threshold {
+		return false
+	}
+	// The peer exceeded the threshold, ban it. Addresses in private networks are
+	// spared as they are likely to be shared with well behaving nodes.
+	delete(r.scores, id)
+	until := now.Add(r.duration)
+	r.ban(id.String(), until)
+	if ip != nil && !netutil.IsLAN(ip) {
+		r.ban(ip.String(), until)
+	}
+	return true
+}
+
+// banFor bans the node ID or IP for the given duration, a zero duration using
+// the default one.
+func (r *reputation) banFor(key string, duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if duration <= 0 {
+		duration = r.duration
+	}
+	r.ban(key, time.Now().Add(duration))
+}
+
+// ban bans the node ID or IP until the given time. The lock must be held.
+func (r *reputation) ban(key string, until time.Time) {
+	r.bans[key] = until
+	if r.store != nil {
+		if err := r.store.StoreBan(key, until); err != nil {
+			log.Warn("Failed to store peer ban", "target", key, "err", err)
+		}
+	}
+}
+
+// unban lifts the ban of the node ID or IP, reporting whecer it was banned.
+func (r *reputation) unban(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.bans[key]
+	delete(r.bans, key)
+	if r.store != nil {
+		if err := r.store.DeleteBan(key); err != nil {
+			log.Warn("Failed to delete peer ban", "target", key, "err", err)
+		}
+	}
+	return ok
+}
+
+// banned reports whecer either the node ID or the IP is banned. A zero ID or
+// nil IP is not checked.
+func (r *reputation) banned(id discover.NodeID, ip net.IP) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if (id != discover.NodeID{}) && r.isBanned(id.String()) {
+		return true
+	}
+	return ip != nil && r.isBanned(ip.String())
+}
+
+// isBanned reports whecer the key is banned, dropping the ban if it expired. The
+// lock must be held.
+func (r *reputation) isBanned(key string) bool {
+	until, ok := r.bans[key]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(r.bans, key)
+	return false
+}
+
+// info returns the scores of penalized peers, highest first, and all active
+// bans. Forgotten scores and expired bans are dropped.
+func (r *reputation) info() *ReputationInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	info := &ReputationInfo{Scores: []PeerScore{}, Bans: []PeerBan{}}
+	now := time.Now()
+	for id, s := range r.scores {
+		score := s.current(now)
+		if score < minScore {
+			delete(r.scores, id)
+			continue
+		}
+		info.Scores = append(info.Scores, PeerScore{ID: id, Score: score, Reason: s.reason})
+	}
+	sort.Slice(info.Scores, func(i, j int) bool { return info.Scores[i].Score > info.Scores[j].Score })
+
+	for key, until := range r.bans {
+		if r.isBanned(key) {
+			info.Bans = append(info.Bans, PeerBan{Target: key, Until: until})
+		}
+	}
+	sort.Slice(info.Bans, func(i, j int) bool { return info.Bans[i].Until.Before(info.Bans[j].Until) })
+	return info
+}
+
+// parseBanTarget converts a node ID, enode URL or IP into the key of its ban.
+func parseBanTarget(target string) (string, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		return ip.String(), nil
+	}
+	n, err := discover.ParseNode(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid ban target %q: not a node ID, enode URL or IP", target)
+	}
+	return n.ID.String(), nil
+}
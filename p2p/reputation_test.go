@@ -0,0 +1,185 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/p2p/discover"
+)
+
+// mapBanStore is a banStore keeping the bans in memory.
+type mapBanStore map[string]time.Time
+
+func (s mapBanStore) Bans() map[string]time.Time {
+	bans := make(map[string]time.Time)
+	for key, until := range s {
+		bans[key] = until
+	}
+	return bans
+}
+
+func (s mapBanStore) StoreBan(key string, until time.Time) error {
+	s[key] = until
+	return nil
+}
+
+func (s mapBanStore) DeleteBan(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestReputationBan(t *testing.T) {
+	var (
+		store = make(mapBanStore)
+		rep   = newReputation(100, time.Hour, store)
+		id    = randomID()
+		ip    = net.IP{8, 8, 8, 8}
+	)
+	if rep.penalize(id, ip, PenaltyInvalid, "bad block") {
+		t.Fatal("peer banned below the threshold")
+	}
+	if info := rep.info(); len(info.Scores) != 1 || info.Scores[0].ID != id || info.Scores[0].Reason != "bad block" {
+		t.Fatalf("score mismatch: %+v", info.Scores)
+	}
+	if !rep.penalize(id, ip, PenaltyInvalid, "bad block") {
+		t.Fatal("peer not banned at the threshold")
+	}
+	if !rep.banned(id, nil) || !rep.banned(discover.NodeID{}, ip) {
+		t.Error("node ID or IP not banned")
+	}
+	if len(store) != 2 {
+		t.Errorf("bans not persisted: %v", store)
+	}
+	// The bans must survive a restart, and be lifted individually
+	rep = newReputation(100, time.Hour, store)
+	if !rep.banned(id, nil) {
+		t.Error("ban lost after restart")
+	}
+	if !rep.unban(id.String()) || rep.banned(id, nil) {
+		t.Error("node ID still banned after unban")
+	}
+	if !rep.banned(randomID(), ip) {
+		t.Error("IP ban lifted with node ID ban")
+	}
+	if _, ok := store[id.String()]; ok {
+		t.Error("lifted ban still persisted")
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	rep := newReputation(100, time.Hour, nil)
+	id := randomID()
+
+	rep.penalize(id, nil, PenaltyInvalid, "")
+	rep.scores[id].updated = time.Now().Add(-2 * scoreHalfLife)
+	if rep.penalize(id, nil, PenaltyInvalid, "") {
+		t.Fatal("peer banned despite decayed penalties")
+	}
+	if score := rep.info().Scores[0].Score; score < 62 || score > 63 {
+		t.Errorf("decayed score mismatch: have %f, want 62.5", score)
+	}
+	rep.scores[id].updated = time.Now().Add(-20 * scoreHalfLife)
+	if info := rep.info(); len(info.Scores) != 0 {
+		t.Errorf("faded score not forgotten: %+v", info.Scores)
+	}
+}
+
+func TestReputationSparesLAN(t *testing.T) {
+	rep := newReputation(10, time.Hour, nil)
+	ip := net.IP{192, 168, 0, 1}
+	if !rep.penalize(randomID(), ip, PenaltyInvalid, "") {
+		t.Fatal("peer not banned")
+	}
+	if rep.banned(discover.NodeID{}, ip) {
+		t.Error("LAN address banned")
+	}
+}
+
+func TestReputationExpiry(t *testing.T) {
+	rep := newReputation(100, time.Hour, nil)
+	rep.banFor("8.8.8.8", time.Hour)
+	rep.bans["8.8.4.4"] = time.Now().Add(-time.Second)
+
+	info := rep.info()
+	if len(info.Bans) != 1 || info.Bans[0].Target != "8.8.8.8" {
+		t.Errorf("bans mismatch: %+v", info.Bans)
+	}
+}
+
+func TestParseBanTarget(t *testing.T) {
+	id := randomID()
+	tests := []struct {
+		target, key string
+	}{
+		{target: "10.0.0.1", key: "10.0.0.1"},
+		{target: "::ffff:10.0.0.1", key: "10.0.0.1"},
+		{target: id.String(), key: id.String()},
+		{target: "enode://" + id.String() + "@10.0.0.1:30303", key: id.String()},
+		{target: "foo"},
+	}
+	for _, tt := range tests {
+		key, err := parseBanTarget(tt.target)
+		if tt.key == "" {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.target)
+			}
+			continue
+		}
+		if err != nil || key != tt.key {
+			t.Errorf("%q: have %q (err %v), want %q", tt.target, key, err, tt.key)
+		}
+	}
+}
+
+// Tests that bans are persisted in the node database even if discovery is off.
+func TestServerBansWithoutDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-bans")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := Config{PrivateKey: newkey(), MaxPeers: 10, NoDiscovery: true, NoDial: true, NodeDatabase: dir}
+
+	srv := &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	if err := srv.BanPeer("10.0.0.1", time.Hour); err != nil {
+		t.Fatalf("failed to ban peer: %v", err)
+	}
+	srv.Stop()
+
+	srv = &Server{Config: config}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("failed to restart server: %v", err)
+	}
+	defer srv.Stop()
+
+	info, err := srv.PeerScores()
+	if err != nil {
+		t.Fatalf("failed to retrieve scores: %v", err)
+	}
+	if len(info.Bans) != 1 || info.Bans[0].Target != "10.0.0.1" {
+		t.Errorf("bans mismatch after restart: have %+v, want ban of 10.0.0.1", info.Bans)
+	}
+}
@@ -606,7 +606,9 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 			return errPlainMessageTooLarge
 		}
 		payload, _ := ioutil.ReadAll(msg.Payload)
+		egressRawMeter.Mark(int64(len(payload)))
 		payload = snappy.Encode(nil, payload)
+		egressCompressedMeter.Mark(int64(len(payload)))
 
 		msg.Payload = bytes.NewReader(payload)
 		msg.Size = uint32(len(payload))
@@ -710,10 +712,12 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 		if size > int(maxUint24) {
 			return msg, errPlainMessageTooLarge
 		}
+		ingressCompressedMeter.Mark(int64(len(payload)))
 		payload, err = snappy.Decode(nil, payload)
 		if err != nil {
 			return msg, err
 		}
+		ingressRawMeter.Mark(int64(len(payload)))
 		msg.Size, msg.Payload = uint32(size), bytes.NewReader(payload)
 	}
 	return msg, nil
@@ -378,6 +378,48 @@ func TestRLPXFrameRW(t *testing.T) {
 	}
 }
 
+func TestRLPXFrameRWSnappy(t *testing.T) {
+	var (
+		aesSecret = make([]byte, 16)
+		macSecret = make([]byte, 16)
+		macInit   = make([]byte, 32)
+	)
+	for _, s := range [][]byte{aesSecret, macSecret, macInit} {
+		rand.Read(s)
+	}
+	conn := new(bytes.Buffer)
+	newRW := func() *rlpxFrameRW {
+		s := secrets{AES: aesSecret, MAC: macSecret, EgressMAC: sha3.NewKeccak256(), IngressMAC: sha3.NewKeccak256()}
+		s.EgressMAC.Write(macInit)
+		s.IngressMAC.Write(macInit)
+		rw := newRLPXFrameRW(conn, s)
+		rw.snappy = true
+		return rw
+	}
+	rw1, rw2 := newRW(), newRW()
+
+	// A highly redundant payload must shrink on the wire and arrive intact
+	wmsg := []interface{}{strings.Repeat("block", 1000)}
+	wantPayload, _ := rlp.EncodeToBytes(wmsg)
+	if err := Send(rw1, 1, wmsg); err != nil {
+		t.Fatalf("WriteMsg error: %v", err)
+	}
+	if conn.Len() >= len(wantPayload)/10 {
+		t.Errorf("payload not compressed: %d bytes on the wire for %d byte payload", conn.Len(), len(wantPayload))
+	}
+	msg, err := rw2.ReadMsg()
+	if err != nil {
+		t.Fatalf("ReadMsg error: %v", err)
+	}
+	if msg.Size != uint32(len(wantPayload)) {
+		t.Errorf("msg size mismatch: got %d, want %d", msg.Size, len(wantPayload))
+	}
+	payload, _ := ioutil.ReadAll(msg.Payload)
+	if !bytes.Equal(payload, wantPayload) {
+		t.Errorf("msg payload mismatch:\ngot  %x\nwant %x", payload, wantPayload)
+	}
+}
+
 type handshakeAuthTest struct {
 	input       string
 	isPlain     bool
@@ -31,6 +31,8 @@ import (
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/p2p/discover"
 	"github.com/ecchain/go-ecchain/p2p/discv5"
+	"github.com/ecchain/go-ecchain/p2p/dnsdisc"
+	"github.com/ecchain/go-ecchain/p2p/enr"
 	"github.com/ecchain/go-ecchain/p2p/nat"
 	"github.com/ecchain/go-ecchain/p2p/netutil"
 )
@@ -51,7 +53,10 @@ const (
 	frameWriteTimeout = 20 * time.Second
 )
 
-var errServerStopped = errors.New("server stopped")
+var (
+	errServerStopped = errors.New("server stopped")
+	errNoDiscovery   = errors.New("discovery is disabled")
+)
 
 // Config holds Server options.
 type Config struct {
@@ -93,6 +98,23 @@ type Config struct {
 	// protocol.
 	BooecrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DNSDiscovery contains the enrtree:// URLs of DNS node lists (EIP-1459)
+	// to take dial candidates from, in addition to the discovery table.
+	DNSDiscovery []string `toml:",omitempty"`
+
+	// BanThreshold is the sum of penalties after which a misbehaving peer is
+	// banned. Penalties fade over time. Zero defaults to 100.
+	BanThreshold int `toml:",omitempty"`
+
+	// BanDuration is the time misbehaving peers are banned for.
+	// Zero defaults to one hour.
+	BanDuration time.Duration `toml:",omitempty"`
+
+	// EgressLimits caps the outbound traffic of protocols in bytes per second,
+	// summed up over all peers and keyed by protocol name. Protocols without a
+	// limit are not throttled.
+	EgressLimits map[string]int `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*discover.Node
@@ -157,6 +179,10 @@ type Server struct {
 	running bool
 
 	ntab         discoverTable
+	dnsdisc      *dnsdisc.Client
+	bandb        *discover.BanDB // Node database opened for bans if discovery is off
+	rep          *reputation
+	limiters     map[string]*rateLimiter // egress rate limits by protocol name
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
@@ -316,6 +342,80 @@ func (srv *Server) Self() *discover.Node {
 	return srv.makeSelf(srv.listener, srv.ntab)
 }
 
+// SetNodeRecordEntry adds or replaces an entry of the local node record announced
+// through discovery. It fails if the server isn't running or discovery is off.
+func (srv *Server) SetNodeRecordEntry(e enr.Entry) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return errServerStopped
+	}
+	if srv.ntab == nil {
+		return errNoDiscovery
+	}
+	return srv.ntab.SetRecordEntry(e)
+}
+
+// PeerScores returns the reputation scores of recently penalized peers and the
+// active bans.
+func (srv *Server) PeerScores() (*ReputationInfo, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return nil, errServerStopped
+	}
+	return srv.rep.info(), nil
+}
+
+// BanPeer bans the given node ID, enode URL or IP for the given duration and
+// disconnects matching peers. A zero duration uses the configured BanDuration.
+func (srv *Server) BanPeer(target string, duration time.Duration) error {
+	key, err := parseBanTarget(target)
+	if err != nil {
+		return err
+	}
+	srv.lock.Lock()
+	if !srv.running {
+		srv.lock.Unlock()
+		return errServerStopped
+	}
+	rep := srv.rep
+	srv.lock.Unlock()
+
+	rep.banFor(key, duration)
+
+	select {
+	case srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		for _, p := range peers {
+			if rep.banned(p.ID(), p.remoteIP()) {
+				p.Disconnect(DiscUselessPeer)
+			}
+		}
+	}:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+	return nil
+}
+
+// UnbanPeer lifts the ban of the given node ID, enode URL or IP, reporting
+// whecer it was banned.
+func (srv *Server) UnbanPeer(target string) (bool, error) {
+	key, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	if !srv.running {
+		return false, errServerStopped
+	}
+	return srv.rep.unban(key), nil
+}
+
 func (srv *Server) makeSelf(listener net.Listener, ntab discoverTable) *discover.Node {
 	// If the server's not running, return an empty node.
 	// If the node is running but discovery is off, manually assemble the node infos.
@@ -393,6 +493,20 @@ func (srv *Server) Start() (err error) {
 		srv.log = log.New()
 	}
 	srv.log.Info("Starting P2P networking")
+	// Release the resources of a half started server on failure
+	defer func() {
+		if err == nil {
+			return
+		}
+		if srv.dnsdisc != nil {
+			srv.dnsdisc.Stop()
+			srv.dnsdisc = nil
+		}
+		if srv.bandb != nil {
+			srv.bandb.Close()
+			srv.bandb = nil
+		}
+	}()
 
 	// static fields
 	if srv.PrivateKey == nil {
@@ -456,6 +570,9 @@ func (srv *Server) Start() (err error) {
 			Bootnodes:    srv.BooecrapNodes,
 			Unhandled:    unhandled,
 		}
+		for _, proto := range srv.Protocols {
+			cfg.Entries = append(cfg.Entries, proto.Attributes...)
+		}
 		ntab, err := discover.ListenUDP(conn, cfg)
 		if err != nil {
 			return err
@@ -482,8 +599,46 @@ func (srv *Server) Start() (err error) {
 		srv.DiscV5 = ntab
 	}
 
+	// DNS node lists
+	if len(srv.DNSDiscovery) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log})
+		for _, url := range srv.DNSDiscovery {
+			if err := client.AddTree(url); err != nil {
+				return err
+			}
+		}
+		client.Start()
+		srv.dnsdisc = client
+	}
+
+	// Peer reputation, bans are persisted in the node database if there is one.
+	// Without a discovery table running on top of it, open the database here.
+	var bans banStore
+	if store, ok := srv.ntab.(banStore); ok {
+		bans = store
+	} else if srv.NodeDatabase != "" {
+		db, err := discover.OpenBanDB(srv.NodeDatabase, discover.PubkeyID(&srv.PrivateKey.PublicKey))
+		if err != nil {
+			return err
+		}
+		srv.bandb, bans = db, db
+	}
+	srv.rep = newReputation(srv.BanThreshold, srv.BanDuration, bans)
+
+	// Protocol egress limits, shared by all peers
+	srv.limiters = make(map[string]*rateLimiter)
+	for name, rate := range srv.EgressLimits {
+		if rate > 0 {
+			srv.limiters[name] = newRateLimiter(rate)
+		}
+	}
+
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.StaticNodes, srv.BooecrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	if srv.dnsdisc != nil {
+		dialer.dns = srv.dnsdisc
+	}
+	dialer.bans = srv.rep
 
 	// handshake
 	srv.ourHandshake = &protoHandshake{Version: baseProtocolVersion, Name: srv.Name, ID: discover.PubkeyID(&srv.PrivateKey.PublicKey)}
@@ -636,6 +791,10 @@ running:
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.rep = srv.rep
+				for name, proto := range p.running {
+					proto.limiter = srv.limiters[name]
+				}
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -677,6 +836,12 @@ running:
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.dnsdisc != nil {
+		srv.dnsdisc.Stop()
+	}
+	if srv.bandb != nil {
+		srv.bandb.Close()
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -711,6 +876,8 @@ func (srv *Server) encHandshakeChecks(peers map[discover.NodeID]*Peer, inboundCo
 		return DiscAlreadyConnected
 	case c.id == srv.Self().ID:
 		return DiscSelf
+	case srv.rep != nil && srv.rep.banned(c.id, nil):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -721,7 +888,7 @@ func (srv *Server) maxInboundConns() int {
 }
 
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if (srv.NoDiscovery && len(srv.DNSDiscovery) == 0) || srv.NoDial {
 		return 0
 	}
 	r := srv.DialRatio
@@ -780,6 +947,14 @@ func (srv *Server) listenLoop() {
 			}
 		}
 
+		// Reject connections from banned addresses.
+		if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok && srv.rep.banned(discover.NodeID{}, tcp.IP) {
+			srv.log.Debug("Rejected conn (banned)", "addr", fd.RemoteAddr())
+			fd.Close()
+			slots <- struct{}{}
+			continue
+		}
+
 		fd = newMeteredConn(fd, true)
 		srv.log.Trace("Accepted connection", "addr", fd.RemoteAddr())
 		go func() {
@@ -29,6 +29,7 @@ import (
 	"github.com/ecchain/go-ecchain/crypto/sha3"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/dnsdisc"
 )
 
 func init() {
@@ -502,3 +503,30 @@ func randomID() (id discover.NodeID) {
 	}
 	return id
 }
+
+// Tests that a server failing to start stops the DNS discovery client it has
+// already launched.
+func TestServerStartFailureStopsDNS(t *testing.T) {
+	tree, err := dnsdisc.MakeTree(1, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tree: %v", err)
+	}
+	url, err := tree.Sign(newkey(), "nodes.example.invalid")
+	if err != nil {
+		t.Fatalf("failed to sign tree: %v", err)
+	}
+	srv := &Server{Config: Config{
+		PrivateKey:   newkey(),
+		MaxPeers:     10,
+		NoDiscovery:  true,
+		DNSDiscovery: []string{url},
+		ListenAddr:   "127.0.0.1:invalid",
+	}}
+	if err := srv.Start(); err == nil {
+		srv.Stop()
+		t.Fatal("server started with invalid listen address")
+	}
+	if srv.dnsdisc != nil {
+		t.Error("DNS discovery client left running after failed start")
+	}
+}
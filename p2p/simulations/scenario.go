@@ -0,0 +1,655 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ecchain/go-ecchain/p2p/discover"
+	"github.com/ecchain/go-ecchain/p2p/simulations/adapters"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+const (
+	// defaultExpectTimeout is the time an assertion is retried for if the step
+	// doesn't specify a timeout
+	defaultExpectTimeout = 10 * time.Second
+
+	// expectPollInterval is the interval an assertion is retried at
+	expectPollInterval = 100 * time.Millisecond
+)
+
+// Scenario is a declarative simulation experiment, creating a number of nodes
+// and driving them through a sequence of timed steps against the simulation
+// HTTP API.
+//
+// Scenarios are defined in JSON, for example:
+//
+//     {
+//       "name": "partition and heal",
+//       "nodes": 4,
+//       "steps": [
+//         {"action": "connect", "topology": "full"},
+//         {"action": "expect", "condition": "peers", "peers": 3},
+//         {"action": "partition", "groups": [["node01", "node02"], ["node03", "node04"]]},
+//         {"action": "expect", "condition": "partitioned", "groups": [["node01", "node02"], ["node03", "node04"]]},
+//         {"wait": "1s", "action": "heal"},
+//         {"action": "expect", "condition": "peers", "peers": 3, "timeout": "5s"}
+//       ]
+//     }
+type Scenario struct {
+	Name     string          `json:"name"`
+	Nodes    int             `json:"nodes"`    // Number of nodes created and started, named node01, node02, ...
+	Services []string        `json:"services"` // Services run by the nodes, the network default if empty
+	Steps    []*ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a single action or assertion of a scenario. Steps which
+// don't list any nodes apply to all nodes of the scenario.
+//
+// The supported actions are:
+//
+//     connect, disconnect  connect or disconnect the nodes in the given topology
+//     partition            disconnect all nodes of different groups
+//     heal                 reconnect all connections dropped by partitions
+//     kill, start          stop or start the nodes
+//     rpc                  call an RPC method on the nodes, checking the result if given
+//     expect               assert a condition, retrying until the timeout
+//
+// The supported conditions are:
+//
+//     up, down             the nodes are running or stopped
+//     connected            the nodes are connected in the given topology
+//     disconnected         none of the nodes are connected in the given topology
+//     partitioned          no node is connected to a node of another group
+//     peers                each node has at least the given number of peers
+type ScenarioStep struct {
+	Wait      time.Duration `json:"-"` // Time to wait before executing the step
+	Action    string        `json:"action"`
+	Condition string        `json:"condition,omitempty"`
+	Nodes     []string      `json:"nodes,omitempty"`
+	Topology  string        `json:"topology,omitempty"` // "chain" (default), "ring", "star" or "full"
+	Groups    [][]string    `json:"groups,omitempty"`
+	Peers     int           `json:"peers,omitempty"`
+	Method    string        `json:"method,omitempty"`
+	Params    []interface{} `json:"params,omitempty"`
+	Result    interface{}   `json:"result,omitempty"`
+	Timeout   time.Duration `json:"-"` // Time an assertion is retried for
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the wait and timeout
+// fields as duration strings (e.g. "1.5s").
+func (s *ScenarioStep) UnmarshalJSON(data []byte) error {
+	type step ScenarioStep
+	dec := struct {
+		*step
+		Wait    string `json:"wait"`
+		Timeout string `json:"timeout"`
+	}{step: (*step)(s)}
+
+	if err := json.Unmarshal(data, &dec); err != nil {
+		return err
+	}
+	var err error
+	if dec.Wait != "" {
+		if s.Wait, err = time.ParseDuration(dec.Wait); err != nil {
+			return fmt.Errorf("invalid wait %q: %v", dec.Wait, err)
+		}
+	}
+	if dec.Timeout != "" {
+		if s.Timeout, err = time.ParseDuration(dec.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout %q: %v", dec.Timeout, err)
+		}
+	}
+	return nil
+}
+
+// String returns a short description of the step for reports.
+func (s *ScenarioStep) String() string {
+	desc := s.Action
+	switch s.Action {
+	case "expect":
+		desc += " " + s.Condition
+	case "rpc":
+		desc += " " + s.Method
+	}
+	if len(s.Nodes) > 0 {
+		desc += fmt.Sprintf(" %v", s.Nodes)
+	}
+	if s.Topology != "" {
+		desc += " (" + s.Topology + ")"
+	}
+	return desc
+}
+
+// assertion reports whecer the step checks the network instead of changing it,
+// i.e. it is an expect step or an RPC call with an expected result.
+func (s *ScenarioStep) assertion() bool {
+	return s.Action == "expect" || (s.Action == "rpc" && s.Result != nil)
+}
+
+// LoadScenario reads a JSON scenario definition from a file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenario := new(Scenario)
+	if err := json.Unmarshal(data, scenario); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", path, err)
+	}
+	return scenario, nil
+}
+
+// ScenarioReport is the outcome of running a scenario.
+type ScenarioReport struct {
+	Name     string                `json:"name"`
+	Passed   bool                  `json:"passed"`
+	Error    string                `json:"error,omitempty"` // Setup failure, if any
+	Steps    []*ScenarioStepResult `json:"steps"`
+	Duration time.Duration         `json:"duration"`
+}
+
+// ScenarioStepResult is the outcome of a single scenario step.
+type ScenarioStepResult struct {
+	Step     *ScenarioStep `json:"step"`
+	Passed   bool          `json:"passed"`
+	Skipped  bool          `json:"skipped,omitempty"` // Not run due to an earlier failed action
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Write prints a human readable pass/fail summary of the report.
+func (r *ScenarioReport) Write(out io.Writer) {
+	status := "PASS"
+	if !r.Passed {
+		status = "FAIL"
+	}
+	fmt.Fprintf(out, "Scenario %q: %s (%v)\n", r.Name, status, r.Duration)
+	if r.Error != "" {
+		fmt.Fprintf(out, "  setup failed: %s\n", r.Error)
+	}
+	w := tabwriter.NewWriter(out, 1, 2, 2, ' ', 0)
+	for i, result := range r.Steps {
+		status := "ok"
+		switch {
+		case result.Skipped:
+			status = "skipped"
+		case !result.Passed:
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%v\t%s\n", i+1, result.Step, status, result.Duration, result.Error)
+	}
+	w.Flush()
+}
+
+// scenarioRun is the state of a scenario being run.
+type scenarioRun struct {
+	scenario *Scenario
+	client   *Client
+	nodes    []string
+
+	rpcs map[string]*rpc.Client // RPC clients of the nodes, dialed on demand
+	cuts [][2]string            // Connections dropped by partitions, restored when healing
+}
+
+// Run creates the scenario's nodes in the network behind the client, executes
+// all steps in order and reports their outcome. Assertion failures are recorded
+// without aborting the run, whereas a failing action skips all remaining steps.
+func (s *Scenario) Run(ctx context.Context, client *Client) *ScenarioReport {
+	start := time.Now()
+	report := &ScenarioReport{Name: s.Name}
+
+	run := &scenarioRun{
+		scenario: s,
+		client:   client,
+		rpcs:     make(map[string]*rpc.Client),
+	}
+	defer run.close()
+
+	if err := run.setup(); err != nil {
+		report.Error = err.Error()
+		report.Duration = time.Since(start)
+		return report
+	}
+	report.Passed = true
+
+	aborted := false
+	for _, step := range s.Steps {
+		result := &ScenarioStepResult{Step: step}
+		report.Steps = append(report.Steps, result)
+		if aborted {
+			result.Skipped = true
+			continue
+		}
+		if step.Wait > 0 {
+			select {
+			case <-time.After(step.Wait):
+			case <-ctx.Done():
+			}
+		}
+		stepStart := time.Now()
+		err := ctx.Err()
+		if err == nil {
+			err = run.execute(ctx, step)
+		}
+		result.Duration = time.Since(stepStart)
+
+		if err != nil {
+			result.Error = err.Error()
+			report.Passed = false
+			if !step.assertion() {
+				aborted = true
+			}
+			continue
+		}
+		result.Passed = true
+	}
+	report.Duration = time.Since(start)
+	return report
+}
+
+// setup creates and starts the nodes of the scenario.
+func (r *scenarioRun) setup() error {
+	if r.scenario.Nodes <= 0 {
+		return errors.New("scenario has no nodes")
+	}
+	for i := 0; i < r.scenario.Nodes; i++ {
+		config := adapters.RandomNodeConfig()
+		config.Name = fmt.Sprintf("node%02d", i+1)
+		config.Services = r.scenario.Services
+
+		node, err := r.client.CreateNode(config)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", config.Name, err)
+		}
+		if err := r.client.StartNode(node.ID); err != nil {
+			return fmt.Errorf("failed to start %s: %v", config.Name, err)
+		}
+		r.nodes = append(r.nodes, config.Name)
+	}
+	return nil
+}
+
+// close disconnects all RPC clients of the run.
+func (r *scenarioRun) close() {
+	for _, client := range r.rpcs {
+		client.Close()
+	}
+}
+
+// stepNodes returns the nodes a step applies to.
+func (r *scenarioRun) stepNodes(step *ScenarioStep) []string {
+	if len(step.Nodes) > 0 {
+		return step.Nodes
+	}
+	return r.nodes
+}
+
+// execute runs a single scenario step.
+func (r *scenarioRun) execute(ctx context.Context, step *ScenarioStep) error {
+	nodes := r.stepNodes(step)
+
+	switch step.Action {
+	case "connect", "disconnect":
+		pairs, err := topologyPairs(nodes, step.Topology)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			if step.Action == "connect" {
+				err = r.client.ConnectNode(pair[0], pair[1])
+			} else {
+				err = r.client.DisconnectNode(pair[0], pair[1])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to %s %s and %s: %v", step.Action, pair[0], pair[1], err)
+			}
+		}
+		return nil
+
+	case "partition":
+		return r.partition(step.Groups)
+
+	case "heal":
+		for _, pair := range r.cuts {
+			if err := r.client.ConnectNode(pair[0], pair[1]); err != nil {
+				return fmt.Errorf("failed to reconnect %s and %s: %v", pair[0], pair[1], err)
+			}
+		}
+		r.cuts = nil
+		return nil
+
+	case "kill", "start":
+		for _, node := range nodes {
+			var err error
+			if step.Action == "kill" {
+				err = r.client.StopNode(node)
+			} else {
+				err = r.client.StartNode(node)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to %s %s: %v", step.Action, node, err)
+			}
+			// The RPC endpoint of a restarted node is a new one, redial
+			if client, ok := r.rpcs[node]; ok {
+				client.Close()
+				delete(r.rpcs, node)
+			}
+		}
+		return nil
+
+	case "rpc":
+		if step.Method == "" {
+			return errors.New("rpc step without method")
+		}
+		if step.Result == nil {
+			for _, node := range nodes {
+				if _, err := r.call(ctx, node, step.Method, step.Params); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return r.poll(ctx, step.Timeout, func() error {
+			for _, node := range nodes {
+				if err := r.checkRPC(ctx, node, step); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	case "expect":
+		check, err := r.condition(step, nodes)
+		if err != nil {
+			return err
+		}
+		return r.poll(ctx, step.Timeout, check)
+
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+}
+
+// partition disconnects all live connections between nodes of different groups,
+// remembering them for a later heal.
+func (r *scenarioRun) partition(groups [][]string) error {
+	if len(groups) < 2 {
+		return errors.New("partition needs at least two groups")
+	}
+	state, err := r.state()
+	if err != nil {
+		return err
+	}
+	group := make(map[string]int)
+	for i, nodes := range groups {
+		for _, node := range nodes {
+			group[node] = i
+		}
+	}
+	for _, conn := range state.conns {
+		one, ok1 := group[conn[0]]
+		other, ok2 := group[conn[1]]
+		if !ok1 || !ok2 || one == other {
+			continue
+		}
+		if err := r.client.DisconnectNode(conn[0], conn[1]); err != nil {
+			return fmt.Errorf("failed to disconnect %s and %s: %v", conn[0], conn[1], err)
+		}
+		r.cuts = append(r.cuts, conn)
+	}
+	return nil
+}
+
+// call invokes an RPC method on a node, dialing it if needed.
+func (r *scenarioRun) call(ctx context.Context, node, method string, params []interface{}) (json.RawMessage, error) {
+	client, ok := r.rpcs[node]
+	if !ok {
+		var err error
+		if client, err = r.client.RPCClient(ctx, node); err != nil {
+			return nil, fmt.Errorf("failed to dial %s: %v", node, err)
+		}
+		r.rpcs[node] = client
+	}
+	var result json.RawMessage
+	if err := client.CallContext(ctx, &result, method, params...); err != nil {
+		return nil, fmt.Errorf("%s on %s failed: %v", method, node, err)
+	}
+	return result, nil
+}
+
+// checkRPC calls the step's RPC method on a node and compares the result with
+// the expected one.
+func (r *scenarioRun) checkRPC(ctx context.Context, node string, step *ScenarioStep) error {
+	have, err := r.call(ctx, node, step.Method, step.Params)
+	if err != nil {
+		return err
+	}
+	want, err := json.Marshal(step.Result)
+	if err != nil {
+		return err
+	}
+	if !jsonEqual(have, want) {
+		return fmt.Errorf("%s on %s returned %s, want %s", step.Method, node, have, want)
+	}
+	return nil
+}
+
+// condition returns a check for the assertion of an expect step.
+func (r *scenarioRun) condition(step *ScenarioStep, nodes []string) (func() error, error) {
+	switch step.Condition {
+	case "up", "down":
+		up := step.Condition == "up"
+		return func() error {
+			state, err := r.state()
+			if err != nil {
+				return err
+			}
+			for _, node := range nodes {
+				if state.up[node] != up {
+					return fmt.Errorf("%s is not %s", node, step.Condition)
+				}
+			}
+			return nil
+		}, nil
+
+	case "connected", "disconnected":
+		pairs, err := topologyPairs(nodes, step.Topology)
+		if err != nil {
+			return nil, err
+		}
+		connected := step.Condition == "connected"
+		return func() error {
+			state, err := r.state()
+			if err != nil {
+				return err
+			}
+			for _, pair := range pairs {
+				if state.connected(pair[0], pair[1]) != connected {
+					return fmt.Errorf("%s and %s are not %s", pair[0], pair[1], step.Condition)
+				}
+			}
+			return nil
+		}, nil
+
+	case "partitioned":
+		if len(step.Groups) < 2 {
+			return nil, errors.New("partitioned condition needs at least two groups")
+		}
+		return func() error {
+			state, err := r.state()
+			if err != nil {
+				return err
+			}
+			for i, group := range step.Groups {
+				for _, other := range step.Groups[i+1:] {
+					for _, one := range group {
+						for _, two := range other {
+							if state.connected(one, two) {
+								return fmt.Errorf("%s and %s are connected across partitions", one, two)
+							}
+						}
+					}
+				}
+			}
+			return nil
+		}, nil
+
+	case "peers":
+		return func() error {
+			state, err := r.state()
+			if err != nil {
+				return err
+			}
+			for _, node := range nodes {
+				if have := state.peers[node]; have < step.Peers {
+					return fmt.Errorf("%s has %d peers, want at least %d", node, have, step.Peers)
+				}
+			}
+			return nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown condition %q", step.Condition)
+	}
+}
+
+// poll retries a check until it succeeds or the timeout expires, returning
+// the last failure.
+func (r *scenarioRun) poll(ctx context.Context, timeout time.Duration, check func() error) error {
+	if timeout == 0 {
+		timeout = defaultExpectTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(expectPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// networkState is a snapshot of the nodes and live connections of the network,
+// keyed by node name.
+type networkState struct {
+	up    map[string]bool
+	peers map[string]int
+	conns [][2]string
+}
+
+// connected reports whecer two nodes are connected in either direction.
+func (s *networkState) connected(one, other string) bool {
+	for _, conn := range s.conns {
+		if (conn[0] == one && conn[1] == other) || (conn[0] == other && conn[1] == one) {
+			return true
+		}
+	}
+	return false
+}
+
+// state retrieves the current state of the network.
+func (r *scenarioRun) state() (*networkState, error) {
+	network, err := r.client.GetNetwork()
+	if err != nil {
+		return nil, err
+	}
+	state := &networkState{
+		up:    make(map[string]bool),
+		peers: make(map[string]int),
+	}
+	names := make(map[discover.NodeID]string)
+	for _, node := range network.Nodes {
+		names[node.Config.ID] = node.Config.Name
+		state.up[node.Config.Name] = node.Up
+	}
+	for _, conn := range network.Conns {
+		if !conn.Up {
+			continue
+		}
+		one, other := names[conn.One], names[conn.Other]
+		state.conns = append(state.conns, [2]string{one, other})
+		state.peers[one]++
+		state.peers[other]++
+	}
+	return state, nil
+}
+
+// topologyPairs returns the node pairs connecting the given nodes in a topology.
+func topologyPairs(nodes []string, topology string) ([][2]string, error) {
+	var pairs [][2]string
+	switch topology {
+	case "", "chain":
+		for i := 1; i < len(nodes); i++ {
+			pairs = append(pairs, [2]string{nodes[i-1], nodes[i]})
+		}
+	case "ring":
+		for i := 1; i < len(nodes); i++ {
+			pairs = append(pairs, [2]string{nodes[i-1], nodes[i]})
+		}
+		if len(nodes) > 2 {
+			pairs = append(pairs, [2]string{nodes[len(nodes)-1], nodes[0]})
+		}
+	case "star":
+		for i := 1; i < len(nodes); i++ {
+			pairs = append(pairs, [2]string{nodes[0], nodes[i]})
+		}
+	case "full":
+		for i := 0; i < len(nodes); i++ {
+			for j := i + 1; j < len(nodes); j++ {
+				pairs = append(pairs, [2]string{nodes[i], nodes[j]})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown topology %q", topology)
+	}
+	return pairs, nil
+}
+
+// jsonEqual reports whecer two JSON documents encode the same value.
+func jsonEqual(a, b []byte) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		return bytes.Equal(a, b)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		return false
+	}
+	xs, _ := json.Marshal(x)
+	ys, _ := json.Marshal(y)
+	return bytes.Equal(xs, ys)
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecchain/go-ecchain/node"
+	"github.com/ecchain/go-ecchain/p2p"
+	"github.com/ecchain/go-ecchain/p2p/simulations/adapters"
+	"github.com/ecchain/go-ecchain/rpc"
+)
+
+// scenarioService is a minimal service which keeps peers connected until they
+// are dropped, allowing them to reconnect any number of times.
+type scenarioService struct {
+	counter int64
+}
+
+func (s *scenarioService) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    "scenario",
+		Version: 1,
+		Length:  1,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+			}
+		},
+	}}
+}
+
+func (s *scenarioService) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "test",
+		Version:   "1.0",
+		Service:   &ScenarioAPI{counter: &s.counter},
+	}}
+}
+
+func (s *scenarioService) Start(server *p2p.Server) error { return nil }
+func (s *scenarioService) Stop() error                    { return nil }
+
+type ScenarioAPI struct {
+	counter *int64
+}
+
+func (api *ScenarioAPI) Get() int64 {
+	return atomic.LoadInt64(api.counter)
+}
+
+func (api *ScenarioAPI) Add(delta int64) {
+	atomic.AddInt64(api.counter, delta)
+}
+
+func scenarioHTTPServer() *httptest.Server {
+	adapter := adapters.NewSimAdapter(adapters.Services{
+		"scenario": func(ctx *adapters.ServiceContext) (node.Service, error) {
+			return new(scenarioService), nil
+		},
+	})
+	network := NewNetwork(adapter, &NetworkConfig{DefaultService: "scenario"})
+	return httptest.NewServer(NewServer(network))
+}
+
+const testScenario = `{
+  "name": "partition and heal",
+  "nodes": 4,
+  "steps": [
+    {"action": "connect", "topology": "full"},
+    {"action": "expect", "condition": "peers", "peers": 3},
+    {"action": "rpc", "method": "test_add", "params": [10], "nodes": ["node01"]},
+    {"action": "rpc", "method": "test_get", "result": 10, "nodes": ["node01"]},
+    {"action": "partition", "groups": [["node01", "node02"], ["node03", "node04"]]},
+    {"action": "expect", "condition": "partitioned", "groups": [["node01", "node02"], ["node03", "node04"]]},
+    {"action": "expect", "condition": "connected", "nodes": ["node01", "node02"]},
+    {"wait": "100ms", "action": "heal"},
+    {"action": "expect", "condition": "peers", "peers": 3, "timeout": "5s"},
+    {"action": "kill", "nodes": ["node04"]},
+    {"action": "expect", "condition": "down", "nodes": ["node04"]},
+    {"action": "expect", "condition": "disconnected", "nodes": ["node01", "node04"]}
+  ]
+}`
+
+// TestScenarioRun tests running a scenario against the HTTP API.
+func TestScenarioRun(t *testing.T) {
+	s := scenarioHTTPServer()
+	defer s.Close()
+
+	scenario := new(Scenario)
+	if err := json.Unmarshal([]byte(testScenario), scenario); err != nil {
+		t.Fatalf("failed to decode scenario: %v", err)
+	}
+	if scenario.Steps[7].Wait != 100*time.Millisecond {
+		t.Fatalf("wait mismatch: have %v, want %v", scenario.Steps[7].Wait, 100*time.Millisecond)
+	}
+	report := scenario.Run(context.Background(), NewClient(s.URL))
+
+	out := new(bytes.Buffer)
+	report.Write(out)
+	if !report.Passed {
+		t.Fatalf("scenario failed:\n%s", out)
+	}
+	if len(report.Steps) != len(scenario.Steps) {
+		t.Fatalf("step result count mismatch: have %d, want %d", len(report.Steps), len(scenario.Steps))
+	}
+}
+
+// TestScenarioFailure tests that failed assertions are reported without
+// aborting the run, while failed actions skip all remaining steps.
+func TestScenarioFailure(t *testing.T) {
+	s := scenarioHTTPServer()
+	defer s.Close()
+
+	scenario := &Scenario{
+		Name:  "failure",
+		Nodes: 2,
+		Steps: []*ScenarioStep{
+			{Action: "expect", Condition: "connected", Timeout: 200 * time.Millisecond},
+			{Action: "connect"},
+			{Action: "rpc", Method: "test_get", Result: 1, Nodes: []string{"node01"}, Timeout: 200 * time.Millisecond},
+			{Action: "connect", Nodes: []string{"node01", "node03"}},
+			{Action: "expect", Condition: "up"},
+		},
+	}
+	report := scenario.Run(context.Background(), NewClient(s.URL))
+	if report.Passed {
+		t.Fatal("scenario passed, expected failure")
+	}
+	want := []struct{ passed, skipped bool }{
+		{false, false}, {true, false}, {false, false}, {false, false}, {false, true},
+	}
+	if len(report.Steps) != len(want) {
+		t.Fatalf("step result count mismatch: have %d, want %d (%s)", len(report.Steps), len(want), report.Error)
+	}
+	for i, result := range report.Steps {
+		if result.Passed != want[i].passed || result.Skipped != want[i].skipped {
+			t.Errorf("step %d: have passed=%v skipped=%v, want passed=%v skipped=%v (%s)",
+				i+1, result.Passed, result.Skipped, want[i].passed, want[i].skipped, result.Error)
+		}
+	}
+}
+
+func TestTopologyPairs(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d"}
+	tests := map[string]int{"": 3, "chain": 3, "ring": 4, "star": 3, "full": 6}
+	for topology, want := range tests {
+		pairs, err := topologyPairs(nodes, topology)
+		if err != nil {
+			t.Fatalf("topology %q: %v", topology, err)
+		}
+		if len(pairs) != want {
+			t.Errorf("topology %q: have %d pairs, want %d", topology, len(pairs), want)
+		}
+	}
+	if _, err := topologyPairs(nodes, "mesh"); err == nil {
+		t.Error("unknown topology accepted")
+	}
+}
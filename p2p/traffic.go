@@ -0,0 +1,179 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the per protocol traffic accounting and egress rate limiting.
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ecchain/go-ecchain/common/mclock"
+	"github.com/ecchain/go-ecchain/metrics"
+)
+
+// MsgTraffic counts the messages and payload bytes exchanged with a peer.
+type MsgTraffic struct {
+	InMessages  uint64 `json:"inMessages"`
+	InBytes     uint64 `json:"inBytes"`
+	OutMessages uint64 `json:"outMessages"`
+	OutBytes    uint64 `json:"outBytes"`
+}
+
+// ProtocolTraffic is the traffic of a protocol with a peer, in total and broken
+// down by message code.
+type ProtocolTraffic struct {
+	MsgTraffic
+	Codes map[uint64]*MsgTraffic `json:"codes"`
+}
+
+// codeMeters are the metrics of one message code of a protocol.
+type codeMeters struct {
+	inPackets, inBytes   metrics.Meter
+	outPackets, outBytes metrics.Meter
+}
+
+// protoTraffic accounts the traffic of a protocol with a single peer, and feeds
+// the metrics of the protocol summed up over all peers.
+type protoTraffic struct {
+	name string
+
+	lock    sync.Mutex
+	traffic ProtocolTraffic
+	meters  map[uint64]*codeMeters
+}
+
+func newProtoTraffic(name string) *protoTraffic {
+	return &protoTraffic{
+		name:    name,
+		traffic: ProtocolTraffic{Codes: make(map[uint64]*MsgTraffic)},
+		meters:  make(map[uint64]*codeMeters),
+	}
+}
+
+// markIn accounts a message received from the peer.
+func (t *protoTraffic) markIn(code uint64, size uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.traffic.InMessages++
+	t.traffic.InBytes += uint64(size)
+	c := t.code(code)
+	c.InMessages++
+	c.InBytes += uint64(size)
+
+	if m := t.codeMeters(code); m != nil {
+		m.inPackets.Mark(1)
+		m.inBytes.Mark(int64(size))
+	}
+}
+
+// markOut accounts a message sent to the peer.
+func (t *protoTraffic) markOut(code uint64, size uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.traffic.OutMessages++
+	t.traffic.OutBytes += uint64(size)
+	c := t.code(code)
+	c.OutMessages++
+	c.OutBytes += uint64(size)
+
+	if m := t.codeMeters(code); m != nil {
+		m.outPackets.Mark(1)
+		m.outBytes.Mark(int64(size))
+	}
+}
+
+// code returns the counters of a message code. The lock must be held.
+func (t *protoTraffic) code(code uint64) *MsgTraffic {
+	c := t.traffic.Codes[code]
+	if c == nil {
+		c = new(MsgTraffic)
+		t.traffic.Codes[code] = c
+	}
+	return c
+}
+
+// codeMeters returns the metrics of a message code, nil if metrics are disabled.
+// The lock must be held.
+func (t *protoTraffic) codeMeters(code uint64) *codeMeters {
+	if !metrics.Enabled {
+		return nil
+	}
+	m := t.meters[code]
+	if m == nil {
+		prefix := fmt.Sprintf("p2p/%s/%d", t.name, code)
+		m = &codeMeters{
+			inPackets:  metrics.GetOrRegisterMeter(prefix+"/in/packets", nil),
+			inBytes:    metrics.GetOrRegisterMeter(prefix+"/in/bytes", nil),
+			outPackets: metrics.GetOrRegisterMeter(prefix+"/out/packets", nil),
+			outBytes:   metrics.GetOrRegisterMeter(prefix+"/out/bytes", nil),
+		}
+		t.meters[code] = m
+	}
+	return m
+}
+
+// info returns a copy of the counters.
+func (t *protoTraffic) info() *ProtocolTraffic {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	info := &ProtocolTraffic{MsgTraffic: t.traffic.MsgTraffic, Codes: make(map[uint64]*MsgTraffic)}
+	for code, c := range t.traffic.Codes {
+		cpy := *c
+		info.Codes[code] = &cpy
+	}
+	return info
+}
+
+// rateLimiter is a token bucket limiting the egress traffic of a protocol over
+// all peers. Messages larger than the available tokens are allowed through
+// but put the bucket into debt, delaying the following ones.
+type rateLimiter struct {
+	rate float64 // Bytes per second, also the capacity of the bucket
+
+	lock   sync.Mutex
+	tokens float64
+	last   mclock.AbsTime
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate), last: mclock.Now()}
+}
+
+// reserve takes the given number of bytes from the bucket, returning how long
+// the caller has to wait before sending them.
+func (l *rateLimiter) reserve(size uint32) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := mclock.Now()
+	l.tokens += l.rate * time.Duration(now-l.last).Seconds()
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	l.tokens -= float64(size)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerTraffic(t *testing.T) {
+	proto := Protocol{
+		Name:   "a",
+		Length: 5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			for i := 0; i < 2; i++ {
+				if err := ExpectMsg(rw, 2, []uint{1}); err != nil {
+					t.Error(err)
+				}
+			}
+			return SendItems(rw, 1, "reply")
+		},
+	}
+	closer, rw, peer, errc := testPeer([]Protocol{proto})
+	defer closer()
+
+	Send(rw, baseProtocolLength+2, []uint{1})
+	Send(rw, baseProtocolLength+2, []uint{1})
+	if err := ExpectMsg(rw, baseProtocolLength+1, []string{"reply"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errc:
+	case <-time.After(2 * time.Second):
+		t.Fatal("protocol did not return")
+	}
+
+	traffic := peer.Info().Traffic["a"]
+	if traffic == nil {
+		t.Fatal("no traffic reported for protocol")
+	}
+	if traffic.InMessages != 2 || traffic.InBytes != 4 || traffic.OutMessages != 1 || traffic.OutBytes != 7 {
+		t.Errorf("total traffic mismatch: %+v", traffic.MsgTraffic)
+	}
+	if in := traffic.Codes[2]; in == nil || in.InMessages != 2 || in.OutMessages != 0 {
+		t.Errorf("code 2 traffic mismatch: %+v", in)
+	}
+	if out := traffic.Codes[1]; out == nil || out.OutMessages != 1 || out.OutBytes != 7 {
+		t.Errorf("code 1 traffic mismatch: %+v", out)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(1000)
+	if wait := l.reserve(600); wait != 0 {
+		t.Errorf("wait within burst: have %v, want 0", wait)
+	}
+	// Going into debt must delay the sender until the debt is paid off
+	wait := l.reserve(900)
+	if wait < 400*time.Millisecond || wait > 500*time.Millisecond {
+		t.Errorf("wait mismatch: have %v, want ~500ms", wait)
+	}
+}
@@ -82,16 +82,23 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllecashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(ecashConfig), nil}
+	AllecashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(ecashConfig), nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the ecchain core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(ecashConfig), nil}
+	// AllDevProtocolChanges contains every protocol change (EIPs) introduced
+	// and accepted by the ecchain core developers into the developer consensus.
+	//
+	// This configuration is intentionally not using keyed fields to force anyone
+	// adding flags to the config to also have to set these fields.
+	AllDevProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &DevConfig{}}
+
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(ecashConfig), nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -121,6 +128,8 @@ type ChainConfig struct {
 	// Various consensus engines
 	ecash *ecashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	BFT    *BFTConfig    `json:"bft,omitempty"`
+	Dev    *DevConfig    `json:"dev,omitempty"`
 }
 
 // ecashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -133,8 +142,10 @@ func (c *ecashConfig) String() string {
 
 // CliqueConfig is the consensus engine configs for proof-of-authority based sealing.
 type CliqueConfig struct {
-	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
-	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
+	Period     uint64          `json:"period"`               // Number of seconds between blocks to enforce
+	Epoch      uint64          `json:"epoch"`                // Epoch length to reset votes and checkpoint
+	Governance *common.Address `json:"governance,omitempty"` // Contract listing the signers at each checkpoint (nil = voting)
+	Finality   uint64          `json:"finality,omitempty"`   // Number of blocks on top of a block after which it can't be reorged (0 = no finality)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -142,6 +153,31 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// BFTConfig is the consensus engine configs for byzantine fault tolerant
+// sealing with instant finality.
+type BFTConfig struct {
+	Period         uint64 `json:"period"`         // Number of seconds between blocks to enforce
+	Epoch          uint64 `json:"epoch"`          // Epoch length to reset votes and checkpoint
+	RequestTimeout uint64 `json:"requestTimeout"` // Milliseconds to wait for a round to commit before changing rounds
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *BFTConfig) String() string {
+	return "bft"
+}
+
+// DevConfig is the consensus engine configs for instant sealing on developer
+// chains.
+type DevConfig struct {
+	Period uint64 `json:"period,omitempty"` // Seconds after which empty blocks are sealed too (0 = never)
+	Manual bool   `json:"manual,omitempty"` // Seal blocks only when explicitly requested
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *DevConfig) String() string {
+	return "dev"
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -150,6 +186,10 @@ func (c *ChainConfig) String() string {
 		engine = c.ecash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.BFT != nil:
+		engine = c.BFT
+	case c.Dev != nil:
+		engine = c.Dev
 	default:
 		engine = "unknown"
 	}
@@ -167,6 +207,19 @@ func (c *ChainConfig) String() string {
 	)
 }
 
+// Finality returns the number of blocks that need to be built on top of a block
+// for it to become final, meaning it will never be reorged, and whecer blocks
+// ever become final at all on this chain.
+func (c *ChainConfig) Finality() (uint64, bool) {
+	switch {
+	case c.BFT != nil:
+		return 0, true
+	case c.Clique != nil && c.Clique.Finality > 0:
+		return c.Clique.Finality, true
+	}
+	return 0, false
+}
+
 // IsHomestead returns whecer num is either equal to the homestead block or greater.
 func (c *ChainConfig) IsHomestead(num *big.Int) bool {
 	return isForked(c.HomesteadBlock, num)
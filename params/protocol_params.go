@@ -18,10 +18,6 @@ package params
 
 import "math/big"
 
-var (
-	TargetGasLimit uint64 = GenesisGasLimit // The artificial target
-)
-
 const (
 	GasLimitBoundDivisor uint64 = 1024    // The bound divisor of the gas limit, used in update calculations.
 	MinGasLimit          uint64 = 5000    // Minimum the gas limit may ever be.
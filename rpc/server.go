@@ -26,6 +26,7 @@ import (
 	"sync/atomic"
 
 	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/tracing"
 	"gopkg.in/fatih/set.v0"
 )
 
@@ -159,7 +160,7 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 
 	// test if the server is ordered to stop
 	for atomic.LoadInt32(&s.run) == 1 {
-		reqs, batch, err := s.readRequest(codec)
+		reqs, batch, err := s.readRequest(ctx, codec)
 		if err != nil {
 			// If a parsing error occurred, send an error
 			if err.Error() != "EOF" {
@@ -174,6 +175,9 @@ func (s *Server) serveRequest(codec ServerCodec, singleShot bool, options CodecO
 		// check if server is ordered to shutdown and return an error
 		// telling the client that his request failed.
 		if atomic.LoadInt32(&s.run) != 1 {
+			for _, r := range reqs {
+				r.span.End()
+			}
 			err = &shutdownError{}
 			if batch {
 				resps := make([]interface{}, len(reqs))
@@ -255,7 +259,16 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 
 // handle executes a request and returns the response from the callback.
 func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	// Requests traced since their arrival carry their span in their context
+	if req.span != nil {
+		ctx = req.ctx
+		if req.callb != nil {
+			req.span.SetAttribute("rpc.method", req.svcname+serviceMethodSeparator+req.callb.method.Name)
+		}
+		defer req.span.End()
+	}
 	if req.err != nil {
+		req.span.SetError(req.err)
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
 
@@ -325,13 +338,7 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 
 // exec executes the given request and writes the result back using the codec.
 func (s *Server) exec(ctx context.Context, codec ServerCodec, req *serverRequest) {
-	var response interface{}
-	var callback func()
-	if req.err != nil {
-		response = codec.CreateErrorResponse(&req.id, req.err)
-	} else {
-		response, callback = s.handle(ctx, codec, req)
-	}
+	response, callback := s.handle(ctx, codec, req)
 
 	if err := codec.Write(response); err != nil {
 		log.Error(fmt.Sprintf("%v\n", err))
@@ -350,13 +357,9 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 	responses := make([]interface{}, len(requests))
 	var callbacks []func()
 	for i, req := range requests {
-		if req.err != nil {
-			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
-		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+		var callback func()
+		if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
+			callbacks = append(callbacks, callback)
 		}
 	}
 
@@ -374,72 +377,85 @@ func (s *Server) execBatch(ctx context.Context, codec ServerCodec, requests []*s
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
 // error when the request could not be read/parsed.
-func (s *Server) readRequest(codec ServerCodec) ([]*serverRequest, bool, Error) {
+//
+// Headers are read as soon as a request arrives, so the trace span of each request is started
+// here, under the connection context, and the argument decoding is traced as its child.
+func (s *Server) readRequest(ctx context.Context, codec ServerCodec) ([]*serverRequest, bool, Error) {
 	reqs, batch, err := codec.ReadRequestHeaders()
 	if err != nil {
 		return nil, batch, err
 	}
-
 	requests := make([]*serverRequest, len(reqs))
 
 	// verify requests
 	for i, r := range reqs {
-		var ok bool
-		var svc *service
-
-		if r.err != nil {
-			requests[i] = &serverRequest{id: r.id, err: r.err}
-			continue
-		}
+		rctx, span := tracing.StartSpan(ctx, "rpc.Server.handle")
+		requests[i] = s.resolveRequest(rctx, codec, r)
+		requests[i].ctx, requests[i].span = rctx, span
+	}
+	return requests, batch, nil
+}
 
-		if r.isPubSub && strings.HasSuffix(r.method, unsubscribeMethodSuffix) {
-			requests[i] = &serverRequest{id: r.id, isUnsubscribe: true}
-			argTypes := []reflect.Type{reflect.TypeOf("")} // expect subscription id as first arg
-			if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
-				requests[i].args = args
-			} else {
-				requests[i].err = &invalidParamsError{err.Error()}
-			}
-			continue
+// resolveRequest looks up the callback of a request and decodes its arguments.
+func (s *Server) resolveRequest(ctx context.Context, codec ServerCodec, r rpcRequest) *serverRequest {
+	if r.err != nil {
+		return &serverRequest{id: r.id, err: r.err}
+	}
+	if r.isPubSub && strings.HasSuffix(r.method, unsubscribeMethodSuffix) {
+		req := &serverRequest{id: r.id, isUnsubscribe: true}
+		argTypes := []reflect.Type{reflect.TypeOf("")} // expect subscription id as first arg
+		if args, err := parseArguments(ctx, codec, argTypes, r.params); err == nil {
+			req.args = args
+		} else {
+			req.err = &invalidParamsError{err.Error()}
 		}
-
-		if svc, ok = s.services[r.service]; !ok { // rpc method isn't available
-			requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
-			continue
+		return req
+	}
+	svc, ok := s.services[r.service]
+	if !ok { // rpc method isn't available
+		return &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+	}
+	if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
+		callb, ok := svc.subscriptions[r.method]
+		if !ok {
+			return &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 		}
-
-		if r.isPubSub { // eth_subscribe, r.method contains the subscription method name
-			if callb, ok := svc.subscriptions[r.method]; ok {
-				requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
-				if r.params != nil && len(callb.argTypes) > 0 {
-					argTypes := []reflect.Type{reflect.TypeOf("")}
-					argTypes = append(argTypes, callb.argTypes...)
-					if args, err := codec.ParseRequestArguments(argTypes, r.params); err == nil {
-						requests[i].args = args[1:] // first one is service.method name which isn't an actual argument
-					} else {
-						requests[i].err = &invalidParamsError{err.Error()}
-					}
-				}
+		req := &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+		if r.params != nil && len(callb.argTypes) > 0 {
+			argTypes := []reflect.Type{reflect.TypeOf("")}
+			argTypes = append(argTypes, callb.argTypes...)
+			if args, err := parseArguments(ctx, codec, argTypes, r.params); err == nil {
+				req.args = args[1:] // first one is service.method name which isn't an actual argument
 			} else {
-				requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+				req.err = &invalidParamsError{err.Error()}
 			}
-			continue
 		}
-
-		if callb, ok := svc.callbacks[r.method]; ok { // lookup RPC method
-			requests[i] = &serverRequest{id: r.id, svcname: svc.name, callb: callb}
-			if r.params != nil && len(callb.argTypes) > 0 {
-				if args, err := codec.ParseRequestArguments(callb.argTypes, r.params); err == nil {
-					requests[i].args = args
-				} else {
-					requests[i].err = &invalidParamsError{err.Error()}
-				}
-			}
-			continue
+		return req
+	}
+	callb, ok := svc.callbacks[r.method] // lookup RPC method
+	if !ok {
+		return &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
+	}
+	req := &serverRequest{id: r.id, svcname: svc.name, callb: callb}
+	if r.params != nil && len(callb.argTypes) > 0 {
+		if args, err := parseArguments(ctx, codec, callb.argTypes, r.params); err == nil {
+			req.args = args
+		} else {
+			req.err = &invalidParamsError{err.Error()}
 		}
-
-		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 	}
+	return req
+}
 
-	return requests, batch, nil
+// parseArguments decodes the arguments of a request, tracing the decoding as a child
+// of the request span in ctx.
+func parseArguments(ctx context.Context, codec ServerCodec, argTypes []reflect.Type, params interface{}) ([]reflect.Value, Error) {
+	_, span := tracing.StartSpan(ctx, "rpc.Server.parseArguments")
+	defer span.End()
+
+	args, err := codec.ParseRequestArguments(argTypes, params)
+	if err != nil {
+		span.SetError(err)
+	}
+	return args, err
 }
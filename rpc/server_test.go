@@ -23,6 +23,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 type Service struct{}
@@ -160,3 +162,73 @@ func TestServerMethodExecution(t *testing.T) {
 func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
+
+// spanCollector gathers all exported tracing spans in memory.
+type spanCollector struct {
+	spans []*tracing.Span
+}
+
+func (c *spanCollector) Export(spans []*tracing.Span) error {
+	c.spans = append(c.spans, spans...)
+	return nil
+}
+
+func (c *spanCollector) Close() error { return nil }
+
+// Tests that the argument decoding of a request is traced under the span of
+// the request, also if the arguments are invalid.
+func TestServerTracing(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("test", new(Service)); err != nil {
+		t.Fatal(err)
+	}
+	collector := new(spanCollector)
+	tracing.Start(collector)
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+
+	go server.ServeCodec(NewJSONCodec(serverConn), OptionMethodInvocation)
+
+	out := json.NewEncoder(clientConn)
+	in := json.NewDecoder(clientConn)
+
+	requests := []map[string]interface{}{
+		{"id": 1, "method": "test_echo", "version": "2.0", "params": []interface{}{"s", 1, &Args{"a"}}},
+		{"id": 2, "method": "test_echo", "version": "2.0", "params": []interface{}{1}},
+	}
+	for _, request := range requests {
+		if err := out.Encode(request); err != nil {
+			t.Fatal(err)
+		}
+		var response map[string]interface{}
+		if err := in.Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tracing.Stop(); err != nil {
+		t.Fatalf("failed to stop tracer: %v", err)
+	}
+	var handles, parses []*tracing.Span
+	for _, span := range collector.spans {
+		switch span.Name {
+		case "rpc.Server.handle":
+			handles = append(handles, span)
+		case "rpc.Server.parseArguments":
+			parses = append(parses, span)
+		default:
+			t.Errorf("unexpected span %s", span.Name)
+		}
+	}
+	if len(handles) != 2 || len(parses) != 2 {
+		t.Fatalf("span count mismatch: have %d handle and %d parse spans, want 2 each", len(handles), len(parses))
+	}
+	for i, parse := range parses {
+		if parse.TraceID != handles[i].TraceID || parse.ParentID != handles[i].SpanID {
+			t.Errorf("request %d: argument decoding not traced under the request span", i)
+		}
+	}
+	if handles[0].Err != nil || handles[1].Err == nil {
+		t.Errorf("request errors mismatch: have %v and %v, want only the second", handles[0].Err, handles[1].Err)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter is the interface for sinks receiving batches of finished spans.
+type Exporter interface {
+	// Export delivers a batch of finished spans. It is never called concurrently.
+	Export(spans []*Span) error
+
+	// Close flushes any buffered data and releases the exporter's resources.
+	Close() error
+}
+
+// NewExporter creates an OTLP/JSON exporter for the given endpoint. HTTP(S) URLs
+// are treated as OTLP collectors, anything else as a local file path.
+func NewExporter(endpoint, service string) (Exporter, error) {
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		return NewCollectorExporter(endpoint, service), nil
+	}
+	return NewFileExporter(endpoint, service)
+}
+
+// fileExporter appends OTLP/JSON encoded span batches to a local file, one
+// export request per line.
+type fileExporter struct {
+	service string
+	file    *os.File
+	writer  *bufio.Writer
+	lock    sync.Mutex
+}
+
+// NewFileExporter creates an exporter appending OTLP/JSON lines to the file at
+// the given path.
+func NewFileExporter(path, service string) (Exporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileExporter{
+		service: service,
+		file:    file,
+		writer:  bufio.NewWriter(file),
+	}, nil
+}
+
+// Export implements Exporter, encoding the spans into the output file.
+func (e *fileExporter) Export(spans []*Span) error {
+	blob, err := json.Marshal(newExportRequest(e.service, spans))
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if _, err := e.writer.Write(append(blob, '\n')); err != nil {
+		return err
+	}
+	return e.writer.Flush()
+}
+
+// Close implements Exporter, closing the output file.
+func (e *fileExporter) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if err := e.writer.Flush(); err != nil {
+		e.file.Close()
+		return err
+	}
+	return e.file.Close()
+}
+
+// collectorExporter posts OTLP/JSON encoded span batches to a collector's HTTP
+// trace endpoint.
+type collectorExporter struct {
+	service string
+	url     string
+	client  *http.Client
+}
+
+// NewCollectorExporter creates an exporter posting spans to an OTLP/HTTP
+// collector. If the URL has no path, the default "/v1/traces" is used.
+func NewCollectorExporter(url, service string) Exporter {
+	if rest := url[strings.Index(url, "://")+3:]; !strings.Contains(rest, "/") {
+		url += "/v1/traces"
+	}
+	return &collectorExporter{
+		service: service,
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export implements Exporter, sending the spans to the collector.
+func (e *collectorExporter) Export(spans []*Span) error {
+	blob, err := json.Marshal(newExportRequest(e.service, spans))
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Post(e.url, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", res.Status)
+	}
+	return nil
+}
+
+// Close implements Exporter. The collector exporter holds no resources.
+func (e *collectorExporter) Close() error {
+	return nil
+}
+
+// OTLP/JSON wire types, limited to the subset of the protocol needed to export
+// spans. See https://github.com/open-telemetry/opentelemetry-proto.
+type (
+	otlpExportRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+const (
+	otlpSpanKindInternal = 1 // SPAN_KIND_INTERNAL
+	otlpStatusError      = 2 // STATUS_CODE_ERROR
+)
+
+// newExportRequest converts a batch of spans into an OTLP export request.
+func newExportRequest(service string, spans []*Span) *otlpExportRequest {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.lock.Lock()
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(span.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.SpanID[:]),
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Started.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.Ended.UnixNano(), 10),
+		}
+		if span.ParentID != (SpanID{}) {
+			s.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+		}
+		for _, attr := range span.Attrs {
+			s.Attributes = append(s.Attributes, newKeyValue(attr.Key, attr.Value))
+		}
+		if span.Err != nil {
+			s.Status = &otlpStatus{Code: otlpStatusError, Message: span.Err.Error()}
+		}
+		span.lock.Unlock()
+
+		converted = append(converted, s)
+	}
+	return &otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{newKeyValue("service.name", service)},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/ecchain/go-ecchain/tracing"},
+				Spans: converted,
+			}},
+		}},
+	}
+}
+
+// newKeyValue converts an attribute into its typed OTLP representation.
+func newKeyValue(key string, value interface{}) otlpKeyValue {
+	var v map[string]interface{}
+	switch value := value.(type) {
+	case bool:
+		v = map[string]interface{}{"boolValue": value}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(int64(value), 10)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case uint64:
+		v = map[string]interface{}{"intValue": strconv.FormatUint(value, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": value}
+	case string:
+		v = map[string]interface{}{"stringValue": value}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpKeyValue{Key: key, Value: v}
+}
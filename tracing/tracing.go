@@ -0,0 +1,263 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing implements a lightweight span based request tracer whose
+// output follows the OpenTelemetry (OTLP/JSON) data model.
+//
+// Tracing is disabled by default. Hot code paths should guard span creation
+// with a check of Enabled so that no work at all is done unless a tracer was
+// started:
+//
+//	if tracing.Enabled() {
+//		_, span := tracing.StartSpan(ctx, "core.BlockChain.insertChain")
+//		defer span.End()
+//	}
+//
+// All methods of Span are safe to call on a nil span.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecchain/go-ecchain/log"
+	"github.com/ecchain/go-ecchain/metrics"
+)
+
+const (
+	// queueSize is the maximum number of finished spans waiting for export. Any
+	// spans finished while the queue is full are dropped.
+	queueSize = 8192
+
+	// batchSize is the maximum number of spans handed to an exporter at once.
+	batchSize = 512
+
+	// flushInterval is the maximum time a finished span waits for export.
+	flushInterval = 2 * time.Second
+)
+
+// enabled is the global kill-switch of the tracer, set to 1 by Start and reset
+// by Stop. It is accessed atomically.
+var enabled int32
+
+// Enabled reports whecer a tracer is running. It should be checked by callers
+// before creating spans on hot code paths.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+var droppedSpanMeter = metrics.NewRegisteredMeter("tracing/dropped", nil)
+
+// TraceID is the unique identifier of a trace, shared by all of its spans.
+type TraceID [16]byte
+
+// SpanID is the unique identifier of a single span within a trace.
+type SpanID [8]byte
+
+// Span represents a single timed operation within a trace.
+type Span struct {
+	Name     string
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // Zero for root spans
+	Started  time.Time
+	Ended    time.Time
+	Attrs    []Attribute
+	Err      error
+
+	ended bool
+	lock  sync.Mutex
+}
+
+// Attribute is a key-value pair annotating a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// spanKey is the context key under which the active span is stored.
+type spanKey struct{}
+
+// tracer is the currently running tracer, batching finished spans and handing
+// them over to an exporter.
+var tracer struct {
+	exporter Exporter
+	queue    chan *Span
+	quit     chan chan error
+	lock     sync.RWMutex
+}
+
+// Start launches the tracer, exporting all finished spans through the given
+// exporter. Start must be called before any spans are created, typically during
+// program startup.
+func Start(exporter Exporter) {
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+
+	if tracer.exporter != nil {
+		return
+	}
+	tracer.exporter = exporter
+	tracer.queue = make(chan *Span, queueSize)
+	tracer.quit = make(chan chan error)
+
+	go loop(exporter, tracer.queue, tracer.quit)
+	atomic.StoreInt32(&enabled, 1)
+}
+
+// Stop flushes all pending spans to the exporter and terminates the tracer.
+func Stop() error {
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+
+	if tracer.exporter == nil {
+		return nil
+	}
+	atomic.StoreInt32(&enabled, 0)
+
+	errc := make(chan error)
+	tracer.quit <- errc
+	err := <-errc
+
+	tracer.exporter, tracer.queue, tracer.quit = nil, nil, nil
+	return err
+}
+
+// loop batches finished spans and exports them either when a batch fills up or
+// when the flush interval elapses.
+func loop(exporter Exporter, queue chan *Span, quit chan chan error) {
+	var (
+		batch  = make([]*Span, 0, batchSize)
+		ticker = time.NewTicker(flushInterval)
+	)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := exporter.Export(batch); err != nil {
+			log.Warn("Failed to export trace spans", "spans", len(batch), "err", err)
+		}
+		batch = make([]*Span, 0, batchSize)
+	}
+	for {
+		select {
+		case span := <-queue:
+			if batch = append(batch, span); len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+
+		case errc := <-quit:
+			for {
+				select {
+				case span := <-queue:
+					batch = append(batch, span)
+					continue
+				default:
+				}
+				break
+			}
+			flush()
+			errc <- exporter.Close()
+			return
+		}
+	}
+}
+
+// StartSpan creates a new span with the given name, parented to the span found
+// in ctx if any, and returns it along with a derived context carrying it. If the
+// tracer is not running, the original context and a nil span are returned.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	span := &Span{
+		Name:    name,
+		Started: time.Now(),
+	}
+	if parent := FromContext(ctx); parent != nil {
+		span.TraceID, span.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		rand.Read(span.TraceID[:])
+	}
+	rand.Read(span.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext retrieves the active span from the context, or nil if none.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetAttribute annotates the span with a key-value pair.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.Attrs = append(s.Attrs, Attribute{Key: key, Value: value})
+	s.lock.Unlock()
+}
+
+// SetError marks the span as failed with the given error. Nil errors are
+// ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	s.Err = err
+	s.lock.Unlock()
+}
+
+// End marks the end of the operation tracked by the span and queues it for
+// export. Subsequent calls are no-ops.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended, s.Ended = true, time.Now()
+	s.lock.Unlock()
+
+	tracer.lock.RLock()
+	queue := tracer.queue
+	tracer.lock.RUnlock()
+
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- s:
+	default:
+		droppedSpanMeter.Mark(1)
+	}
+}
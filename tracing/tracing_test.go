@@ -0,0 +1,153 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ecereum library.
+//
+// The go-ecereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ecereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ecereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memoryExporter collects all exported spans in memory.
+type memoryExporter struct {
+	spans  []*Span
+	closed bool
+}
+
+func (e *memoryExporter) Export(spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+// Tests that spans are not created while the tracer is disabled and that the
+// nil spans returned are safe to use.
+func TestDisabledTracing(t *testing.T) {
+	ctx := context.Background()
+
+	derived, span := StartSpan(ctx, "disabled")
+	if span != nil {
+		t.Fatalf("span created while disabled: %v", span)
+	}
+	if derived != ctx {
+		t.Fatalf("context derived while disabled")
+	}
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("failure"))
+	span.End()
+}
+
+// Tests that child spans inherit the trace of their parents and that all spans
+// are flushed to the exporter on stop.
+func TestSpanExport(t *testing.T) {
+	exporter := new(memoryExporter)
+	Start(exporter)
+
+	ctx, root := StartSpan(context.Background(), "root")
+	_, child := StartSpan(ctx, "child")
+	child.SetAttribute("number", 1)
+	child.SetError(errors.New("failure"))
+	child.End()
+	root.End()
+	root.End() // double end should be ignored
+
+	if err := Stop(); err != nil {
+		t.Fatalf("failed to stop tracer: %v", err)
+	}
+	if Enabled() {
+		t.Fatalf("tracer still enabled after stop")
+	}
+	if !exporter.closed {
+		t.Fatalf("exporter not closed on stop")
+	}
+	if len(exporter.spans) != 2 {
+		t.Fatalf("exported span count mismatch: have %d, want 2", len(exporter.spans))
+	}
+	if child.TraceID != root.TraceID {
+		t.Errorf("child trace mismatch: have %x, want %x", child.TraceID, root.TraceID)
+	}
+	if child.ParentID != root.SpanID {
+		t.Errorf("child parent mismatch: have %x, want %x", child.ParentID, root.SpanID)
+	}
+	if root.ParentID != (SpanID{}) {
+		t.Errorf("root span has parent %x", root.ParentID)
+	}
+}
+
+// Tests that the file exporter writes valid OTLP/JSON export requests.
+func TestFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spans.json")
+	exporter, err := NewExporter(path, "test")
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	Start(exporter)
+
+	_, span := StartSpan(context.Background(), "ecdb.Get")
+	span.SetAttribute("size", 32)
+	span.End()
+
+	if err := Stop(); err != nil {
+		t.Fatalf("failed to stop tracer: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open export file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatalf("no export request written")
+	}
+	var req otlpExportRequest
+	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+		t.Fatalf("failed to decode export request: %v", err)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("invalid export request layout: %+v", req)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("exported span count mismatch: have %d, want 1", len(spans))
+	}
+	if spans[0].Name != "ecdb.Get" {
+		t.Errorf("span name mismatch: have %s, want ecdb.Get", spans[0].Name)
+	}
+	if spans[0].SpanID != hex.EncodeToString(span.SpanID[:]) {
+		t.Errorf("span id mismatch: have %s, want %x", spans[0].SpanID, span.SpanID)
+	}
+	if len(spans[0].Attributes) != 1 || spans[0].Attributes[0].Value["intValue"] != "32" {
+		t.Errorf("span attributes mismatch: %+v", spans[0].Attributes)
+	}
+}
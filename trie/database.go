@@ -122,17 +122,24 @@ func (db *Database) insertPreimage(hash common.Hash, preimage []byte) {
 // the method queries the persistent database for the content.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	// Retrieve the node from cache if available
-	db.lock.RLock()
-	node := db.nodes[hash]
-	db.lock.RUnlock()
-
-	if node != nil {
-		return node.blob, nil
+	if blob := db.cachedNode(hash); blob != nil {
+		return blob, nil
 	}
 	// Content unavailable in memory, attempt to retrieve from disk
 	return db.diskdb.Get(hash[:])
 }
 
+// cachedNode retrieves a trie node from memory, or nil if it's not cached.
+func (db *Database) cachedNode(hash common.Hash) []byte {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if node := db.nodes[hash]; node != nil {
+		return node.blob
+	}
+	return nil
+}
+
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
 // found cached, the method queries the persistent database for the content.
 func (db *Database) preimage(hash common.Hash) ([]byte, error) {
@@ -17,6 +17,7 @@
 package trie
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/ecchain/go-ecchain/common"
@@ -159,6 +160,12 @@ func (t *SecureTrie) Hash() common.Hash {
 	return t.trie.Hash()
 }
 
+// SetTraceContext sets the context whose span the trie's persistent database
+// reads are traced as children of. A nil context disables tracing them.
+func (t *SecureTrie) SetTraceContext(ctx context.Context) {
+	t.trie.SetTraceContext(ctx)
+}
+
 func (t *SecureTrie) Root() []byte {
 	return t.trie.Root()
 }
@@ -19,12 +19,15 @@ package trie
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
 	"github.com/ecchain/go-ecchain/common"
 	"github.com/ecchain/go-ecchain/crypto"
+	"github.com/ecchain/go-ecchain/ecdb"
 	"github.com/ecchain/go-ecchain/log"
 	"github.com/ecchain/go-ecchain/metrics"
+	"github.com/ecchain/go-ecchain/tracing"
 )
 
 var (
@@ -74,6 +77,9 @@ type Trie struct {
 	// new nodes are tagged with the current generation and unloaded
 	// when their generation is older than than cachegen-cachelimit.
 	cachegen, cachelimit uint16
+
+	// Trace context the persistent database reads are traced under, if any
+	traceCtx context.Context
 }
 
 // SetCacheLimit sets the number of 'cache generations' to keep.
@@ -434,13 +440,36 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 
 	hash := common.BytesToHash(n)
 
-	enc, err := t.db.Node(hash)
+	var (
+		enc []byte
+		err error
+	)
+	if t.traceCtx != nil && tracing.Enabled() {
+		enc, err = t.tracedNode(hash)
+	} else {
+		enc, err = t.db.Node(hash)
+	}
 	if err != nil || enc == nil {
 		return nil, &MissingNodeError{NodeHash: hash, Path: prefix}
 	}
 	return mustDecodeNode(n, enc, t.cachegen), nil
 }
 
+// tracedNode retrieves a trie node like Database.Node does, tracing the reads
+// hitting the persistent database as children of the trie's trace context.
+func (t *Trie) tracedNode(hash common.Hash) ([]byte, error) {
+	if blob := t.db.cachedNode(hash); blob != nil {
+		return blob, nil
+	}
+	return ecdb.TracedGet(t.traceCtx, t.db.diskdb, hash[:])
+}
+
+// SetTraceContext sets the context whose span the trie's persistent database
+// reads are traced as children of. A nil context disables tracing them.
+func (t *Trie) SetTraceContext(ctx context.Context) {
+	t.traceCtx = ctx
+}
+
 // Root returns the root hash of the trie.
 // Deprecated: use Hash instead.
 func (t *Trie) Root() []byte { return t.Hash().Bytes() }